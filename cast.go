@@ -0,0 +1,339 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// CastView is a View which converts the data of another View to
+// a different DType.
+//
+// The conversion takes place each time Data is called, and its result is
+// not retained: this way, serializing CastView values with
+// SerializeToWriter only requires the data of one converted tensor to be
+// held in memory at a time.
+//
+// Conversions to floating point DTypes round to the nearest representable
+// value. Conversions to integer DTypes truncate toward zero, and saturate
+// to the range of the target DType, while NaN values become zero.
+// Conversions to BOOL map to true any value which is non-zero after such
+// truncation.
+type CastView struct {
+	view  View
+	dType DType
+}
+
+// Cast returns a CastView converting the data of v to the given DType.
+//
+// Unless allowLossy is true, an error is returned if v contains any value
+// which cannot be represented exactly by an integer or BOOL target DType,
+// such as fractional or out-of-range values. Conversions to floating
+// point DTypes are always allowed.
+func Cast(v View, dType DType, allowLossy bool) (CastView, error) {
+	if !dType.isValid() {
+		return CastView{}, fmt.Errorf("invalid target DType %d", dType)
+	}
+	cv := CastView{view: v, dType: dType}
+	if allowLossy || dType == v.DType() || dType.isFloat() {
+		return cv, nil
+	}
+
+	// Check all values before any data is actually written.
+	conv := newConverter(v.DType(), dType)
+	src := v.Data()
+	dst := make([]byte, conv.dstSize)
+	for i := 0; i < len(src); i += conv.srcSize {
+		if !conv.convert(dst, src[i:i+conv.srcSize]) {
+			return CastView{}, fmt.Errorf("cannot cast from %s to %s without loss: value at index %d is not representable", v.DType(), dType, i/conv.srcSize)
+		}
+	}
+	return cv, nil
+}
+
+func (cv CastView) DType() DType    { return cv.dType }
+func (cv CastView) Shape() []uint64 { return cv.view.Shape() }
+
+func (cv CastView) DataLen() uint64 {
+	return cv.view.DataLen() / cv.view.DType().Size() * cv.dType.Size()
+}
+
+func (cv CastView) Data() []byte {
+	srcType := cv.view.DType()
+	src := cv.view.Data()
+	if srcType == cv.dType {
+		return src
+	}
+
+	dst := make([]byte, cv.DataLen())
+	if srcType == F32 && (cv.dType == F16 || cv.dType == BF16) {
+		castF32ToHalf(dst, src, cv.dType)
+		return dst
+	}
+
+	conv := newConverter(srcType, cv.dType)
+	for i, j := 0, 0; i < len(src); i, j = i+conv.srcSize, j+conv.dstSize {
+		conv.convert(dst[j:j+conv.dstSize], src[i:i+conv.srcSize])
+	}
+	return dst
+}
+
+// castF32ToHalf is a faster path for the most common conversions.
+func castF32ToHalf(dst, src []byte, dType DType) {
+	values := make([]uint16, len(src)/4)
+	if dType == F16 {
+		Float32ToF16Slice(values, bytesAs[float32](src))
+	} else {
+		Float32ToBF16Slice(values, bytesAs[float32](src))
+	}
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(dst[i*2:], v)
+	}
+}
+
+// CastOptions allows to customize the behavior of CastTensors.
+type CastOptions struct {
+	// Overrides maps the names of specific tensors to their target DType,
+	// taking precedence over the default one. Unlike the default DType,
+	// overrides apply to tensors of any DType, not only floating point ones.
+	Overrides map[string]DType
+	// AllowLossy allows conversions which cannot represent some values
+	// exactly, as described for Cast.
+	AllowLossy bool
+}
+
+// CastTensors wraps each tensor within a CastView.
+//
+// Floating point tensors are converted to dType, while all other tensors
+// keep their DType, unless otherwise specified by opts.Overrides.
+func CastTensors[V View](data map[string]V, dType DType, opts CastOptions) (map[string]CastView, error) {
+	result := make(map[string]CastView, len(data))
+	for name, v := range data {
+		target := v.DType()
+		if override, ok := opts.Overrides[name]; ok {
+			target = override
+		} else if target.isFloat() {
+			target = dType
+		}
+
+		cv, err := Cast(v, target, opts.AllowLossy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cast tensor %q: %w", name, err)
+		}
+		result[name] = cv
+	}
+	return result, nil
+}
+
+// converter converts single elements from one DType to another.
+type converter struct {
+	srcSize int
+	dstSize int
+	// convert converts one element, reporting whether the conversion
+	// is exact.
+	convert func(dst, src []byte) bool
+}
+
+func newConverter(srcType, dstType DType) converter {
+	c := converter{
+		srcSize: int(srcType.Size()),
+		dstSize: int(dstType.Size()),
+	}
+
+	switch {
+	case dstType.isFloat() && srcType.isFloat():
+		read, write := floatReader(srcType), floatWriter(dstType)
+		c.convert = func(dst, src []byte) bool {
+			write(dst, read(src))
+			return true
+		}
+	case dstType.isFloat():
+		read, write := intReader(srcType), intFloatWriter(dstType)
+		c.convert = func(dst, src []byte) bool {
+			neg, abs := read(src)
+			write(dst, neg, abs)
+			return true
+		}
+	case srcType.isFloat():
+		read := floatReader(srcType)
+		c.convert = func(dst, src []byte) bool {
+			neg, abs, exact := floatToInt(read(src))
+			return writeInt(dst, dstType, neg, abs) && exact
+		}
+	default:
+		read := intReader(srcType)
+		c.convert = func(dst, src []byte) bool {
+			neg, abs := read(src)
+			return writeInt(dst, dstType, neg, abs)
+		}
+	}
+	return c
+}
+
+// isFloat reports whether dt is a floating point DType.
+func (dt DType) isFloat() bool {
+	switch dt {
+	case F8_E5M2, F8_E4M3, F16, BF16, F32, F64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSigned reports whether dt is a signed integer DType.
+func (dt DType) isSigned() bool {
+	switch dt {
+	case I8, I16, I32, I64:
+		return true
+	default:
+		return false
+	}
+}
+
+func floatReader(dt DType) func([]byte) float64 {
+	switch dt {
+	case F8_E5M2:
+		return func(b []byte) float64 { return float64(F8E5M2ToFloat32(b[0])) }
+	case F8_E4M3:
+		return func(b []byte) float64 { return float64(F8E4M3ToFloat32(b[0])) }
+	case F16:
+		return func(b []byte) float64 { return float64(F16ToFloat32(binary.LittleEndian.Uint16(b))) }
+	case BF16:
+		return func(b []byte) float64 { return float64(BF16ToFloat32(binary.LittleEndian.Uint16(b))) }
+	case F32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	default:
+		return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	}
+}
+
+func floatWriter(dt DType) func([]byte, float64) {
+	switch dt {
+	case F8_E5M2:
+		return func(b []byte, f float64) { b[0] = uint8(f8E5M2.fromFloat64(f)) }
+	case F8_E4M3:
+		return func(b []byte, f float64) { b[0] = uint8(f8E4M3.fromFloat64(f)) }
+	case F16:
+		return func(b []byte, f float64) { binary.LittleEndian.PutUint16(b, uint16(f16.fromFloat64(f))) }
+	case BF16:
+		return func(b []byte, f float64) { binary.LittleEndian.PutUint16(b, uint16(bf16.fromFloat64(f))) }
+	case F32:
+		return func(b []byte, f float64) { binary.LittleEndian.PutUint32(b, math.Float32bits(float32(f))) }
+	default:
+		return func(b []byte, f float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(f)) }
+	}
+}
+
+// intFloatWriter returns a function encoding an integer value, given as
+// sign and absolute value, as a float rounded only once: a conversion
+// through float64 would round twice values larger than 2^53.
+func intFloatWriter(dt DType) func([]byte, bool, uint64) {
+	switch dt {
+	case F8_E5M2:
+		return func(b []byte, neg bool, abs uint64) { b[0] = uint8(f8E5M2.fromInt(neg, abs)) }
+	case F8_E4M3:
+		return func(b []byte, neg bool, abs uint64) { b[0] = uint8(f8E4M3.fromInt(neg, abs)) }
+	case F16:
+		return func(b []byte, neg bool, abs uint64) { binary.LittleEndian.PutUint16(b, uint16(f16.fromInt(neg, abs))) }
+	case BF16:
+		return func(b []byte, neg bool, abs uint64) { binary.LittleEndian.PutUint16(b, uint16(bf16.fromInt(neg, abs))) }
+	case F32:
+		return func(b []byte, neg bool, abs uint64) {
+			f := float32(abs)
+			if neg {
+				f = -f
+			}
+			binary.LittleEndian.PutUint32(b, math.Float32bits(f))
+		}
+	default:
+		return func(b []byte, neg bool, abs uint64) {
+			binary.LittleEndian.PutUint64(b, math.Float64bits(intToFloat(neg, abs)))
+		}
+	}
+}
+
+// intReader returns a function decoding a BOOL or integer value as
+// sign and absolute value.
+func intReader(dt DType) func([]byte) (bool, uint64) {
+	size := int(dt.Size())
+	signed := dt.isSigned()
+	return func(b []byte) (bool, uint64) {
+		v := uint64(0)
+		for i := size - 1; i >= 0; i-- {
+			v = v<<8 | uint64(b[i])
+		}
+		if !signed || v>>(size*8-1) == 0 {
+			return false, v
+		}
+		// Negative value: sign-extend, then negate.
+		v |= ^uint64(0) << (size*8 - 1)
+		return true, -v
+	}
+}
+
+// writeInt encodes a BOOL or integer value, given as sign and absolute
+// value, saturating it to the range of the DType. It reports whether the
+// value was represented exactly.
+func writeInt(b []byte, dt DType, neg bool, abs uint64) bool {
+	if dt == BOOL {
+		b[0] = 0
+		if abs != 0 {
+			b[0] = 1
+		}
+		return !neg && abs <= 1
+	}
+
+	maxPos, maxNeg := intRange(dt)
+	exact := true
+	if neg && abs > maxNeg {
+		abs, exact = maxNeg, false
+	} else if !neg && abs > maxPos {
+		abs, exact = maxPos, false
+	}
+
+	v := abs
+	if neg {
+		v = -abs
+	}
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return exact
+}
+
+// intRange returns the largest absolute values representable by an
+// integer DType, for positive and negative values respectively.
+func intRange(dt DType) (uint64, uint64) {
+	bits := dt.Size() * 8
+	if dt.isSigned() {
+		return 1<<(bits-1) - 1, 1 << (bits - 1)
+	}
+	return 1<<bits - 1, 0
+}
+
+func intToFloat(neg bool, abs uint64) float64 {
+	if neg {
+		return -float64(abs)
+	}
+	return float64(abs)
+}
+
+// floatToInt truncates a float value toward zero, returning its sign and
+// absolute value, and reporting whether the conversion is exact.
+func floatToInt(f float64) (bool, uint64, bool) {
+	if math.IsNaN(f) {
+		return false, 0, false
+	}
+	t := math.Trunc(f)
+	exact := t == f
+	neg := t < 0
+	t = math.Abs(t)
+	if t >= 1<<64 {
+		return neg, math.MaxUint64, false
+	}
+	return neg, uint64(t), exact
+}
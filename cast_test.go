@@ -0,0 +1,213 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ View = CastView{}
+
+func TestCast(t *testing.T) {
+	t.Run("float to float", func(t *testing.T) {
+		src := newF32Tensor(t, 1, -2, 0.5, float32(math.Inf(1)))
+
+		testCases := []struct {
+			dType DType
+			want  []byte
+		}{
+			{F8_E5M2, []byte{0x3c, 0xc0, 0x38, 0x7c}},
+			{F8_E4M3, []byte{0x38, 0xc0, 0x30, 0x7e}},
+			{F16, []byte{0x00, 0x3c, 0x00, 0xc0, 0x00, 0x38, 0x00, 0x7c}},
+			{BF16, []byte{0x80, 0x3f, 0x00, 0xc0, 0x00, 0x3f, 0x80, 0x7f}},
+			{F32, src.Data()},
+			{F64, []byte{
+				0, 0, 0, 0, 0, 0, 0xf0, 0x3f,
+				0, 0, 0, 0, 0, 0, 0x00, 0xc0,
+				0, 0, 0, 0, 0, 0, 0xe0, 0x3f,
+				0, 0, 0, 0, 0, 0, 0xf0, 0x7f,
+			}},
+		}
+		for _, tc := range testCases {
+			cv, err := Cast(src, tc.dType, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.dType, cv.DType())
+			assert.Equal(t, src.Shape(), cv.Shape())
+			assert.Equal(t, uint64(len(tc.want)), cv.DataLen(), "DType %s", tc.dType)
+			assert.Equal(t, tc.want, cv.Data(), "DType %s", tc.dType)
+
+			if tc.dType != F8_E4M3 { // no infinity
+				back, err := Cast(cv, F32, false)
+				require.NoError(t, err)
+				assert.Equal(t, src.Data(), back.Data(), "DType %s", tc.dType)
+			}
+		}
+	})
+
+	t.Run("float64 rounding", func(t *testing.T) {
+		// Each value is just above a tie of the target DType, which would
+		// be rounded to even if the value were first rounded to float32.
+		testCases := []struct {
+			dType DType
+			value float64
+			want  []byte
+		}{
+			{F8_E5M2, 1 + 0x1p-3 + 0x1p-40, []byte{0x3d}},
+			{F8_E4M3, 1 + 0x1p-4 + 0x1p-40, []byte{0x39}},
+			{F16, 1 + 0x1p-11 + 0x1p-40, []byte{0x01, 0x3c}},
+			{BF16, 1 + 0x1p-8 + 0x1p-40, []byte{0x81, 0x3f}},
+		}
+		for _, tc := range testCases {
+			require.Equal(t, tc.value-0x1p-40, float64(float32(tc.value)))
+			data := binary.LittleEndian.AppendUint64(nil, math.Float64bits(tc.value))
+			src, err := NewTensorView(F64, []uint64{1}, data)
+			require.NoError(t, err)
+			cv, err := Cast(src, tc.dType, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, cv.Data(), "DType %s", tc.dType)
+		}
+	})
+
+	t.Run("integer to float", func(t *testing.T) {
+		src, err := NewTensorView(I16, []uint64{2}, []byte{0xfe, 0xff, 0x03, 0x00})
+		require.NoError(t, err)
+		cv, err := Cast(src, F32, false)
+		require.NoError(t, err)
+		assert.Equal(t, newF32Tensor(t, -2, 3).Data(), cv.Data())
+
+		cv, err = Cast(src, F16, false)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x00, 0xc0, 0x00, 0x42}, cv.Data())
+	})
+
+	t.Run("integer to float rounds once", func(t *testing.T) {
+		// Through float64, the first value would round to a tie, then to
+		// 2^60, as F32, and so would the second one as BF16.
+		src := newI64Tensor(t, 1<<60+1<<36+1, -(1<<60 + 1<<52 + 1))
+
+		cv, err := Cast(src, F32, false)
+		require.NoError(t, err)
+		assert.Equal(t, newF32Tensor(t, 1<<60+1<<37, -(1<<60+1<<52)).Data(), cv.Data())
+
+		cv, err = Cast(src, BF16, false)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x80, 0x5d, 0x81, 0xdd}, cv.Data())
+	})
+
+	t.Run("lossless integer narrowing", func(t *testing.T) {
+		src := newI64Tensor(t, -128, 0, 127)
+		cv, err := Cast(src, I8, false)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x80, 0x00, 0x7f}, cv.Data())
+	})
+
+	t.Run("lossy integer narrowing", func(t *testing.T) {
+		src := newI64Tensor(t, -1, 300, 2)
+
+		_, err := Cast(src, U8, false)
+		assert.EqualError(t, err, "cannot cast from I64 to U8 without loss: value at index 0 is not representable")
+
+		cv, err := Cast(src, U8, true)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0, 255, 2}, cv.Data())
+	})
+
+	t.Run("float to integer", func(t *testing.T) {
+		_, err := Cast(newF32Tensor(t, 1, 2.5), I32, false)
+		assert.EqualError(t, err, "cannot cast from F32 to I32 without loss: value at index 1 is not representable")
+
+		cv, err := Cast(newF32Tensor(t, 1, -2.5, 1e20, float32(math.NaN())), I16, true)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 0, 0xfe, 0xff, 0xff, 0x7f, 0, 0}, cv.Data())
+
+		cv, err = Cast(newF32Tensor(t, 1, -2), I64, false)
+		require.NoError(t, err)
+		assert.Equal(t, newI64Tensor(t, 1, -2).Data(), cv.Data())
+	})
+
+	t.Run("to BOOL", func(t *testing.T) {
+		_, err := Cast(newI64Tensor(t, 0, 1, -3), BOOL, false)
+		assert.EqualError(t, err, "cannot cast from I64 to BOOL without loss: value at index 2 is not representable")
+
+		cv, err := Cast(newI64Tensor(t, 0, 1, -3), BOOL, true)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0, 1, 1}, cv.Data())
+	})
+
+	t.Run("invalid DType", func(t *testing.T) {
+		_, err := Cast(newF32Tensor(t, 1), DType(200), false)
+		assert.EqualError(t, err, "invalid target DType 200")
+	})
+}
+
+func TestCastTensors(t *testing.T) {
+	data := map[string]TensorView{
+		"weight":   newF32Tensor(t, 1, 2),
+		"norm":     newF32Tensor(t, 3, 4),
+		"position": newI64Tensor(t, 0, 1),
+		"mask":     newI64Tensor(t, 0, 1),
+	}
+
+	casted, err := CastTensors(data, BF16, CastOptions{
+		Overrides: map[string]DType{
+			"norm": F32,
+			"mask": BOOL,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, BF16, casted["weight"].DType())
+	assert.Equal(t, F32, casted["norm"].DType())
+	assert.Equal(t, I64, casted["position"].DType())
+	assert.Equal(t, BOOL, casted["mask"].DType())
+
+	var buf bytes.Buffer
+	require.NoError(t, SerializeToWriter(casted, nil, &buf))
+	loaded, err := Deserialize(buf.Bytes())
+	require.NoError(t, err)
+
+	weight, ok := loaded.Tensor("weight")
+	require.True(t, ok)
+	assert.Equal(t, BF16, weight.DType())
+	values, err := Float32Values(weight)
+	require.NoError(t, err)
+	assert.Equal(t, []float32{1, 2}, values)
+
+	mask, ok := loaded.Tensor("mask")
+	require.True(t, ok)
+	assert.Equal(t, []byte{0, 1}, mask.Data())
+
+	data = map[string]TensorView{"x": newF32Tensor(t, 0.5)}
+	_, err = CastTensors(data, BF16, CastOptions{Overrides: map[string]DType{"x": I8}})
+	assert.EqualError(t, err, `failed to cast tensor "x": cannot cast from F32 to I8 without loss: value at index 0 is not representable`)
+}
+
+func newF32Tensor(t *testing.T, values ...float32) TensorView {
+	t.Helper()
+	data := make([]byte, 0, len(values)*4)
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+	}
+	tv, err := NewTensorView(F32, []uint64{uint64(len(values))}, data)
+	require.NoError(t, err)
+	return tv
+}
+
+func newI64Tensor(t *testing.T, values ...int64) TensorView {
+	t.Helper()
+	data := make([]byte, 0, len(values)*8)
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint64(data, uint64(v))
+	}
+	tv, err := NewTensorView(I64, []uint64{uint64(len(values))}, data)
+	require.NoError(t, err)
+	return tv
+}
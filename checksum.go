@@ -0,0 +1,150 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChecksumsMetadataKey is the __metadata__ key under which the tensors'
+// checksums are stored.
+//
+// Its value is a JSON object mapping each tensor name to the hex-encoded
+// SHA-256 digest of the tensor's data.
+const ChecksumsMetadataKey = "checksums.sha256"
+
+// SerializeOption is an option of Serialize, SerializeToWriter and
+// SerializeSharded.
+type SerializeOption func(*serializeOptions)
+
+type serializeOptions struct {
+	checksums bool
+}
+
+// WithChecksums computes the checksum of each tensor, storing them in
+// __metadata__ under ChecksumsMetadataKey. The checksums can be verified
+// with Verify, or when parsing a file with ParseOptions.VerifyChecksums.
+//
+// Since the header is written first, the data of each tensor is read
+// twice: once for computing the checksums, in parallel, and once for
+// writing it.
+func WithChecksums() SerializeOption {
+	return func(o *serializeOptions) { o.checksums = true }
+}
+
+// applySerializeOptions returns the metadata to be written, according to
+// the options.
+func applySerializeOptions[V View](data map[string]V, dataInfo map[string]string, opts []SerializeOption) (map[string]string, error) {
+	var o serializeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.checksums {
+		return dataInfo, nil
+	}
+
+	checksums := computeChecksums(data)
+	encoded, err := json.Marshal(checksums)
+	if err != nil {
+		return nil, fmt.Errorf("failed to JSON-marshal checksums: %w", err)
+	}
+	result := make(map[string]string, len(dataInfo)+1)
+	for k, v := range dataInfo {
+		result[k] = v
+	}
+	result[ChecksumsMetadataKey] = string(encoded)
+	return result, nil
+}
+
+// computeChecksums computes the checksums of all tensors in parallel.
+func computeChecksums[V View](data map[string]V) map[string]string {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sums := make([]string, len(names))
+	parallelEach(len(names), func(i int) {
+		sums[i] = checksum(data[names[i]].Data())
+	})
+	checksums := make(map[string]string, len(names))
+	for i, name := range names {
+		checksums[name] = sums[i]
+	}
+	return checksums
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify recomputes the checksums of all tensors, in parallel, comparing
+// them with the ones stored in __metadata__ (see WithChecksums).
+//
+// If any tensor is corrupt or has no checksum, a *ChecksumError is
+// returned. Checksums of tensors which are not in st are ignored.
+func Verify(st SafeTensors) error {
+	checksums, err := readChecksums(st.metadata)
+	if err != nil {
+		return err
+	}
+	tensors := st.Tensors()
+	results := make([]error, len(tensors))
+	parallelEach(len(tensors), func(i int) {
+		results[i] = verifyTensor(checksums, tensors[i].Name, tensors[i].TensorView.Data())
+	})
+	return joinChecksumErrors(results)
+}
+
+// readChecksums decodes the checksums stored in the metadata.
+func readChecksums(m Metadata) (map[string]string, error) {
+	encoded, ok := m.metadata[ChecksumsMetadataKey]
+	if !ok {
+		return nil, ErrNoChecksums
+	}
+	var checksums map[string]string
+	if err := json.Unmarshal([]byte(encoded), &checksums); err != nil {
+		return nil, fmt.Errorf("invalid %q metadata: %w", ChecksumsMetadataKey, err)
+	}
+	return checksums, nil
+}
+
+// verifyTensor verifies the checksum of the data of a single tensor,
+// returning a *ChecksumError in case of failure.
+func verifyTensor(checksums map[string]string, name string, data []byte) error {
+	expected, ok := checksums[name]
+	if !ok {
+		return &ChecksumError{Unchecked: []string{name}}
+	}
+	if checksum(data) != strings.ToLower(expected) {
+		return &ChecksumError{Corrupt: []string{name}}
+	}
+	return nil
+}
+
+// joinChecksumErrors merges the results of verifyTensor in a single
+// *ChecksumError, or returns nil if there are no errors.
+func joinChecksumErrors(results []error) error {
+	var joined ChecksumError
+	for _, err := range results {
+		var ce *ChecksumError
+		if errors.As(err, &ce) {
+			joined.Corrupt = append(joined.Corrupt, ce.Corrupt...)
+			joined.Unchecked = append(joined.Unchecked, ce.Unchecked...)
+		}
+	}
+	if len(joined.Corrupt) == 0 && len(joined.Unchecked) == 0 {
+		return nil
+	}
+	sort.Strings(joined.Corrupt)
+	sort.Strings(joined.Unchecked)
+	return &joined
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksums(t *testing.T) {
+	tensors := map[string]TensorView{
+		"a": newArangeU8(t, 2, 3),
+		"b": newArangeU8(t, 4),
+		"c": newArangeU8(t, 1),
+	}
+	serialized, err := Serialize(tensors, map[string]string{"foo": "bar"}, WithChecksums())
+	require.NoError(t, err)
+
+	// corrupt flips one byte of the data of the named tensor.
+	corrupt := func(t *testing.T, buf []byte, name string) []byte {
+		st, err := Deserialize(buf)
+		require.NoError(t, err)
+		info := st.Metadata().Tensors()[name]
+		out := append([]byte(nil), buf...)
+		out[len(buf)-len(st.data)+int(info.DataOffsets[0])] ^= 0xff
+		return out
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		st, err := Deserialize(serialized)
+		require.NoError(t, err)
+		metadata := st.Metadata().Metadata()
+		assert.Equal(t, "bar", metadata["foo"])
+
+		var checksums map[string]string
+		require.NoError(t, json.Unmarshal([]byte(metadata[ChecksumsMetadataKey]), &checksums))
+		assert.Equal(t, map[string]string{
+			"a": "17e88db187afd62c16e5debf3e6527cd006bc012bc90b51a810cd80c2d511f43",
+			"b": "054edec1d0211f624fed0cbca9d4f9400b0e491c43742af2c5b0abebf0c990d8",
+			"c": "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		}, checksums)
+		assert.NoError(t, Verify(st))
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, SerializeToWriter(tensors, map[string]string{"foo": "bar"}, &buf, WithChecksums()))
+		assert.Equal(t, serialized, buf.Bytes())
+	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		buf := corrupt(t, corrupt(t, serialized, "c"), "a")
+
+		st, err := Deserialize(buf)
+		require.NoError(t, err)
+		err = Verify(st)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+		var ce *ChecksumError
+		require.ErrorAs(t, err, &ce)
+		assert.Equal(t, []string{"a", "c"}, ce.Corrupt)
+		assert.Empty(t, ce.Unchecked)
+		assert.EqualError(t, err, `checksum mismatch: corrupt tensors ["a" "c"]`)
+
+		_, err = ParseOptions{VerifyChecksums: true}.Deserialize(buf)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		buf := corrupt(t, serialized, "b")
+		r, err := ParseOptions{VerifyChecksums: true}.NewReader(bytes.NewReader(buf), int64(len(buf)))
+		require.NoError(t, err)
+
+		_, err = r.Tensor("a")
+		assert.NoError(t, err)
+		_, err = r.Tensor("b")
+		assert.EqualError(t, err, `checksum mismatch: corrupt tensors ["b"]`)
+	})
+
+	t.Run("missing checksums", func(t *testing.T) {
+		plain, err := Serialize(tensors, nil)
+		require.NoError(t, err)
+
+		_, err = ParseOptions{VerifyChecksums: true}.Deserialize(plain)
+		assert.ErrorIs(t, err, ErrNoChecksums)
+		_, err = ParseOptions{VerifyChecksums: true}.NewReader(bytes.NewReader(plain), int64(len(plain)))
+		assert.ErrorIs(t, err, ErrNoChecksums)
+	})
+
+	t.Run("unchecked tensor", func(t *testing.T) {
+		metadata := map[string]string{ChecksumsMetadataKey: `{"a":"` + checksum(tensors["a"].Data()) + `"}`}
+		buf, err := Serialize(tensors, metadata)
+		require.NoError(t, err)
+		st, err := Deserialize(buf)
+		require.NoError(t, err)
+
+		err = Verify(st)
+		assert.EqualError(t, err, `checksum mismatch: tensors without checksum ["b" "c"]`)
+	})
+}
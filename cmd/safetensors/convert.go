@@ -0,0 +1,121 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors/gguf"
+	"github.com/nlpodyssey/safetensors/numpy"
+	"github.com/nlpodyssey/safetensors/pytorch"
+)
+
+// converters convert a file of another format to safetensors, indexed by
+// format name.
+var converters = map[string]func(src, dst string) error{
+	"gguf":    gguf.ConvertFile,
+	"numpy":   numpy.ConvertFile,
+	"pytorch": pytorch.ConvertFile,
+}
+
+// exporters convert a safetensors file to another format, indexed by
+// format name.
+var exporters = map[string]func(src, dst string) error{
+	"gguf":  gguf.ExportFile,
+	"numpy": numpy.ExportFile,
+}
+
+// formatExtensions maps file extensions to format names, for detecting
+// the format of input and output files.
+var formatExtensions = map[string]string{
+	".bin":  "pytorch",
+	".gguf": "gguf",
+	".npz":  "numpy",
+	".pt":   "pytorch",
+	".pth":  "pytorch",
+}
+
+func runConvert(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "input `format`: "+strings.Join(formatNames(converters), ", ")+
+		" (default: detected from the file extension)")
+	to := fs.String("to", "", "output `format`: "+strings.Join(formatNames(exporters), ", ")+
+		" (default: detected from the file extension)")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors convert [flags] <input> <output>\n\n"+
+			"Convert converts a file of another format to safetensors, or a\n"+
+			"safetensors file to another format.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	src, dst := fs.Arg(0), fs.Arg(1)
+
+	convert, err := resolveConverter(src, dst, *from, *to)
+	if err != nil {
+		return fail(stderr, "convert", err)
+	}
+	if err = convert(src, dst); err != nil {
+		return fail(stderr, "convert", err)
+	}
+	return 0
+}
+
+// resolveConverter returns the function converting src to dst. Unless the
+// output format is given, the conversion is from the input format to
+// safetensors, except when the extension of dst denotes a format which
+// can be exported.
+func resolveConverter(src, dst, from, to string) (func(src, dst string) error, error) {
+	if from != "" && to != "" {
+		return nil, errors.New("-from and -to cannot be used together")
+	}
+	if format := detectFormat(dst); from == "" && to == "" && exporters[format] != nil {
+		to = format
+	}
+	if to != "" {
+		return lookupFormat(exporters, "output format", to)
+	}
+	if from == "" {
+		if from = detectFormat(src); from == "" {
+			return nil, fmt.Errorf("cannot detect the format of %q: use -from", src)
+		}
+	}
+	return lookupFormat(converters, "format", from)
+}
+
+func lookupFormat(m map[string]func(src, dst string) error, kind, format string) (func(src, dst string) error, error) {
+	convert, ok := m[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid %s %q", kind, format)
+	}
+	return convert, nil
+}
+
+// detectFormat returns the format denoted by the extension of name, or
+// the empty string if unknown.
+func detectFormat(name string) string {
+	return formatExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+func formatNames(m map[string]func(src, dst string) error) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
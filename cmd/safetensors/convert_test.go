@@ -0,0 +1,130 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	// torch.save({"a": torch.tensor([1, 2], dtype=torch.float32)})
+	pkl := "\x80\x02}X\x01\x00\x00\x00a" +
+		"ctorch._utils\n_rebuild_tensor_v2\n(" +
+		"(X\x07\x00\x00\x00storagectorch\nFloatStorage\nX\x01\x00\x00\x000X\x03\x00\x00\x00cpuK\x02tQ" +
+		"K\x00K\x02\x85K\x01\x85\x89ccollections\nOrderedDict\n)RtRs."
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string]string{
+		"archive/data.pkl": pkl,
+		"archive/data/0":   "\x00\x00\x80\x3f\x00\x00\x00\x40",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pytorch_model.bin")
+	require.NoError(t, os.WriteFile(src, buf.Bytes(), 0o600))
+
+	t.Run("pytorch", func(t *testing.T) {
+		dst := filepath.Join(dir, "model.safetensors")
+		code, _, stderr := runCommand("convert", src, dst)
+		require.Equal(t, 0, code, stderr)
+
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		tv, ok := f.Tensor("a")
+		require.True(t, ok)
+		assert.Equal(t, safetensors.F32, tv.DType())
+		assert.Equal(t, []uint64{2}, tv.Shape())
+		values, err := safetensors.Values[float32](tv)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{1, 2}, values)
+	})
+
+	t.Run("explicit format", func(t *testing.T) {
+		other := filepath.Join(dir, "checkpoint")
+		require.NoError(t, os.WriteFile(other, buf.Bytes(), 0o600))
+
+		code, _, stderr := runCommand("convert", other, filepath.Join(dir, "a.safetensors"))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "cannot detect the format")
+
+		code, _, stderr = runCommand("convert", "-from", "pytorch", other, filepath.Join(dir, "b.safetensors"))
+		assert.Equal(t, 0, code, stderr)
+
+		code, _, stderr = runCommand("convert", "-from", "foo", other, filepath.Join(dir, "c.safetensors"))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `invalid format "foo"`)
+	})
+}
+
+func TestConvertNumPy(t *testing.T) {
+	dir := t.TempDir()
+	st := filepath.Join(dir, "model.safetensors")
+	tv, err := safetensors.NewTensorView(safetensors.U8, []uint64{2}, []byte{1, 2})
+	require.NoError(t, err)
+	data, err := safetensors.Serialize(map[string]safetensors.TensorView{"a": tv}, nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(st, data, 0o600))
+
+	npz := filepath.Join(dir, "arrays.npz")
+	code, _, stderr := runCommand("convert", st, npz)
+	require.Equal(t, 0, code, stderr)
+
+	back := filepath.Join(dir, "back.safetensors")
+	code, _, stderr = runCommand("convert", npz, back)
+	require.Equal(t, 0, code, stderr)
+	f, err := safetensors.Open(back)
+	require.NoError(t, err)
+	defer f.Close()
+	actual, ok := f.Tensor("a")
+	require.True(t, ok)
+	assert.Equal(t, tv, actual)
+
+	code, _, stderr = runCommand("convert", "-to", "numpy", st, filepath.Join(dir, "arrays"))
+	assert.Equal(t, 0, code, stderr)
+
+	code, _, stderr = runCommand("convert", "-to", "pytorch", st, filepath.Join(dir, "model.bin"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `invalid output format "pytorch"`)
+
+	code, _, stderr = runCommand("convert", "-from", "numpy", "-to", "numpy", npz, st)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "cannot be used together")
+}
+
+func TestConvertGGUF(t *testing.T) {
+	dir := t.TempDir()
+	st := filepath.Join(dir, "model.safetensors")
+	tv, err := safetensors.NewTensorView(safetensors.F16, []uint64{2, 1}, []byte{0, 0x3c, 0, 0x40})
+	require.NoError(t, err)
+	data, err := safetensors.Serialize(map[string]safetensors.TensorView{"a": tv}, map[string]string{"general.name": "test"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(st, data, 0o600))
+
+	gguf := filepath.Join(dir, "model.gguf")
+	code, _, stderr := runCommand("convert", st, gguf)
+	require.Equal(t, 0, code, stderr)
+
+	back := filepath.Join(dir, "back.safetensors")
+	code, _, stderr = runCommand("convert", gguf, back)
+	require.Equal(t, 0, code, stderr)
+	actual, err := os.ReadFile(back)
+	require.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
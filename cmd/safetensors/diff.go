@@ -0,0 +1,88 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nlpodyssey/safetensors"
+)
+
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts safetensors.CompareOptions
+	fs.Float64Var(&opts.RTol, "rtol", 0, "relative tolerance for floating point values")
+	fs.Float64Var(&opts.ATol, "atol", 0, "absolute tolerance for floating point values")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors diff [flags] <file1> <file2>\n\n"+
+			"Diff compares two safetensors files, printing their differences.\n"+
+			"The exit code is 0 if the files are equal, 1 if they differ, 2 on errors.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	a, err := safetensors.Open(fs.Arg(0))
+	if err != nil {
+		fail(stderr, "diff", err)
+		return 2
+	}
+	defer a.Close()
+	b, err := safetensors.Open(fs.Arg(1))
+	if err != nil {
+		fail(stderr, "diff", err)
+		return 2
+	}
+	defer b.Close()
+
+	c := safetensors.Compare(a.SafeTensors, b.SafeTensors, opts)
+	printComparison(stdout, c)
+	if !c.Equal() {
+		return 1
+	}
+	return 0
+}
+
+func printComparison(w io.Writer, c safetensors.Comparison) {
+	for _, name := range c.OnlyInA {
+		fmt.Fprintf(w, "only in first file: tensor %q\n", name)
+	}
+	for _, name := range c.OnlyInB {
+		fmt.Fprintf(w, "only in second file: tensor %q\n", name)
+	}
+	for _, d := range c.Metadata {
+		fmt.Fprintf(w, "metadata %q: %s != %s\n", d.Key, formatMetadataValue(d.A, d.InA), formatMetadataValue(d.B, d.InB))
+	}
+	for _, tc := range c.Tensors {
+		printTensorComparison(w, tc)
+	}
+}
+
+func printTensorComparison(w io.Writer, tc safetensors.TensorComparison) {
+	switch {
+	case tc.DTypeA != tc.DTypeB:
+		fmt.Fprintf(w, "tensor %q: dtype %s != %s\n", tc.Name, tc.DTypeA, tc.DTypeB)
+	case !tc.Equal() && tc.Mismatches == 0:
+		fmt.Fprintf(w, "tensor %q: shape %s != %s\n", tc.Name, formatShape(tc.ShapeA), formatShape(tc.ShapeB))
+	case tc.Mismatches > 0:
+		fmt.Fprintf(w, "tensor %q: %d elements differ, first at index %d, max abs diff %g\n",
+			tc.Name, tc.Mismatches, tc.FirstMismatch, tc.MaxAbsDiff)
+	}
+}
+
+func formatMetadataValue(v string, ok bool) string {
+	if !ok {
+		return "(missing)"
+	}
+	return fmt.Sprintf("%q", v)
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	f32 := func(values ...float32) []byte {
+		data := make([]byte, 0, len(values)*4)
+		for _, v := range values {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+		return data
+	}
+
+	a := writeTestFile(t, map[string]safetensors.TensorView{
+		"w":   newTensor(t, safetensors.F32, []uint64{3}, f32(1, 2, 3)),
+		"b":   newTensor(t, safetensors.F32, []uint64{1}, f32(1)),
+		"old": newTensor(t, safetensors.U8, []uint64{1}, []byte{1}),
+	}, map[string]string{"format": "pt"})
+	b := writeTestFile(t, map[string]safetensors.TensorView{
+		"w":   newTensor(t, safetensors.F32, []uint64{3}, f32(1, 2.001, 3.5)),
+		"b":   newTensor(t, safetensors.F16, []uint64{1}, []byte{0, 0x3c}),
+		"new": newTensor(t, safetensors.U8, []uint64{1}, []byte{1}),
+	}, nil)
+
+	t.Run("differences", func(t *testing.T) {
+		code, stdout, stderr := runCommand("diff", a, b)
+		assert.Equal(t, 1, code, stderr)
+		assert.Equal(t, ""+
+			"only in first file: tensor \"old\"\n"+
+			"only in second file: tensor \"new\"\n"+
+			"metadata \"format\": \"pt\" != (missing)\n"+
+			"tensor \"b\": dtype F32 != F16\n"+
+			"tensor \"w\": 2 elements differ, first at index 1, max abs diff 0.5\n", stdout)
+	})
+
+	t.Run("tolerance", func(t *testing.T) {
+		code, stdout, _ := runCommand("diff", "-atol", "0.01", a, b)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stdout, "tensor \"w\": 1 elements differ, first at index 2, max abs diff 0.5\n")
+	})
+
+	t.Run("equal", func(t *testing.T) {
+		code, stdout, stderr := runCommand("diff", a, a)
+		assert.Equal(t, 0, code, stderr)
+		assert.Empty(t, stdout)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		code, _, stderr := runCommand("diff", a)
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: safetensors diff")
+
+		code, _, stderr = runCommand("diff", a, b+".missing")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "no such file or directory")
+	})
+}
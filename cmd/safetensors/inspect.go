@@ -0,0 +1,207 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nlpodyssey/safetensors"
+)
+
+func runInspect(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "table", "output format: table, json or names")
+	filter := fs.String("filter", "*", "only show tensors whose name matches the glob `pattern`")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors inspect [flags] <file>\n\n"+
+			"Inspect prints the header of a safetensors file, without reading the tensors' data.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if _, err := path.Match(*filter, ""); err != nil {
+		return fail(stderr, "inspect", fmt.Errorf("invalid filter %q: %w", *filter, err))
+	}
+
+	h, err := readHeader(fs.Arg(0), *filter)
+	if err != nil {
+		return fail(stderr, "inspect", err)
+	}
+
+	switch *format {
+	case "table":
+		err = h.writeTable(stdout)
+	case "json":
+		err = h.writeJSON(stdout)
+	case "names":
+		err = h.writeNames(stdout)
+	default:
+		return fail(stderr, "inspect", fmt.Errorf("invalid format %q", *format))
+	}
+	if err != nil {
+		return fail(stderr, "inspect", err)
+	}
+	return 0
+}
+
+// header is the inspected content of a file's header.
+type header struct {
+	Metadata map[string]string `json:"metadata"`
+	Tensors  []tensorEntry     `json:"tensors"`
+	Totals   []dTypeTotal      `json:"totals"`
+}
+
+type tensorEntry struct {
+	Name        string            `json:"name"`
+	DType       safetensors.DType `json:"dtype"`
+	Shape       []uint64          `json:"shape"`
+	Elements    uint64            `json:"elements"`
+	Bytes       uint64            `json:"bytes"`
+	DataOffsets [2]uint64         `json:"data_offsets"`
+}
+
+type dTypeTotal struct {
+	DType    safetensors.DType `json:"dtype"`
+	Tensors  int               `json:"tensors"`
+	Elements uint64            `json:"elements"`
+	Bytes    uint64            `json:"bytes"`
+}
+
+// readHeader reads the header of the named file, only including the
+// tensors whose name matches the pattern.
+func readHeader(name, pattern string) (header, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return header{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return header{}, err
+	}
+	r, err := safetensors.NewReader(f, fi.Size())
+	if err != nil {
+		return header{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	md := r.Metadata()
+	infos := md.Tensors()
+	h := header{Metadata: md.Metadata(), Tensors: []tensorEntry{}}
+	if h.Metadata == nil {
+		h.Metadata = map[string]string{}
+	}
+	totals := make(map[safetensors.DType]*dTypeTotal)
+
+	for _, tensorName := range r.Names() {
+		if ok, _ := path.Match(pattern, tensorName); !ok {
+			continue
+		}
+		info := infos[tensorName]
+		e := newTensorEntry(tensorName, info)
+		h.Tensors = append(h.Tensors, e)
+
+		t, ok := totals[info.DType]
+		if !ok {
+			t = &dTypeTotal{DType: info.DType}
+			totals[info.DType] = t
+		}
+		t.Tensors++
+		t.Elements += e.Elements
+		t.Bytes += e.Bytes
+	}
+
+	h.Totals = make([]dTypeTotal, 0, len(totals))
+	for _, t := range totals {
+		h.Totals = append(h.Totals, *t)
+	}
+	sort.Slice(h.Totals, func(i, j int) bool { return h.Totals[i].DType < h.Totals[j].DType })
+	return h, nil
+}
+
+func newTensorEntry(name string, info *safetensors.TensorInfo) tensorEntry {
+	bytes := info.DataOffsets[1] - info.DataOffsets[0]
+	return tensorEntry{
+		Name:        name,
+		DType:       info.DType,
+		Shape:       info.Shape,
+		Elements:    bytes / info.DType.Size(),
+		Bytes:       bytes,
+		DataOffsets: info.DataOffsets,
+	}
+}
+
+func (h header) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if len(h.Metadata) > 0 {
+		fmt.Fprintln(tw, "METADATA\tVALUE")
+		keys := make([]string, 0, len(h.Metadata))
+		for k := range h.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", k, h.Metadata[k])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintln(tw, "NAME\tDTYPE\tSHAPE\tELEMENTS\tBYTES\tOFFSETS")
+	for _, e := range h.Tensors {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d-%d\n", e.Name, e.DType, formatShape(e.Shape),
+			e.Elements, e.Bytes, e.DataOffsets[0], e.DataOffsets[1])
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "DTYPE\tTENSORS\tELEMENTS\tBYTES")
+	var total dTypeTotal
+	for _, t := range h.Totals {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", t.DType, t.Tensors, t.Elements, t.Bytes)
+		total.Tensors += t.Tensors
+		total.Elements += t.Elements
+		total.Bytes += t.Bytes
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\n", total.Tensors, total.Elements, total.Bytes)
+
+	return tw.Flush()
+}
+
+func (h header) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
+
+func (h header) writeNames(w io.Writer) error {
+	for _, e := range h.Tensors {
+		if _, err := fmt.Fprintln(w, e.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatShape(shape []uint64) string {
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = fmt.Sprint(d)
+	}
+	return "[" + strings.Join(dims, ", ") + "]"
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	name := writeTestFile(t, map[string]safetensors.TensorView{
+		"h.0.attn.weight": newTensor(t, safetensors.F32, []uint64{2, 2}, make([]byte, 16)),
+		"h.0.attn.bias":   newTensor(t, safetensors.F32, []uint64{2}, make([]byte, 8)),
+		"h.0.mlp.weight":  newTensor(t, safetensors.F16, []uint64{3}, make([]byte, 6)),
+	}, map[string]string{"format": "pt"})
+
+	t.Run("table", func(t *testing.T) {
+		code, stdout, stderr := runCommand("inspect", name)
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, ""+
+			"METADATA  VALUE\n"+
+			"format    pt\n"+
+			"\n"+
+			"NAME             DTYPE  SHAPE   ELEMENTS  BYTES  OFFSETS\n"+
+			"h.0.attn.bias    F32    [2]     2         8      0-8\n"+
+			"h.0.attn.weight  F32    [2, 2]  4         16     8-24\n"+
+			"h.0.mlp.weight   F16    [3]     3         6      24-30\n"+
+			"\n"+
+			"DTYPE  TENSORS  ELEMENTS  BYTES\n"+
+			"F16    1        3         6\n"+
+			"F32    2        6         24\n"+
+			"TOTAL  3        9         30\n", stdout)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		code, stdout, stderr := runCommand("inspect", "-format", "json", "-filter", "*.mlp.*", name)
+		assert.Equal(t, 0, code, stderr)
+		assert.JSONEq(t, `{
+			"metadata": {"format": "pt"},
+			"tensors": [
+				{"name": "h.0.mlp.weight", "dtype": "F16", "shape": [3], "elements": 3, "bytes": 6, "data_offsets": [24, 30]}
+			],
+			"totals": [
+				{"dtype": "F16", "tensors": 1, "elements": 3, "bytes": 6}
+			]
+		}`, stdout)
+	})
+
+	t.Run("names", func(t *testing.T) {
+		code, stdout, stderr := runCommand("inspect", "-format", "names", "-filter", "h.0.attn.*", name)
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "h.0.attn.bias\nh.0.attn.weight\n", stdout)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		code, _, stderr := runCommand("inspect", "-format", "xml", name)
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "safetensors inspect: invalid format \"xml\"\n", stderr)
+
+		code, _, stderr = runCommand("inspect", "-filter", "[", name)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `invalid filter "["`)
+
+		code, _, stderr = runCommand("inspect")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: safetensors inspect")
+
+		code, _, stderr = runCommand("inspect", name+".missing")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "no such file or directory")
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command safetensors inspects and manipulates safetensors files.
+//
+// Usage:
+//
+//	safetensors <command> [arguments]
+//
+// The commands are:
+//
+//	convert    convert between safetensors and other formats
+//	diff       compare two files
+//	inspect    print the header of a file
+//	merge      merge several files into one
+//	meta       print or edit the metadata of a file
+//	rename     rename the tensors of a file with regular expressions
+//	serve      serve the tensors of a file over HTTP
+//
+// Use "safetensors <command> -h" for more information about a command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a subcommand of the safetensors tool.
+type command struct {
+	// short is a one-line description of the command.
+	short string
+	// run executes the command with the given arguments, returning the
+	// process exit code.
+	run func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"convert": {short: "convert between safetensors and other formats", run: runConvert},
+	"diff":    {short: "compare two files", run: runDiff},
+	"inspect": {short: "print the header of a file", run: runInspect},
+	"merge":   {short: "merge several files into one", run: runMerge},
+	"meta":    {short: "print or edit the metadata of a file", run: runMeta},
+	"rename":  {short: "rename the tensors of a file with regular expressions", run: runRename},
+	"serve":   {short: "serve the tensors of a file over HTTP", run: runServe},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "safetensors: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, "Usage:\n\n\tsafetensors <command> [arguments]\n\nThe commands are:\n\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%-10s %s\n", name, commands[name].short)
+	}
+	fmt.Fprint(w, "\nUse \"safetensors <command> -h\" for more information about a command.\n")
+}
+
+// fail prints an error message for the named command, returning the
+// process exit code for failures.
+func fail(stderr io.Writer, name string, err error) int {
+	fmt.Fprintf(stderr, "safetensors %s: %v\n", name, err)
+	return 1
+}
+
+// checkNotSameFile reports an error if the output file is the input file,
+// which must not be overwritten while being read.
+func checkNotSameFile(input, output string) error {
+	fi, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+	if fo, err := os.Stat(output); err == nil && os.SameFile(fi, fo) {
+		return fmt.Errorf("output file %s is also an input", output)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("no command", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, run(nil, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "Usage:")
+		assert.Contains(t, stderr.String(), "inspect")
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, run([]string{"foo"}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), `safetensors: unknown command "foo"`)
+	})
+}
+
+// runCommand runs the tool with the given arguments, returning the exit
+// code, stdout and stderr.
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeTestFile serializes the tensors to a new file, returning its name.
+func writeTestFile(t *testing.T, tensors map[string]safetensors.TensorView, metadata map[string]string) string {
+	t.Helper()
+	out, err := safetensors.Serialize(tensors, metadata)
+	require.NoError(t, err)
+	name := filepath.Join(t.TempDir(), "test.safetensors")
+	require.NoError(t, os.WriteFile(name, out, 0o600))
+	return name
+}
+
+func newTensor(t *testing.T, dType safetensors.DType, shape []uint64, data []byte) safetensors.TensorView {
+	t.Helper()
+	tv, err := safetensors.NewTensorView(dType, shape, data)
+	require.NoError(t, err)
+	return tv
+}
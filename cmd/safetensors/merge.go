@@ -0,0 +1,105 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// conflictPolicies are the values of the -conflict flag of merge.
+var conflictPolicies = map[string]safetensors.ConflictPolicy{
+	safetensors.ConflictError.String():     safetensors.ConflictError,
+	safetensors.ConflictFirstWins.String(): safetensors.ConflictFirstWins,
+	safetensors.ConflictLastWins.String():  safetensors.ConflictLastWins,
+	safetensors.ConflictIdentical.String(): safetensors.ConflictIdentical,
+}
+
+// patternsFlag is a flag which can be repeated, collecting its values.
+type patternsFlag []string
+
+func (p *patternsFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternsFlag) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+func runMerge(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts safetensors.MergeOptions
+	output := fs.String("o", "", "write the merged tensors to `file` (required)")
+	fs.Var((*patternsFlag)(&opts.Include), "include", "only merge tensors whose name matches the glob `pattern` (repeatable)")
+	fs.Var((*patternsFlag)(&opts.Exclude), "exclude", "do not merge tensors whose name matches the glob `pattern` (repeatable)")
+	conflict := fs.String("conflict", "error", "`policy` for duplicate names: error, first-wins, last-wins or identical")
+	fs.BoolVar(&opts.Checksums, "checksums", false, "add the checksums of the merged tensors to the metadata")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors merge [flags] -o <output> [prefix=]<input>...\n\n"+
+			"Merge writes the tensors of several files to a single file, merging their metadata.\n"+
+			"The names of the tensors of an input can be given a prefix, such as vision.=clip.safetensors.\n"+
+			"An input naming an existing file is never split at '=', even if it contains one.\n"+
+			"Patterns are matched against prefixed names.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	policy, ok := conflictPolicies[*conflict]
+	if *output == "" || fs.NArg() == 0 || !ok {
+		fs.Usage()
+		return 2
+	}
+	opts.Conflict = policy
+
+	if err := mergeFiles(*output, fs.Args(), opts); err != nil {
+		return fail(stderr, "merge", err)
+	}
+	return 0
+}
+
+// mergeFiles merges the inputs, given as [prefix=]file, to the named
+// output file.
+func mergeFiles(output string, args []string, opts safetensors.MergeOptions) error {
+	inputs := make([]safetensors.MergeInput, len(args))
+	for i, arg := range args {
+		prefix, name := splitInput(arg)
+		inputs[i].Prefix = prefix
+		if err := checkNotSameFile(name, output); err != nil {
+			return err
+		}
+		f, err := safetensors.Open(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer f.Close()
+		inputs[i].SafeTensors = f.SafeTensors
+	}
+
+	return tensorio.CreateFile(output, func(w io.Writer) error {
+		return safetensors.Merge(w, inputs, opts)
+	})
+}
+
+// splitInput splits an input argument, given as [prefix=]file, into its
+// prefix and file name. An argument naming an existing file is a file
+// name, even if it contains '='.
+func splitInput(arg string) (prefix, name string) {
+	if _, err := os.Stat(arg); err == nil {
+		return "", arg
+	}
+	if prefix, name, ok := strings.Cut(arg, "="); ok {
+		return prefix, name
+	}
+	return "", arg
+}
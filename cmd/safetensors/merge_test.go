@@ -0,0 +1,85 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	base := writeTestFile(t, map[string]safetensors.TensorView{
+		"embed": newTensor(t, safetensors.U8, []uint64{2}, []byte{1, 2}),
+		"head":  newTensor(t, safetensors.U8, []uint64{1}, []byte{3}),
+	}, map[string]string{"format": "pt"})
+	extra := writeTestFile(t, map[string]safetensors.TensorView{
+		"head": newTensor(t, safetensors.U8, []uint64{1}, []byte{4}),
+	}, map[string]string{"format": "pt"})
+	dst := filepath.Join(t.TempDir(), "merged.safetensors")
+
+	t.Run("prefix", func(t *testing.T) {
+		code, _, stderr := runCommand("merge", "-o", dst, base, "extra.="+extra)
+		assert.Equal(t, 0, code, stderr)
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		assert.ElementsMatch(t, []string{"embed", "head", "extra.head"}, f.Names())
+		assert.Equal(t, map[string]string{"format": "pt"}, f.Metadata().Metadata())
+	})
+
+	t.Run("file name with equals sign", func(t *testing.T) {
+		named := filepath.Join(t.TempDir(), "a=b.safetensors")
+		data, err := os.ReadFile(extra)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(named, data, 0o644))
+
+		code, _, stderr := runCommand("merge", "-o", dst, base, "extra.="+named)
+		assert.Equal(t, 0, code, stderr)
+		code, _, stderr = runCommand("merge", "-o", dst, "-conflict", "last-wins", base, named)
+		assert.Equal(t, 0, code, stderr)
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		assert.ElementsMatch(t, []string{"embed", "head"}, f.Names())
+	})
+
+	t.Run("policy and filters", func(t *testing.T) {
+		code, _, stderr := runCommand("merge", "-o", dst, "-conflict", "last-wins", "-exclude", "emb*", "-checksums", base, extra)
+		assert.Equal(t, 0, code, stderr)
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		assert.Equal(t, []string{"head"}, f.Names())
+		tv, _ := f.Tensor("head")
+		assert.Equal(t, []byte{4}, tv.Data())
+		assert.NoError(t, safetensors.Verify(f.SafeTensors))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		code, _, stderr := runCommand("merge", base)
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: safetensors merge")
+
+		code, _, _ = runCommand("merge", "-o", dst, "-conflict", "random", base)
+		assert.Equal(t, 2, code)
+
+		failed := filepath.Join(t.TempDir(), "failed.safetensors")
+		code, _, stderr = runCommand("merge", "-o", failed, base, extra)
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "safetensors merge: merge conflict: tensor \"head\" in inputs 0 and 1\n", stderr)
+		assert.NoFileExists(t, failed)
+
+		code, _, stderr = runCommand("merge", "-o", base, base)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "is also an input")
+		_, err := os.Stat(base)
+		assert.NoError(t, err)
+	})
+}
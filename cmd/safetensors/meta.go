@@ -0,0 +1,130 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+)
+
+// metaOps are the operations of the meta command. Each one receives the
+// file name, its current metadata, and the remaining arguments.
+var metaOps = map[string]struct {
+	minArgs int
+	run     func(stdout io.Writer, name string, metadata map[string]string, args []string) error
+}{
+	"get":    {minArgs: 0, run: metaGet},
+	"set":    {minArgs: 1, run: metaSet},
+	"delete": {minArgs: 1, run: metaDelete},
+}
+
+func runMeta(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("meta", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage:\n\n"+
+			"\tsafetensors meta get <file> [key...]\n"+
+			"\tsafetensors meta set <file> <key=value>...\n"+
+			"\tsafetensors meta delete <file> <key>...\n\n"+
+			"Meta prints or edits the __metadata__ of a file.\n"+
+			"Get prints all entries as key=value lines, or only the values of the\n"+
+			"given keys. Set and delete rewrite the header in place when possible,\n"+
+			"without copying the tensors data.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	op, ok := metaOps[fs.Arg(0)]
+	if !ok || fs.NArg() < 2+op.minArgs {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(1)
+	metadata, err := readFileMetadata(name)
+	if err != nil {
+		return fail(stderr, "meta", err)
+	}
+	if err = op.run(stdout, name, metadata, fs.Args()[2:]); err != nil {
+		return fail(stderr, "meta", err)
+	}
+	return 0
+}
+
+// readFileMetadata reads the __metadata__ of the named file, reading
+// only the header.
+func readFileMetadata(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r, err := safetensors.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]string)
+	for k, v := range r.Metadata().Metadata() {
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+func metaGet(w io.Writer, _ string, metadata map[string]string, keys []string) error {
+	if len(keys) == 0 {
+		for _, k := range sortedKeys(metadata) {
+			fmt.Fprintf(w, "%s=%s\n", k, metadata[k])
+		}
+		return nil
+	}
+	for _, k := range keys {
+		v, ok := metadata[k]
+		if !ok {
+			return fmt.Errorf("key %q not found", k)
+		}
+		fmt.Fprintln(w, v)
+	}
+	return nil
+}
+
+func metaSet(_ io.Writer, name string, metadata map[string]string, pairs []string) error {
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid argument %q: expected key=value", pair)
+		}
+		metadata[k] = v
+	}
+	return safetensors.UpdateMetadataFile(name, metadata)
+}
+
+func metaDelete(_ io.Writer, name string, metadata map[string]string, keys []string) error {
+	for _, k := range keys {
+		if _, ok := metadata[k]; !ok {
+			return fmt.Errorf("key %q not found", k)
+		}
+		delete(metadata, k)
+	}
+	return safetensors.UpdateMetadataFile(name, metadata)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeta(t *testing.T) {
+	name := writeTestFile(t, map[string]safetensors.TensorView{
+		"a": newTensor(t, safetensors.U8, []uint64{2}, []byte{1, 2}),
+	}, map[string]string{"format": "pt", "license": "apache-2.0"})
+
+	t.Run("get all", func(t *testing.T) {
+		code, stdout, stderr := runCommand("meta", "get", name)
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "format=pt\nlicense=apache-2.0\n", stdout)
+	})
+
+	t.Run("get keys", func(t *testing.T) {
+		code, stdout, stderr := runCommand("meta", "get", name, "license", "format")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "apache-2.0\npt\n", stdout)
+
+		code, _, stderr = runCommand("meta", "get", name, "foo")
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "safetensors meta: key \"foo\" not found\n", stderr)
+	})
+
+	t.Run("set and delete", func(t *testing.T) {
+		code, _, stderr := runCommand("meta", "set", name, "license=MIT", "source=a=b")
+		assert.Equal(t, 0, code, stderr)
+		code, _, stderr = runCommand("meta", "delete", name, "format")
+		assert.Equal(t, 0, code, stderr)
+
+		_, stdout, _ := runCommand("meta", "get", name)
+		assert.Equal(t, "license=MIT\nsource=a=b\n", stdout)
+
+		_, stdout, _ = runCommand("inspect", "-format", "names", name)
+		assert.Equal(t, "a\n", stdout)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		code, _, stderr := runCommand("meta", "set", name, "foo")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `invalid argument "foo": expected key=value`)
+
+		code, _, stderr = runCommand("meta", "set", name)
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage:")
+
+		code, _, _ = runCommand("meta", "foo", name, "x")
+		assert.Equal(t, 2, code)
+	})
+}
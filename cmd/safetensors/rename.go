@@ -0,0 +1,145 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+	"gopkg.in/yaml.v3"
+)
+
+// unmatchedPolicies are the values of the -unmatched flag of rename.
+var unmatchedPolicies = map[string]safetensors.UnmatchedPolicy{
+	safetensors.UnmatchedError.String(): safetensors.UnmatchedError,
+	safetensors.UnmatchedKeep.String():  safetensors.UnmatchedKeep,
+	safetensors.UnmatchedDrop.String():  safetensors.UnmatchedDrop,
+}
+
+func runRename(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rulesFile := fs.String("rules", "", "read the rename rules from the YAML or JSON `file` (required)")
+	unmatched := fs.String("unmatched", "error", "`policy` for names not matched by any rule: error, keep or drop")
+	dryRun := fs.Bool("n", false, "print the new names, without writing the output file")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors rename [flags] -rules <file> <input> [<output>]\n\n"+
+			"Rename writes the tensors of a file with new names, given by an ordered list of\n"+
+			"rules. Each rule has a regular expression pattern, which must match the whole\n"+
+			"name, and a replacement, where $1 or ${name} refer to capture groups:\n\n"+
+			"\t- pattern: 'model\\.layers\\.(\\d+)\\.self_attn\\.q_proj\\.weight'\n"+
+			"\t  replacement: 'blocks.$1.attn.wq'\n\n"+
+			"Each name is renamed by the first matching rule. Checksums, if any, are recomputed.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	policy, ok := unmatchedPolicies[*unmatched]
+	if *rulesFile == "" || !ok || fs.NArg() < 1 || fs.NArg() > 2 || (fs.NArg() == 1) != *dryRun {
+		fs.Usage()
+		return 2
+	}
+
+	rn, err := readRenamer(*rulesFile)
+	if err != nil {
+		return fail(stderr, "rename", err)
+	}
+	rn.Unmatched = policy
+
+	if *dryRun {
+		err = printRenamed(stdout, fs.Arg(0), rn)
+	} else {
+		err = renameFile(fs.Arg(0), fs.Arg(1), rn)
+	}
+	if err != nil {
+		return fail(stderr, "rename", err)
+	}
+	return 0
+}
+
+// readRenamer returns a Renamer with the rules of the named file.
+func readRenamer(name string) (*safetensors.Renamer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := readRenameRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	rn, err := safetensors.NewRenamer(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return rn, nil
+}
+
+// readRenameRules decodes a list of rename rules from YAML or JSON, such as:
+//
+//	# Hugging Face to blocks.
+//	- pattern: 'model\.layers\.(\d+)\.self_attn\.q_proj\.weight'
+//	  replacement: 'blocks.$1.attn.wq'
+func readRenameRules(r io.Reader) ([]safetensors.RenameRule, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var rules []safetensors.RenameRule
+	if err := dec.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid rename rules: %w", err)
+	}
+	return rules, nil
+}
+
+// printRenamed prints the new name of each tensor of the named file, as
+// "old -> new" lines sorted by old name.
+func printRenamed(w io.Writer, name string, rn *safetensors.Renamer) error {
+	h, err := readHeader(name, "*")
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(h.Tensors))
+	for i, e := range h.Tensors {
+		names[i] = e.Name
+	}
+	renamed, err := rn.Names(names)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if newName, ok := renamed[name]; ok {
+			if _, err = fmt.Fprintf(w, "%s -> %s\n", name, newName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renameFile writes the tensors of the input file with new names to the
+// output file, along with the metadata.
+func renameFile(input, output string, rn *safetensors.Renamer) error {
+	if err := checkNotSameFile(input, output); err != nil {
+		return err
+	}
+	f, err := safetensors.Open(input)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	defer f.Close()
+
+	// If the input has checksums, they are recomputed for the new names.
+	_, rn.Checksums = f.Metadata().Metadata()[safetensors.ChecksumsMetadataKey]
+	return tensorio.CreateFile(output, func(w io.Writer) error {
+		return rn.Apply(w, f.SafeTensors)
+	})
+}
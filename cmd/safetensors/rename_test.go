@@ -0,0 +1,120 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRename(t *testing.T) {
+	tensors := map[string]safetensors.TensorView{
+		"model.layers.0.self_attn.q_proj.weight": newTensor(t, safetensors.U8, []uint64{2}, []byte{1, 2}),
+		"model.layers.1.self_attn.q_proj.weight": newTensor(t, safetensors.U8, []uint64{2}, []byte{3, 4}),
+		"lm_head.weight":                         newTensor(t, safetensors.U8, []uint64{1}, []byte{5}),
+	}
+	out, err := safetensors.Serialize(tensors, map[string]string{"format": "pt"}, safetensors.WithChecksums())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "model.safetensors")
+	require.NoError(t, os.WriteFile(src, out, 0o600))
+
+	rules := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(rules, []byte(""+
+		"- pattern: 'model\\.layers\\.(\\d+)\\.self_attn\\.q_proj\\.weight'\n"+
+		"  replacement: 'blocks.$1.attn.wq'\n"), 0o600))
+
+	t.Run("dry run", func(t *testing.T) {
+		code, stdout, stderr := runCommand("rename", "-rules", rules, "-unmatched", "keep", "-n", src)
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, ""+
+			"lm_head.weight -> lm_head.weight\n"+
+			"model.layers.0.self_attn.q_proj.weight -> blocks.0.attn.wq\n"+
+			"model.layers.1.self_attn.q_proj.weight -> blocks.1.attn.wq\n", stdout)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		dst := filepath.Join(dir, "renamed.safetensors")
+		code, _, stderr := runCommand("rename", "-rules", rules, "-unmatched", "drop", src, dst)
+		assert.Equal(t, 0, code, stderr)
+
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		assert.ElementsMatch(t, []string{"blocks.0.attn.wq", "blocks.1.attn.wq"}, f.Names())
+		tv, _ := f.Tensor("blocks.1.attn.wq")
+		assert.Equal(t, []byte{3, 4}, tv.Data())
+		assert.Equal(t, "pt", f.Metadata().Metadata()["format"])
+		assert.NoError(t, safetensors.Verify(f.SafeTensors))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		code, _, stderr := runCommand("rename", src, filepath.Join(dir, "out.safetensors"))
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: safetensors rename")
+
+		code, _, _ = runCommand("rename", "-rules", rules, src)
+		assert.Equal(t, 2, code)
+
+		code, _, _ = runCommand("rename", "-rules", rules, "-unmatched", "ignore", "-n", src)
+		assert.Equal(t, 2, code)
+
+		dst := filepath.Join(dir, "failed.safetensors")
+		code, _, stderr = runCommand("rename", "-rules", rules, src, dst)
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "safetensors rename: invalid rename: unmatched tensors [\"lm_head.weight\"]\n", stderr)
+		assert.NoFileExists(t, dst)
+
+		invalid := filepath.Join(dir, "invalid.json")
+		require.NoError(t, os.WriteFile(invalid, []byte(`[{"pattern": "("}]`), 0o600))
+		code, _, stderr = runCommand("rename", "-rules", invalid, "-n", src)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "invalid.json: invalid rename rule 0:")
+	})
+}
+
+func TestReadRenameRules(t *testing.T) {
+	expected := []safetensors.RenameRule{
+		{Pattern: `model\.layers\.(\d+)\.self_attn\.q_proj\.weight`, Replacement: "blocks.$1.attn.wq"},
+		{Pattern: `lm_head\.weight`, Replacement: "output"},
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		rules, err := readRenameRules(strings.NewReader("" +
+			"# Hugging Face to blocks.\n" +
+			"- pattern: 'model\\.layers\\.(\\d+)\\.self_attn\\.q_proj\\.weight'\n" +
+			"  replacement: blocks.$1.attn.wq\n" +
+			"- {pattern: 'lm_head\\.weight', replacement: output}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, rules)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		rules, err := readRenameRules(strings.NewReader(`[
+			{"pattern": "model\\.layers\\.(\\d+)\\.self_attn\\.q_proj\\.weight", "replacement": "blocks.$1.attn.wq"},
+			{"pattern": "lm_head\\.weight", "replacement": "output"}
+		]`))
+		require.NoError(t, err)
+		assert.Equal(t, expected, rules)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		rules, err := readRenameRules(strings.NewReader(""))
+		require.NoError(t, err)
+		assert.Empty(t, rules)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := readRenameRules(strings.NewReader(`[{"pattern": "a", "replace": "b"}]`))
+		assert.ErrorContains(t, err, `invalid rename rules: yaml: unmarshal errors:`)
+		assert.ErrorContains(t, err, `field replace not found`)
+	})
+}
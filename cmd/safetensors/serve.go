@@ -0,0 +1,67 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/remote"
+)
+
+func runServe(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8080", "listen on the TCP network `address`")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: safetensors serve [flags] <file>\n\n"+
+			"Serve serves the tensors of a safetensors file over HTTP, memory-mapping it.\n"+
+			"The header is served at /header, as JSON, and the raw data of each tensor at\n"+
+			"/tensors/<name>, optionally sliced with a query such as ?slice=0:2,:,1:.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	f, h, err := openHandler(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, "serve", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(stdout, "serving %s on %s\n", fs.Arg(0), *addr)
+	if err = http.ListenAndServe(*addr, h); err != nil {
+		return fail(stderr, "serve", err)
+	}
+	return 0
+}
+
+// openHandler memory-maps the named file, returning it along with a
+// handler serving its tensors.
+func openHandler(name string) (*safetensors.File, http.Handler, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := safetensors.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	h, err := remote.NewHandler(f.SafeTensors, remote.FileIdentity(fi))
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return f, h, nil
+}
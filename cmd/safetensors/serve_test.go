@@ -0,0 +1,54 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	name := writeTestFile(t, map[string]safetensors.TensorView{
+		"a": newTensor(t, safetensors.U8, []uint64{2, 2}, []byte{1, 2, 3, 4}),
+	}, nil)
+
+	t.Run("handler", func(t *testing.T) {
+		f, h, err := openHandler(name)
+		require.NoError(t, err)
+		defer f.Close()
+		ts := httptest.NewServer(h)
+		defer ts.Close()
+
+		res, err := http.Get(ts.URL + "/tensors/a?slice=1:2")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []byte{3, 4}, body)
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		code, _, stderr := runCommand("serve")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: safetensors serve")
+
+		code, _, stderr = runCommand("serve", filepath.Join(t.TempDir(), "missing.safetensors"))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "no such file or directory")
+
+		code, _, stderr = runCommand("serve", "-addr", "invalid address", name)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "safetensors serve:")
+	})
+}
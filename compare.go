@@ -0,0 +1,189 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+// CompareOptions allows to customize the behavior of Compare.
+type CompareOptions struct {
+	// RTol is the relative tolerance for comparing floating point values.
+	RTol float64
+	// ATol is the absolute tolerance for comparing floating point values.
+	ATol float64
+}
+
+// Comparison is the result of comparing two SafeTensors, a and b.
+type Comparison struct {
+	// OnlyInA are the sorted names of the tensors only found in a.
+	OnlyInA []string
+	// OnlyInB are the sorted names of the tensors only found in b.
+	OnlyInB []string
+	// Metadata lists the differences between the metadata of a and b,
+	// sorted by key.
+	Metadata []MetadataDiff
+	// Tensors lists the comparisons of the tensors found both in a and b,
+	// sorted by name.
+	Tensors []TensorComparison
+}
+
+// MetadataDiff describes a metadata entry which differs between two
+// SafeTensors. A missing entry is reported as an empty value, and a false
+// flag.
+type MetadataDiff struct {
+	Key  string
+	A, B string
+	InA  bool
+	InB  bool
+}
+
+// TensorComparison describes the comparison of two tensors with the same
+// name.
+type TensorComparison struct {
+	Name   string
+	DTypeA DType
+	DTypeB DType
+	ShapeA []uint64
+	ShapeB []uint64
+	// Mismatches is the number of differing elements. Elements are only
+	// compared when both DType and shape are the same.
+	Mismatches uint64
+	// FirstMismatch is the flat index of the first differing element,
+	// or -1 if there are no mismatches.
+	FirstMismatch int64
+	// MaxAbsDiff is the maximum absolute difference between elements,
+	// ignoring NaN values.
+	MaxAbsDiff float64
+}
+
+// Equal reports whether the two tensors are considered equal.
+func (tc TensorComparison) Equal() bool {
+	return tc.DTypeA == tc.DTypeB && equalShapes(tc.ShapeA, tc.ShapeB) && tc.Mismatches == 0
+}
+
+// Equal reports whether no difference was found.
+func (c Comparison) Equal() bool {
+	if len(c.OnlyInA) > 0 || len(c.OnlyInB) > 0 || len(c.Metadata) > 0 {
+		return false
+	}
+	for i := range c.Tensors {
+		if !c.Tensors[i].Equal() {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares the sets of tensors, their DTypes, shapes and values,
+// and the metadata, of two SafeTensors.
+//
+// By default, tensors' elements are compared byte by byte. If a non-zero
+// tolerance is given in opts, elements of floating point tensors, a and b,
+// are instead considered equal when |a - b| <= ATol + RTol * |b|; NaN
+// values are equal to each other.
+func Compare(a, b SafeTensors, opts CompareOptions) Comparison {
+	var c Comparison
+	c.Metadata = compareMetadata(a.metadata.metadata, b.metadata.metadata)
+
+	for _, name := range a.Names() {
+		ta, _ := a.Tensor(name)
+		tb, ok := b.Tensor(name)
+		if !ok {
+			c.OnlyInA = append(c.OnlyInA, name)
+			continue
+		}
+		c.Tensors = append(c.Tensors, compareTensors(name, ta, tb, opts))
+	}
+	for _, name := range b.Names() {
+		if _, ok := a.metadata.indexMap[name]; !ok {
+			c.OnlyInB = append(c.OnlyInB, name)
+		}
+	}
+
+	sort.Strings(c.OnlyInA)
+	sort.Strings(c.OnlyInB)
+	sort.Slice(c.Tensors, func(i, j int) bool { return c.Tensors[i].Name < c.Tensors[j].Name })
+	return c
+}
+
+func compareMetadata(a, b map[string]string) []MetadataDiff {
+	var diffs []MetadataDiff
+	for k, va := range a {
+		vb, ok := b[k]
+		if !ok || va != vb {
+			diffs = append(diffs, MetadataDiff{Key: k, A: va, B: vb, InA: true, InB: ok})
+		}
+	}
+	for k, vb := range b {
+		if _, ok := a[k]; !ok {
+			diffs = append(diffs, MetadataDiff{Key: k, B: vb, InB: true})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
+
+func compareTensors(name string, a, b TensorView, opts CompareOptions) TensorComparison {
+	tc := TensorComparison{
+		Name:          name,
+		DTypeA:        a.DType(),
+		DTypeB:        b.DType(),
+		ShapeA:        a.Shape(),
+		ShapeB:        b.Shape(),
+		FirstMismatch: -1,
+	}
+	if !tc.Equal() || bytes.Equal(a.Data(), b.Data()) {
+		return tc
+	}
+
+	compareElements(&tc, a.Data(), b.Data(), a.DType(), opts)
+	return tc
+}
+
+func compareElements(tc *TensorComparison, a, b []byte, dt DType, opts CompareOptions) {
+	tolerance := dt.isFloat() && (opts.RTol != 0 || opts.ATol != 0)
+	read := elementReader(dt)
+	size := int(dt.Size())
+
+	for i := 0; i < len(a); i += size {
+		ea, eb := a[i:i+size], b[i:i+size]
+		va, vb := read(ea), read(eb)
+		if d := math.Abs(va - vb); d > tc.MaxAbsDiff {
+			tc.MaxAbsDiff = d
+		}
+		if tolerance && isClose(va, vb, opts) || !tolerance && bytes.Equal(ea, eb) {
+			continue
+		}
+		if tc.Mismatches == 0 {
+			tc.FirstMismatch = int64(i / size)
+		}
+		tc.Mismatches++
+	}
+}
+
+// elementReader returns a function decoding numeric values of any DType.
+func elementReader(dt DType) func([]byte) float64 {
+	if dt.isFloat() {
+		return floatReader(dt)
+	}
+	read := intReader(dt)
+	return func(b []byte) float64 {
+		return intToFloat(read(b))
+	}
+}
+
+func isClose(a, b float64, opts CompareOptions) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if a == b {
+		// Also covers infinities with the same sign.
+		return true
+	}
+	return math.Abs(a-b) <= opts.ATol+opts.RTol*math.Abs(b)
+}
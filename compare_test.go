@@ -0,0 +1,110 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	newST := func(t *testing.T, tensors map[string]TensorView, metadata map[string]string) SafeTensors {
+		t.Helper()
+		out, err := Serialize(tensors, metadata)
+		require.NoError(t, err)
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+		return st
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		tensors := map[string]TensorView{
+			"a": newF32Tensor(t, 1, 2),
+			"b": newI64Tensor(t, 3),
+		}
+		a := newST(t, tensors, map[string]string{"k": "v"})
+		b := newST(t, tensors, map[string]string{"k": "v"})
+
+		c := Compare(a, b, CompareOptions{})
+		assert.True(t, c.Equal())
+		assert.Empty(t, c.OnlyInA)
+		assert.Empty(t, c.OnlyInB)
+		assert.Empty(t, c.Metadata)
+		require.Len(t, c.Tensors, 2)
+		assert.Equal(t, TensorComparison{
+			Name:          "a",
+			DTypeA:        F32,
+			DTypeB:        F32,
+			ShapeA:        []uint64{2},
+			ShapeB:        []uint64{2},
+			FirstMismatch: -1,
+		}, c.Tensors[0])
+	})
+
+	t.Run("differences", func(t *testing.T) {
+		a := newST(t, map[string]TensorView{
+			"only_a": newF32Tensor(t, 1),
+			"dtype":  newF32Tensor(t, 1),
+			"shape":  newF32Tensor(t, 1, 2),
+			"values": newF32Tensor(t, 1, 2, 3, 4),
+			"ints":   newI64Tensor(t, 1, 2, 3),
+		}, map[string]string{"same": "x", "changed": "1", "removed": "y"})
+		b := newST(t, map[string]TensorView{
+			"only_b": newF32Tensor(t, 1),
+			"dtype":  newI64Tensor(t, 1),
+			"shape":  newF32Tensor(t, 1, 2, 3),
+			"values": newF32Tensor(t, 1, 2.5, 3, 3),
+			"ints":   newI64Tensor(t, 1, 2, 3),
+		}, map[string]string{"same": "x", "changed": "2", "added": "z"})
+
+		c := Compare(a, b, CompareOptions{})
+		assert.False(t, c.Equal())
+		assert.Equal(t, []string{"only_a"}, c.OnlyInA)
+		assert.Equal(t, []string{"only_b"}, c.OnlyInB)
+		assert.Equal(t, []MetadataDiff{
+			{Key: "added", B: "z", InB: true},
+			{Key: "changed", A: "1", B: "2", InA: true, InB: true},
+			{Key: "removed", A: "y", InA: true},
+		}, c.Metadata)
+
+		require.Len(t, c.Tensors, 4)
+		byName := make(map[string]TensorComparison)
+		for _, tc := range c.Tensors {
+			byName[tc.Name] = tc
+		}
+		assert.False(t, byName["dtype"].Equal())
+		assert.False(t, byName["shape"].Equal())
+		assert.True(t, byName["ints"].Equal())
+
+		values := byName["values"]
+		assert.False(t, values.Equal())
+		assert.Equal(t, uint64(2), values.Mismatches)
+		assert.Equal(t, int64(1), values.FirstMismatch)
+		assert.Equal(t, 1.0, values.MaxAbsDiff)
+	})
+
+	t.Run("tolerance", func(t *testing.T) {
+		nan := float32(math.NaN())
+		a := newST(t, map[string]TensorView{"x": newF32Tensor(t, 1, 100, nan, 0)}, nil)
+		b := newST(t, map[string]TensorView{"x": newF32Tensor(t, 1.05, 101, nan, float32(math.Copysign(0, -1)))}, nil)
+
+		c := Compare(a, b, CompareOptions{})
+		require.Len(t, c.Tensors, 1)
+		assert.Equal(t, uint64(3), c.Tensors[0].Mismatches)
+
+		c = Compare(a, b, CompareOptions{ATol: 0.1, RTol: 0.01})
+		require.Len(t, c.Tensors, 1)
+		assert.True(t, c.Equal())
+		assert.InDelta(t, 1.0, c.Tensors[0].MaxAbsDiff, 1e-9)
+
+		c = Compare(a, b, CompareOptions{ATol: 0.01})
+		require.Len(t, c.Tensors, 1)
+		assert.Equal(t, uint64(2), c.Tensors[0].Mismatches)
+		assert.Equal(t, int64(0), c.Tensors[0].FirstMismatch)
+	})
+}
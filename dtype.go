@@ -17,6 +17,12 @@ const (
 	U8
 	// I8 represents a signed byte type.
 	I8
+	// F8_E5M2 represents an 8-bit floating point type, with 5 bits of
+	// exponent and 2 bits of mantissa.
+	F8_E5M2
+	// F8_E4M3 represents an 8-bit floating point type, with 4 bits of
+	// exponent and 3 bits of mantissa.
+	F8_E4M3
 	// I16 represents a 16-bit signed integer type.
 	I16
 	// U16 represents a 16-bit unsigned integer type.
@@ -41,64 +47,72 @@ const (
 
 var (
 	dTypeToSize = [...]uint64{
-		BOOL: 1,
-		U8:   1,
-		I8:   1,
-		I16:  2,
-		U16:  2,
-		F16:  2,
-		BF16: 2,
-		I32:  4,
-		U32:  4,
-		F32:  4,
-		F64:  8,
-		I64:  8,
-		U64:  8,
+		BOOL:    1,
+		U8:      1,
+		I8:      1,
+		F8_E5M2: 1,
+		F8_E4M3: 1,
+		I16:     2,
+		U16:     2,
+		F16:     2,
+		BF16:    2,
+		I32:     4,
+		U32:     4,
+		F32:     4,
+		F64:     8,
+		I64:     8,
+		U64:     8,
 	}
 	dTypeToString = [...]string{
-		BOOL: "BOOL",
-		U8:   "U8",
-		I8:   "I8",
-		I16:  "I16",
-		U16:  "U16",
-		F16:  "F16",
-		BF16: "BF16",
-		I32:  "I32",
-		U32:  "U32",
-		F32:  "F32",
-		F64:  "F64",
-		I64:  "I64",
-		U64:  "U64",
+		BOOL:    "BOOL",
+		U8:      "U8",
+		I8:      "I8",
+		F8_E5M2: "F8_E5M2",
+		F8_E4M3: "F8_E4M3",
+		I16:     "I16",
+		U16:     "U16",
+		F16:     "F16",
+		BF16:    "BF16",
+		I32:     "I32",
+		U32:     "U32",
+		F32:     "F32",
+		F64:     "F64",
+		I64:     "I64",
+		U64:     "U64",
 	}
 	dTypeToJSON = [...][]byte{
-		BOOL: []byte(`"BOOL"`),
-		U8:   []byte(`"U8"`),
-		I8:   []byte(`"I8"`),
-		I16:  []byte(`"I16"`),
-		U16:  []byte(`"U16"`),
-		F16:  []byte(`"F16"`),
-		BF16: []byte(`"BF16"`),
-		I32:  []byte(`"I32"`),
-		U32:  []byte(`"U32"`),
-		F32:  []byte(`"F32"`),
-		F64:  []byte(`"F64"`),
-		I64:  []byte(`"I64"`),
-		U64:  []byte(`"U64"`),
+		BOOL:    []byte(`"BOOL"`),
+		U8:      []byte(`"U8"`),
+		I8:      []byte(`"I8"`),
+		F8_E5M2: []byte(`"F8_E5M2"`),
+		F8_E4M3: []byte(`"F8_E4M3"`),
+		I16:     []byte(`"I16"`),
+		U16:     []byte(`"U16"`),
+		F16:     []byte(`"F16"`),
+		BF16:    []byte(`"BF16"`),
+		I32:     []byte(`"I32"`),
+		U32:     []byte(`"U32"`),
+		F32:     []byte(`"F32"`),
+		F64:     []byte(`"F64"`),
+		I64:     []byte(`"I64"`),
+		U64:     []byte(`"U64"`),
 	}
 	stringToDType = map[string]DType{
-		"BOOL": BOOL,
-		"U8":   U8,
-		"I8":   I8,
-		"I16":  I16,
-		"U16":  U16,
-		"F16":  F16,
-		"BF16": BF16,
-		"I32":  I32,
-		"U32":  U32,
-		"F32":  F32,
-		"F64":  F64,
-		"I64":  I64,
-		"U64":  U64,
+		"BOOL":    BOOL,
+		"U8":      U8,
+		"I8":      I8,
+		"F8_E5M2": F8_E5M2,
+		"F8_E4M3": F8_E4M3,
+		"I16":     I16,
+		"U16":     U16,
+		"F16":     F16,
+		"BF16":    BF16,
+		"I32":     I32,
+		"U32":     U32,
+		"F32":     F32,
+		"F64":     F64,
+		"I64":     I64,
+		"U64":     U64,
 	}
 )
 
@@ -111,6 +125,11 @@ func (dt DType) Size() uint64 {
 	return dTypeToSize[dt]
 }
 
+// isValid reports whether dt is one of the known DType values.
+func (dt DType) isValid() bool {
+	return dt < DType(len(dTypeToSize))
+}
+
 // String representation of a DType.
 func (dt DType) String() string {
 	if dt >= DType(len(dTypeToString)) {
@@ -130,7 +149,7 @@ func (dt DType) MarshalJSON() ([]byte, error) {
 func ParseDType(s string) (DType, error) {
 	dt, ok := stringToDType[s]
 	if !ok {
-		return 0, fmt.Errorf("invalid DType string value %q", s)
+		return 0, fmt.Errorf("%w string value %q", ErrInvalidDType, s)
 	}
 	return dt, nil
 }
@@ -28,6 +28,8 @@ var commonTests = []struct {
 	{BOOL, 1, "BOOL", []byte(`"BOOL"`)},
 	{U8, 1, "U8", []byte(`"U8"`)},
 	{I8, 1, "I8", []byte(`"I8"`)},
+	{F8_E5M2, 1, "F8_E5M2", []byte(`"F8_E5M2"`)},
+	{F8_E4M3, 1, "F8_E4M3", []byte(`"F8_E4M3"`)},
 	{I16, 2, "I16", []byte(`"I16"`)},
 	{U16, 2, "U16", []byte(`"U16"`)},
 	{F16, 2, "F16", []byte(`"F16"`)},
@@ -0,0 +1,191 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors, which can be tested with errors.Is.
+var (
+	// ErrHeaderTooSmall is reported when the buffer is too small to
+	// contain the header size.
+	ErrHeaderTooSmall = errors.New("header too small")
+	// ErrHeaderTooLarge is reported when the header size exceeds the
+	// maximum allowed size. See also LimitError.
+	ErrHeaderTooLarge = errors.New("header too large")
+	// ErrInvalidHeaderLength is reported when the header size exceeds
+	// the size of the buffer.
+	ErrInvalidHeaderLength = errors.New("invalid header length")
+	// ErrInvalidHeader is reported when the JSON header cannot be decoded.
+	ErrInvalidHeader = errors.New("invalid header deserialization")
+	// ErrIncompleteBuffer is reported when the size of the data buffer
+	// does not match the data offsets declared in the header.
+	ErrIncompleteBuffer = errors.New("metadata incomplete buffer")
+	// ErrInvalidDType is reported for unknown DType values.
+	ErrInvalidDType = errors.New("invalid DType")
+	// ErrOverflow is reported when the number of elements or bytes of a
+	// tensor cannot be represented.
+	ErrOverflow = errors.New("multiplication overflow")
+	// ErrInvalidOffset is reported when the data offsets of a tensor are
+	// not contiguous with the previous tensor. See also OffsetError.
+	ErrInvalidOffset = errors.New("invalid metadata offset")
+	// ErrOffsetMismatch is reported when the data offsets of a tensor do
+	// not match its DType and shape. See also OffsetMismatchError.
+	ErrOffsetMismatch = errors.New("info data offsets mismatch")
+	// ErrTooManyTensors is reported when the header contains more tensors
+	// than allowed. See also LimitError.
+	ErrTooManyTensors = errors.New("too many tensors")
+	// ErrNameTooLong is reported when a tensor name is longer than
+	// allowed. See also LimitError.
+	ErrNameTooLong = errors.New("tensor name too long")
+	// ErrInvalidSlice is reported when slice ranges do not match the
+	// shape of a tensor.
+	ErrInvalidSlice = errors.New("invalid slice")
+	// ErrTensorNotFound is reported when a tensor is looked up by a name
+	// that does not exist. See also TensorNotFoundError.
+	ErrTensorNotFound = errors.New("tensor not found")
+	// ErrHeaderDoesNotFit is reported by UpdateMetadataAt when the new
+	// header is larger than the existing one.
+	ErrHeaderDoesNotFit = errors.New("new header does not fit in the existing header")
+	// ErrNoChecksums is reported when checksums are verified on a file
+	// that does not contain them.
+	ErrNoChecksums = errors.New("checksums not found")
+	// ErrChecksumMismatch is reported when the data of some tensors does
+	// not match the checksums. See also ChecksumError.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrMergeConflict is reported by Merge when inputs have tensors or
+	// metadata entries with the same name, not allowed by the policy.
+	ErrMergeConflict = errors.New("merge conflict")
+	// ErrInvalidRename is reported when renaming tensors leaves names
+	// unmatched or not unique. See also RenameError.
+	ErrInvalidRename = errors.New("invalid rename")
+)
+
+// LimitError reports a value exceeding a limit, such as the header size
+// or the number of tensors.
+type LimitError struct {
+	// Err is the sentinel error identifying the limit.
+	Err error
+	// Max is the maximum allowed value.
+	Max uint64
+	// Actual is the value found.
+	Actual uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: max %d, actual %d", e.Err, e.Max, e.Actual)
+}
+
+// Unwrap returns the sentinel error identifying the limit.
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// OffsetError reports a tensor whose data offsets do not begin where
+// the data of the previous tensor ends, or are not in ascending order.
+type OffsetError struct {
+	// Tensor is the name of the tensor.
+	Tensor string
+	// Offsets are the data offsets declared in the header.
+	Offsets [2]uint64
+	// ExpectedBegin is the expected begin offset.
+	ExpectedBegin uint64
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("invalid metadata offset for tensor %q: data offsets %v, expected begin %d",
+		e.Tensor, e.Offsets, e.ExpectedBegin)
+}
+
+// Unwrap returns ErrInvalidOffset.
+func (e *OffsetError) Unwrap() error { return ErrInvalidOffset }
+
+// OffsetMismatchError reports a tensor whose data size, as declared by
+// its data offsets, does not match the size computed from its DType
+// and shape.
+type OffsetMismatchError struct {
+	// Tensor is the name of the tensor.
+	Tensor string
+	// Expected is the data size computed from DType and shape.
+	Expected uint64
+	// Actual is the data size declared by the data offsets.
+	Actual uint64
+}
+
+func (e *OffsetMismatchError) Error() string {
+	return fmt.Sprintf("metadata validation error: %v: tensor %q has %d bytes of data, expected %d",
+		ErrOffsetMismatch, e.Tensor, e.Actual, e.Expected)
+}
+
+// Unwrap returns ErrOffsetMismatch.
+func (e *OffsetMismatchError) Unwrap() error { return ErrOffsetMismatch }
+
+// TensorNotFoundError reports a missing tensor.
+type TensorNotFoundError struct {
+	// Name of the tensor.
+	Name string
+}
+
+func (e *TensorNotFoundError) Error() string {
+	return fmt.Sprintf("tensor %q not found", e.Name)
+}
+
+// Unwrap returns ErrTensorNotFound.
+func (e *TensorNotFoundError) Unwrap() error { return ErrTensorNotFound }
+
+// ChecksumError reports the tensors which failed the verification of
+// their checksums.
+type ChecksumError struct {
+	// Corrupt are the names of the tensors whose data does not match the
+	// checksum, sorted.
+	Corrupt []string
+	// Unchecked are the names of the tensors without a checksum, sorted.
+	Unchecked []string
+}
+
+func (e *ChecksumError) Error() string {
+	var parts []string
+	if len(e.Corrupt) > 0 {
+		parts = append(parts, fmt.Sprintf("corrupt tensors %q", e.Corrupt))
+	}
+	if len(e.Unchecked) > 0 {
+		parts = append(parts, fmt.Sprintf("tensors without checksum %q", e.Unchecked))
+	}
+	return fmt.Sprintf("%v: %s", ErrChecksumMismatch, strings.Join(parts, ", "))
+}
+
+// Unwrap returns ErrChecksumMismatch.
+func (e *ChecksumError) Unwrap() error { return ErrChecksumMismatch }
+
+// RenameError reports the names which a Renamer could not rename.
+type RenameError struct {
+	// Unmatched are the names not matched by any rule, sorted.
+	Unmatched []string
+	// Collisions are the new names given to more than one tensor, sorted
+	// by name.
+	Collisions []RenameCollision
+}
+
+// RenameCollision is a new name given to more than one tensor.
+type RenameCollision struct {
+	Name string
+	// Sources are the original names of the tensors, sorted.
+	Sources []string
+}
+
+func (e *RenameError) Error() string {
+	var parts []string
+	if len(e.Unmatched) > 0 {
+		parts = append(parts, fmt.Sprintf("unmatched tensors %q", e.Unmatched))
+	}
+	for _, c := range e.Collisions {
+		parts = append(parts, fmt.Sprintf("tensors %q renamed to %q", c.Sources, c.Name))
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidRename, strings.Join(parts, ", "))
+}
+
+// Unwrap returns ErrInvalidRename.
+func (e *RenameError) Unwrap() error { return ErrInvalidRename }
@@ -0,0 +1,101 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("header too small", func(t *testing.T) {
+		_, err := Deserialize([]byte{1, 2})
+		assert.ErrorIs(t, err, ErrHeaderTooSmall)
+	})
+
+	t.Run("header too large", func(t *testing.T) {
+		buf := buildRaw(`{}      `, 0)
+		_, err := ParseOptions{MaxHeaderSize: 4}.Deserialize(buf)
+		assert.ErrorIs(t, err, ErrHeaderTooLarge)
+
+		var limitErr *LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, uint64(4), limitErr.Max)
+		assert.Equal(t, uint64(8), limitErr.Actual)
+	})
+
+	t.Run("invalid header length", func(t *testing.T) {
+		_, err := Deserialize([]byte{9, 0, 0, 0, 0, 0, 0, 0, '{', '}'})
+		assert.ErrorIs(t, err, ErrInvalidHeaderLength)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":`, 0))
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+	})
+
+	t.Run("invalid dtype", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":{"dtype":"X","shape":[],"data_offsets":[0,0]}}`, 0))
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+		assert.ErrorIs(t, err, ErrInvalidDType)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":{"dtype":"U8","shape":[4294967296,4294967296],"data_offsets":[0,0]}}`, 0))
+		assert.ErrorIs(t, err, ErrOverflow)
+	})
+
+	t.Run("invalid offset", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":{"dtype":"U8","shape":[2],"data_offsets":[1,3]}}`, 3))
+		assert.ErrorIs(t, err, ErrInvalidOffset)
+
+		var offsetErr *OffsetError
+		require.ErrorAs(t, err, &offsetErr)
+		assert.Equal(t, OffsetError{Tensor: "a", Offsets: [2]uint64{1, 3}, ExpectedBegin: 0}, *offsetErr)
+		assert.EqualError(t, err, `invalid metadata offset for tensor "a": data offsets [1 3], expected begin 0`)
+	})
+
+	t.Run("offset mismatch", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,6]}}`, 6))
+		assert.ErrorIs(t, err, ErrOffsetMismatch)
+
+		var mismatchErr *OffsetMismatchError
+		require.ErrorAs(t, err, &mismatchErr)
+		assert.Equal(t, OffsetMismatchError{Tensor: "a", Expected: 8, Actual: 6}, *mismatchErr)
+		assert.EqualError(t, err, `metadata validation error: info data offsets mismatch: tensor "a" has 6 bytes of data, expected 8`)
+	})
+
+	t.Run("incomplete buffer", func(t *testing.T) {
+		_, err := Deserialize(buildRaw(`{"a":{"dtype":"U8","shape":[2],"data_offsets":[0,2]}}`, 1))
+		assert.ErrorIs(t, err, ErrIncompleteBuffer)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		buf := buildRaw(`{"abc":{"dtype":"U8","shape":[],"data_offsets":[0,1]}}`, 1)
+
+		_, err := ParseOptions{MaxTensors: 0, MaxNameLength: 2}.Deserialize(buf)
+		assert.ErrorIs(t, err, ErrNameTooLong)
+
+		_, err = ParseOptions{MaxTensors: -1}.Deserialize(buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tensor not found", func(t *testing.T) {
+		buf := buildRaw(`{}`, 0)
+		r, err := NewReader(bytes.NewReader(buf), int64(len(buf)))
+		require.NoError(t, err)
+
+		_, err = r.Tensor("x")
+		assert.True(t, errors.Is(err, ErrTensorNotFound))
+
+		var notFound *TensorNotFoundError
+		require.ErrorAs(t, err, &notFound)
+		assert.Equal(t, "x", notFound.Name)
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// File is a SafeTensors whose data is memory-mapped, read-only, from
+// a file on disk.
+//
+// The TensorView values obtained from a File refer directly to the mapped
+// memory: they must not be modified, and they are valid only until Close
+// is called.
+type File struct {
+	SafeTensors
+	mapping []byte
+}
+
+// Open opens the named safetensors file and memory-maps its content.
+//
+// Only the header is actually read and validated at this stage: the tensors'
+// data is loaded lazily by the operating system upon access.
+func Open(name string) (*File, error) {
+	return ParseOptions{}.Open(name)
+}
+
+// Open is like the package-level Open function, but it parses the header
+// according to the options.
+func (o ParseOptions) Open(name string) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return o.OpenFile(f)
+}
+
+// OpenFile memory-maps the content of an already opened safetensors file.
+//
+// The mapping stays valid after f is closed, so the caller is free to
+// close f as soon as OpenFile returns.
+func OpenFile(f *os.File) (*File, error) {
+	return ParseOptions{}.OpenFile(f)
+}
+
+// OpenFile is like the package-level OpenFile function, but it parses the
+// header according to the options.
+func (o ParseOptions) OpenFile(f *os.File) (*File, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size > math.MaxInt {
+		return nil, fmt.Errorf("file too large to be mapped: %d bytes", size)
+	}
+
+	mapping, err := mmapFile(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map file %q: %w", f.Name(), err)
+	}
+
+	st, err := o.Deserialize(mapping)
+	if err != nil {
+		_ = munmapFile(mapping)
+		return nil, err
+	}
+	return &File{
+		SafeTensors: st,
+		mapping:     mapping,
+	}, nil
+}
+
+// Close unmaps the file.
+//
+// After Close, the File behaves as an empty SafeTensors, and any TensorView
+// previously obtained from it must no longer be used.
+// Calling Close more than once has no effect.
+func (f *File) Close() error {
+	if f.mapping == nil {
+		return nil
+	}
+	mapping := f.mapping
+	f.SafeTensors = SafeTensors{}
+	f.mapping = nil
+	return munmapFile(mapping)
+}
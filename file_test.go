@@ -0,0 +1,66 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	t.Run("valid file", func(t *testing.T) {
+		name := writeTempFile(t, []byte("Y\x00\x00\x00\x00\x00\x00\x00"+
+			`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0,16]},"__metadata__":{"foo":"bar"}}`+
+			"\x01\x00\x00\x00\x02\x00\x00\x00\x03\x00\x00\x00\x04\x00\x00\x00"))
+
+		f, err := Open(name)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, f.Len())
+		assert.Equal(t, []string{"test"}, f.Names())
+
+		tensor, ok := f.Tensor("test")
+		require.True(t, ok)
+		assert.Equal(t, I32, tensor.DType())
+		assert.Equal(t, []uint64{2, 2}, tensor.Shape())
+		assert.Equal(t, []byte("\x01\x00\x00\x00\x02\x00\x00\x00\x03\x00\x00\x00\x04\x00\x00\x00"), tensor.Data())
+
+		require.NoError(t, f.Close())
+		assert.True(t, f.IsEmpty())
+		_, ok = f.Tensor("test")
+		assert.False(t, ok)
+
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		name := writeTempFile(t, nil)
+		_, err := Open(name)
+		assert.EqualError(t, err, "header too small")
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		name := writeTempFile(t, []byte("<\x00\x00\x00\x00\x00\x00\x00"+
+			`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0, 4]}}`))
+		_, err := Open(name)
+		assert.EqualError(t, err, `metadata validation error: info data offsets mismatch: tensor "test" has 4 bytes of data, expected 16`)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Open(filepath.Join(t.TempDir(), "missing.safetensors"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.safetensors")
+	require.NoError(t, os.WriteFile(name, data, 0o600))
+	return name
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"math"
+	"sync"
+)
+
+// f16 is the IEEE 754 half-precision format.
+var f16 = minifloat{expBits: 5, mantBits: 10, hasInf: true, saturate: false}
+
+// bf16 is the brain floating point format, only used for conversions from
+// float64 and integers: Float32ToBF16 is faster for float32 values.
+var bf16 = minifloat{expBits: 8, mantBits: 7, hasInf: true, saturate: false}
+
+var (
+	f16TableOnce sync.Once
+	// f16Table maps each F16 value to float32, speeding up bulk conversions.
+	f16Table []float32
+)
+
+func initF16Table() {
+	f16Table = make([]float32, 1<<16)
+	for i := range f16Table {
+		f16Table[i] = f16.toFloat32(uint32(i))
+	}
+}
+
+// F16ToFloat32 converts an F16 (IEEE 754 half-precision) value to float32.
+// The conversion is exact, NaN payloads included.
+func F16ToFloat32(v uint16) float32 {
+	return f16.toFloat32(uint32(v))
+}
+
+// Float32ToF16 converts a float32 value to the nearest F16 (IEEE 754
+// half-precision) value, rounding half to even.
+//
+// Values exceeding the F16 range become infinities. NaN values are
+// preserved, together with the most significant bits of their payload.
+func Float32ToF16(f float32) uint16 {
+	return uint16(f16.fromFloat32(f))
+}
+
+// BF16ToFloat32 converts a BF16 (brain floating point) value to float32.
+// The conversion is exact, NaN payloads included.
+func BF16ToFloat32(v uint16) float32 {
+	return math.Float32frombits(uint32(v) << 16)
+}
+
+// Float32ToBF16 converts a float32 value to the nearest BF16 (brain
+// floating point) value, rounding half to even.
+//
+// Values exceeding the BF16 range become infinities. NaN values are
+// preserved, together with the most significant bits of their payload.
+func Float32ToBF16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	if bits&0x7fffffff > 0x7f800000 {
+		// NaN: make sure the truncated payload is not zero, which would
+		// turn it into an infinity.
+		v := uint16(bits >> 16)
+		if v&0x7f == 0 {
+			v |= 0x40
+		}
+		return v
+	}
+	return uint16((bits + 0x7fff + (bits>>16)&1) >> 16)
+}
+
+// F16ToFloat32Slice converts each F16 value from src to float32, storing
+// the results in dst, which must be at least as long as src.
+func F16ToFloat32Slice(dst []float32, src []uint16) {
+	f16TableOnce.Do(initF16Table)
+	dst = dst[:len(src)]
+	parallelFor(len(src), func(lo, hi int) {
+		for i, v := range src[lo:hi] {
+			dst[lo+i] = f16Table[v]
+		}
+	})
+}
+
+// Float32ToF16Slice converts each float32 value from src to F16, as with
+// Float32ToF16, storing the results in dst, which must be at least as long
+// as src.
+func Float32ToF16Slice(dst []uint16, src []float32) {
+	dst = dst[:len(src)]
+	parallelFor(len(src), func(lo, hi int) {
+		for i, f := range src[lo:hi] {
+			dst[lo+i] = Float32ToF16(f)
+		}
+	})
+}
+
+// BF16ToFloat32Slice converts each BF16 value from src to float32, storing
+// the results in dst, which must be at least as long as src.
+func BF16ToFloat32Slice(dst []float32, src []uint16) {
+	dst = dst[:len(src)]
+	parallelFor(len(src), func(lo, hi int) {
+		for i, v := range src[lo:hi] {
+			dst[lo+i] = math.Float32frombits(uint32(v) << 16)
+		}
+	})
+}
+
+// Float32ToBF16Slice converts each float32 value from src to BF16, as with
+// Float32ToBF16, storing the results in dst, which must be at least as long
+// as src.
+func Float32ToBF16Slice(dst []uint16, src []float32) {
+	dst = dst[:len(src)]
+	parallelFor(len(src), func(lo, hi int) {
+		for i, f := range src[lo:hi] {
+			dst[lo+i] = Float32ToBF16(f)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestF16(t *testing.T) {
+	inf := float32(math.Inf(1))
+
+	t.Run("to float32", func(t *testing.T) {
+		testCases := []struct {
+			v    uint16
+			want float32
+		}{
+			{0x0000, 0},
+			{0x0001, 1.0 / (1 << 24)},    // smallest subnormal
+			{0x03ff, 1023.0 / (1 << 24)}, // largest subnormal
+			{0x0400, 1.0 / (1 << 14)},    // smallest normal
+			{0x3c00, 1},
+			{0x3c01, 1 + 1.0/1024},
+			{0xc000, -2},
+			{0x7bff, 65504}, // largest finite
+			{0x7c00, inf},
+			{0xfc00, -inf},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, F16ToFloat32(tc.v), "value %#04x", tc.v)
+		}
+		assert.True(t, math.Signbit(float64(F16ToFloat32(0x8000))))
+		assert.Equal(t, uint32(0x7fc02000), math.Float32bits(F16ToFloat32(0x7e01)), "NaN payload")
+	})
+
+	t.Run("from float32", func(t *testing.T) {
+		testCases := []struct {
+			f    float32
+			want uint16
+		}{
+			{0, 0x0000},
+			{1, 0x3c00},
+			{-2, 0xc000},
+			{1 + 1.0/2048, 0x3c00}, // tie, round to even
+			{1 + 3.0/2048, 0x3c02}, // tie, round to even
+			{65504, 0x7bff},
+			{65519, 0x7bff},           // below half, rounded down
+			{65520, 0x7c00},           // overflow
+			{1e10, 0x7c00},            // overflow
+			{-1e10, 0xfc00},           // overflow
+			{inf, 0x7c00},             // infinity
+			{1.0 / (1 << 25), 0x0000}, // tie with zero, round to even
+			{3.0 / (1 << 26), 0x0001}, // rounded up to smallest subnormal
+			{-1e-30, 0x8000},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, Float32ToF16(tc.f), "value %g", tc.f)
+		}
+		assert.Equal(t, uint16(0x7e01), Float32ToF16(math.Float32frombits(0x7fc02000)), "NaN payload")
+		assert.Equal(t, uint16(0x7e00), Float32ToF16(math.Float32frombits(0x7f800001)), "NaN with truncated payload")
+		assert.Equal(t, uint16(0xfe00), Float32ToF16(math.Float32frombits(0xffc00000)), "negative NaN")
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for i := 0; i < 1<<16; i++ {
+			v := uint16(i)
+			if got := Float32ToF16(F16ToFloat32(v)); got != v {
+				t.Errorf("value %#04x: got %#04x", v, got)
+			}
+		}
+	})
+}
+
+func TestBF16(t *testing.T) {
+	inf := float32(math.Inf(1))
+
+	t.Run("to float32", func(t *testing.T) {
+		assert.Equal(t, float32(0), BF16ToFloat32(0x0000))
+		assert.Equal(t, float32(1), BF16ToFloat32(0x3f80))
+		assert.Equal(t, float32(-2), BF16ToFloat32(0xc000))
+		assert.Equal(t, math.Float32frombits(0x00010000), BF16ToFloat32(0x0001)) // subnormal
+		assert.Equal(t, inf, BF16ToFloat32(0x7f80))
+		assert.Equal(t, uint32(0x7fc10000), math.Float32bits(BF16ToFloat32(0x7fc1)), "NaN payload")
+	})
+
+	t.Run("from float32", func(t *testing.T) {
+		testCases := []struct {
+			bits uint32
+			want uint16
+		}{
+			{0x3f800000, 0x3f80},
+			{0x3f808000, 0x3f80}, // tie, round to even
+			{0x3f818000, 0x3f82}, // tie, round to even
+			{0x3f808001, 0x3f81}, // above half
+			{0x00018000, 0x0002}, // subnormal tie, round to even
+			{0x7f7fffff, 0x7f80}, // overflow
+			{0xff7fffff, 0xff80}, // overflow
+			{0x7f800000, 0x7f80}, // infinity
+			{0x7fc10000, 0x7fc1}, // NaN payload
+			{0x7f800001, 0x7fc0}, // NaN with truncated payload
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, Float32ToBF16(math.Float32frombits(tc.bits)), "bits %#08x", tc.bits)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for i := 0; i < 1<<16; i++ {
+			v := uint16(i)
+			if got := Float32ToBF16(BF16ToFloat32(v)); got != v {
+				t.Errorf("value %#04x: got %#04x", v, got)
+			}
+		}
+	})
+}
+
+func TestHalfSlices(t *testing.T) {
+	// Large enough to be processed concurrently.
+	n := 4 * minParallelChunk
+	src := make([]float32, n)
+	for i := range src {
+		src[i] = float32(i%2048) - 1024
+	}
+
+	f16s := make([]uint16, n)
+	Float32ToF16Slice(f16s, src)
+	bf16s := make([]uint16, n)
+	Float32ToBF16Slice(bf16s, src)
+
+	f16Back := make([]float32, n)
+	F16ToFloat32Slice(f16Back, f16s)
+	bf16Back := make([]float32, n)
+	BF16ToFloat32Slice(bf16Back, bf16s)
+
+	for i, f := range src {
+		if f16s[i] != Float32ToF16(f) || f16Back[i] != f {
+			t.Fatalf("F16 conversion mismatch at index %d", i)
+		}
+		if bf16s[i] != Float32ToBF16(f) || bf16Back[i] != BF16ToFloat32(bf16s[i]) {
+			t.Fatalf("BF16 conversion mismatch at index %d", i)
+		}
+	}
+}
+
+func TestFloat32Values(t *testing.T) {
+	testCases := []struct {
+		dType DType
+		data  []byte
+	}{
+		{F8_E5M2, []byte{0x3c, 0xc0}},
+		{F8_E4M3, []byte{0x38, 0xc0}},
+		{F16, []byte{0x00, 0x3c, 0x00, 0xc0}},
+		{BF16, []byte{0x80, 0x3f, 0x00, 0xc0}},
+		{F32, []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0xc0}},
+		{F64, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0xc0}},
+	}
+	for _, tc := range testCases {
+		tv, err := NewTensorView(tc.dType, []uint64{2}, tc.data)
+		require.NoError(t, err)
+		values, err := Float32Values(tv)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{1, -2}, values, "DType %s", tc.dType)
+	}
+
+	tv, err := NewTensorView(I32, []uint64{1}, make([]byte, 4))
+	require.NoError(t, err)
+	_, err = Float32Values(tv)
+	assert.EqualError(t, err, "cannot get float32 values from tensor with DType I32")
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+var (
+	// f8E5M2 follows IEEE 754 conventions, having infinities and
+	// multiple NaN values.
+	f8E5M2 = minifloat{expBits: 5, mantBits: 2, hasInf: true, saturate: true}
+	// f8E4M3 has no infinities, and a single NaN value (per sign),
+	// extending the range of finite values up to 448.
+	f8E4M3 = minifloat{expBits: 4, mantBits: 3, hasInf: false, saturate: true}
+)
+
+// F8E5M2ToFloat32 converts an F8_E5M2 value to float32.
+// The conversion is exact.
+func F8E5M2ToFloat32(v uint8) float32 {
+	return f8E5M2.toFloat32(uint32(v))
+}
+
+// Float32ToF8E5M2 converts a float32 value to the nearest F8_E5M2 value,
+// rounding half to even.
+//
+// Finite values exceeding the F8_E5M2 range saturate to the largest finite
+// value (±57344), while infinities are preserved. NaN values are preserved,
+// together with the most significant bits of their payload.
+func Float32ToF8E5M2(f float32) uint8 {
+	return uint8(f8E5M2.fromFloat32(f))
+}
+
+// F8E4M3ToFloat32 converts an F8_E4M3 value to float32.
+// The conversion is exact.
+func F8E4M3ToFloat32(v uint8) float32 {
+	return f8E4M3.toFloat32(uint32(v))
+}
+
+// Float32ToF8E4M3 converts a float32 value to the nearest F8_E4M3 value,
+// rounding half to even.
+//
+// F8_E4M3 has no infinities: infinities and finite values exceeding its
+// range saturate to the largest finite value (±448). NaN values are
+// converted to the only F8_E4M3 NaN value with the same sign.
+func Float32ToF8E4M3(f float32) uint8 {
+	return uint8(f8E4M3.fromFloat32(f))
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestF8E5M2(t *testing.T) {
+	inf := float32(math.Inf(1))
+
+	t.Run("to float32", func(t *testing.T) {
+		testCases := []struct {
+			v    uint8
+			want float32
+		}{
+			{0x00, 0},
+			{0x01, 1.0 / (1 << 16)}, // smallest subnormal
+			{0x03, 3.0 / (1 << 16)}, // largest subnormal
+			{0x04, 1.0 / (1 << 14)}, // smallest normal
+			{0x3c, 1},
+			{0x3d, 1.25},
+			{0x40, 2},
+			{0x7b, 57344}, // largest finite
+			{0x7c, inf},
+			{0xbc, -1},
+			{0xfc, -inf},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, F8E5M2ToFloat32(tc.v), "value %#02x", tc.v)
+		}
+		assert.True(t, math.Signbit(float64(F8E5M2ToFloat32(0x80))))
+		for _, v := range []uint8{0x7d, 0x7e, 0x7f, 0xfd, 0xfe, 0xff} {
+			assert.True(t, math.IsNaN(float64(F8E5M2ToFloat32(v))), "value %#02x", v)
+		}
+	})
+
+	t.Run("from float32", func(t *testing.T) {
+		testCases := []struct {
+			f    float32
+			want uint8
+		}{
+			{0, 0x00},
+			{1, 0x3c},
+			{-1, 0xbc},
+			{1.125, 0x3c},           // tie, round to even
+			{1.375, 0x3e},           // tie, round to even
+			{1.126, 0x3d},           // above half
+			{57344, 0x7b},           // largest finite
+			{61440, 0x7b},           // saturated
+			{1e10, 0x7b},            // saturated
+			{-1e10, 0xfb},           // saturated
+			{inf, 0x7c},             // preserved
+			{-inf, 0xfc},            // preserved
+			{1.0 / (1 << 17), 0x00}, // tie with zero, round to even
+			{1.5 / (1 << 17), 0x01}, // rounded up to smallest subnormal
+			{3.5 / (1 << 16), 0x04}, // rounded up to smallest normal
+			{1e-30, 0x00},
+			{-1e-30, 0x80},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, Float32ToF8E5M2(tc.f), "value %g", tc.f)
+		}
+		assert.Equal(t, uint8(0x7e), Float32ToF8E5M2(float32(math.NaN())))
+		assert.Equal(t, uint8(0xfe), Float32ToF8E5M2(float32(math.Copysign(math.NaN(), -1))))
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for i := 0; i < 256; i++ {
+			v := uint8(i)
+			f := F8E5M2ToFloat32(v)
+			if math.IsNaN(float64(f)) {
+				assert.True(t, math.IsNaN(float64(F8E5M2ToFloat32(Float32ToF8E5M2(f)))), "value %#02x", v)
+				continue
+			}
+			assert.Equal(t, v, Float32ToF8E5M2(f), "value %#02x", v)
+		}
+	})
+}
+
+func TestF8E4M3(t *testing.T) {
+	inf := float32(math.Inf(1))
+
+	t.Run("to float32", func(t *testing.T) {
+		testCases := []struct {
+			v    uint8
+			want float32
+		}{
+			{0x00, 0},
+			{0x01, 1.0 / (1 << 9)}, // smallest subnormal
+			{0x07, 7.0 / (1 << 9)}, // largest subnormal
+			{0x08, 1.0 / (1 << 6)}, // smallest normal
+			{0x38, 1},
+			{0x39, 1.125},
+			{0x40, 2},
+			{0x78, 256},
+			{0x7e, 448}, // largest finite
+			{0xb8, -1},
+			{0xfe, -448},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, F8E4M3ToFloat32(tc.v), "value %#02x", tc.v)
+		}
+		assert.True(t, math.Signbit(float64(F8E4M3ToFloat32(0x80))))
+		assert.True(t, math.IsNaN(float64(F8E4M3ToFloat32(0x7f))))
+		assert.True(t, math.IsNaN(float64(F8E4M3ToFloat32(0xff))))
+	})
+
+	t.Run("from float32", func(t *testing.T) {
+		testCases := []struct {
+			f    float32
+			want uint8
+		}{
+			{0, 0x00},
+			{1, 0x38},
+			{-1, 0xb8},
+			{1.0625, 0x38},          // tie, round to even
+			{1.1875, 0x3a},          // tie, round to even
+			{448, 0x7e},             // largest finite
+			{464, 0x7e},             // saturated, rather than rounded to NaN
+			{1e10, 0x7e},            // saturated
+			{inf, 0x7e},             // saturated
+			{-inf, 0xfe},            // saturated
+			{1.0 / (1 << 10), 0x00}, // tie with zero, round to even
+			{1.5 / (1 << 9), 0x02},  // tie, round to even
+			{7.5 / (1 << 9), 0x08},  // rounded up to smallest normal
+			{-1e-30, 0x80},
+		}
+		for _, tc := range testCases {
+			assert.Equal(t, tc.want, Float32ToF8E4M3(tc.f), "value %g", tc.f)
+		}
+		assert.Equal(t, uint8(0x7f), Float32ToF8E4M3(float32(math.NaN())))
+		assert.Equal(t, uint8(0xff), Float32ToF8E4M3(float32(math.Copysign(math.NaN(), -1))))
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for i := 0; i < 256; i++ {
+			v := uint8(i)
+			// NaN values are preserved as well, since there is only one.
+			assert.Equal(t, v, Float32ToF8E4M3(F8E4M3ToFloat32(v)), "value %#02x", v)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// maxDims is the maximum number of dimensions of a tensor.
+const maxDims = 4
+
+// kv is a metadata key-value pair.
+type kv struct {
+	key   string
+	value value
+}
+
+// tensorInfo describes a tensor, whose data is stored at the given offset
+// from the start of the data section.
+type tensorInfo struct {
+	name string
+	// dims are the dimensions, from the fastest-varying one.
+	dims   []uint64
+	t      tensorType
+	offset uint64
+}
+
+// file is the content of the header of a GGUF file.
+type file struct {
+	kvs     []kv
+	tensors []tensorInfo
+	// dataStart is the offset of the data section.
+	dataStart int64
+	// alignment is the alignment of tensor data.
+	alignment int64
+}
+
+// decoder reads the header of a GGUF file, keeping track of the position.
+type decoder struct {
+	r    *bufio.Reader
+	pos  int64
+	size int64
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.pos += int64(n)
+	return n, err
+}
+
+// scalarReaders read the metadata values of scalar types.
+var scalarReaders = map[valueType]func(d *decoder) (any, error){
+	typeUint8:   readScalar[uint8],
+	typeInt8:    readScalar[int8],
+	typeUint16:  readScalar[uint16],
+	typeInt16:   readScalar[int16],
+	typeUint32:  readScalar[uint32],
+	typeInt32:   readScalar[int32],
+	typeFloat32: readScalar[float32],
+	typeBool:    readScalar[bool],
+	typeString:  func(d *decoder) (any, error) { return d.readString() },
+	typeUint64:  readScalar[uint64],
+	typeInt64:   readScalar[int64],
+	typeFloat64: readScalar[float64],
+}
+
+// valueSizes are the sizes in bytes of scalar values, or the minimum size
+// for strings.
+var valueSizes = map[valueType]uint64{
+	typeUint8: 1, typeInt8: 1, typeUint16: 2, typeInt16: 2, typeUint32: 4, typeInt32: 4,
+	typeFloat32: 4, typeBool: 1, typeString: 8, typeUint64: 8, typeInt64: 8, typeFloat64: 8,
+}
+
+func readScalar[T any](d *decoder) (any, error) {
+	var v T
+	err := binary.Read(d, binary.LittleEndian, &v)
+	return v, err
+}
+
+func decodeFile(r io.ReaderAt, size int64) (*file, error) {
+	d := &decoder{r: bufio.NewReader(io.NewSectionReader(r, 0, size)), size: size}
+	tensorCount, kvCount, err := d.readPreamble()
+	if err != nil {
+		return nil, err
+	}
+
+	f := new(file)
+	keys := make(map[string]bool)
+	for i := uint64(0); i < kvCount; i++ {
+		p, err := d.readKV()
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		if keys[p.key] {
+			return nil, fmt.Errorf("invalid metadata: duplicate key %q", p.key)
+		}
+		keys[p.key] = true
+		f.kvs = append(f.kvs, p)
+	}
+
+	names := make(map[string]bool)
+	for i := uint64(0); i < tensorCount; i++ {
+		info, err := d.readTensorInfo()
+		if err != nil {
+			return nil, fmt.Errorf("invalid tensor info: %w", err)
+		}
+		if names[info.name] {
+			return nil, fmt.Errorf("invalid tensor info: duplicate tensor %q", info.name)
+		}
+		names[info.name] = true
+		f.tensors = append(f.tensors, info)
+	}
+
+	if f.alignment, err = alignmentOf(f.kvs); err != nil {
+		return nil, err
+	}
+	f.dataStart = alignOffset(d.pos, f.alignment)
+	return f, nil
+}
+
+// readPreamble reads the magic number, the version and the numbers of
+// tensors and metadata key-value pairs.
+func (d *decoder) readPreamble() (tensorCount, kvCount uint64, err error) {
+	var preamble struct {
+		Magic       [4]byte
+		Version     uint32
+		TensorCount uint64
+		KVCount     uint64
+	}
+	if err = binary.Read(d, binary.LittleEndian, &preamble); err != nil {
+		return 0, 0, fmt.Errorf("failed to read GGUF header: %w", err)
+	}
+	if string(preamble.Magic[:]) != magic {
+		return 0, 0, errors.New("invalid GGUF file: magic number not found")
+	}
+	switch v := preamble.Version; {
+	case v == 2 || v == 3:
+	case bits.ReverseBytes32(v) == 2 || bits.ReverseBytes32(v) == 3:
+		return 0, 0, errors.New("big-endian GGUF files are not supported")
+	default:
+		return 0, 0, fmt.Errorf("unsupported GGUF version %d", v)
+	}
+	// Every key-value pair and tensor info takes more than 8 bytes.
+	if err = d.checkCount(preamble.TensorCount, 8); err != nil {
+		return 0, 0, fmt.Errorf("invalid tensor count: %w", err)
+	}
+	if err = d.checkCount(preamble.KVCount, 8); err != nil {
+		return 0, 0, fmt.Errorf("invalid metadata count: %w", err)
+	}
+	return preamble.TensorCount, preamble.KVCount, nil
+}
+
+// checkCount checks that n items of at least itemSize bytes fit in the
+// rest of the file, so that corrupted counts cannot trigger huge
+// allocations.
+func (d *decoder) checkCount(n, itemSize uint64) error {
+	remaining := uint64(d.size - d.pos)
+	if n > remaining/itemSize {
+		return fmt.Errorf("%d exceeds the file size", n)
+	}
+	return nil
+}
+
+func (d *decoder) readString() (string, error) {
+	var n uint64
+	if err := binary.Read(d, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if err := d.checkCount(n, 1); err != nil {
+		return "", fmt.Errorf("invalid string length: %w", err)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) readKV() (kv, error) {
+	key, err := d.readString()
+	if err != nil {
+		return kv{}, err
+	}
+	k, err := d.readKind()
+	if err != nil {
+		return kv{}, fmt.Errorf("key %q: %w", key, err)
+	}
+	v, err := d.readValue(k)
+	if err != nil {
+		return kv{}, fmt.Errorf("key %q: %w", key, err)
+	}
+	return kv{key: key, value: value{kind: k, v: v}}, nil
+}
+
+// readKind reads a value type, which for arrays is followed by the type
+// of their elements. Nested arrays are not supported.
+func (d *decoder) readKind() (kind, error) {
+	t, err := d.readValueType()
+	if err != nil || t != typeArray {
+		return kind{t: t}, err
+	}
+	elem, err := d.readValueType()
+	if err != nil {
+		return kind{}, err
+	}
+	if elem == typeArray {
+		return kind{}, errors.New("nested arrays are not supported")
+	}
+	return kind{t: t, elem: &kind{t: elem}}, nil
+}
+
+func (d *decoder) readValueType() (valueType, error) {
+	var t valueType
+	if err := binary.Read(d, binary.LittleEndian, &t); err != nil {
+		return 0, err
+	}
+	if _, ok := valueTypeNames[t]; !ok && t != typeArray {
+		return 0, fmt.Errorf("invalid value type %d", t)
+	}
+	return t, nil
+}
+
+func (d *decoder) readValue(k kind) (any, error) {
+	if k.t != typeArray {
+		return scalarReaders[k.t](d)
+	}
+	var n uint64
+	if err := binary.Read(d, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if err := d.checkCount(n, valueSizes[k.elem.t]); err != nil {
+		return nil, fmt.Errorf("invalid array length: %w", err)
+	}
+	items := make([]any, n)
+	for i := range items {
+		v, err := scalarReaders[k.elem.t](d)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (d *decoder) readTensorInfo() (tensorInfo, error) {
+	name, err := d.readString()
+	if err != nil {
+		return tensorInfo{}, err
+	}
+	var nDims uint32
+	if err = binary.Read(d, binary.LittleEndian, &nDims); err != nil {
+		return tensorInfo{}, err
+	}
+	if nDims > maxDims {
+		return tensorInfo{}, fmt.Errorf("tensor %q has %d dimensions, maximum %d", name, nDims, maxDims)
+	}
+	info := tensorInfo{name: name, dims: make([]uint64, nDims)}
+	if err = binary.Read(d, binary.LittleEndian, info.dims); err != nil {
+		return tensorInfo{}, err
+	}
+	if err = binary.Read(d, binary.LittleEndian, &info.t); err != nil {
+		return tensorInfo{}, err
+	}
+	if err = binary.Read(d, binary.LittleEndian, &info.offset); err != nil {
+		return tensorInfo{}, err
+	}
+	return info, nil
+}
+
+// alignOffset rounds offset up to a multiple of alignment.
+func alignOffset(offset, alignment int64) int64 {
+	return (offset + alignment - 1) / alignment * alignment
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// version is the GGUF version of written files.
+const version = 3
+
+// encodeHeader returns the header of a GGUF file with the given metadata
+// and tensors, padded to the alignment of the data section.
+func encodeHeader(kvs []kv, tensors []tensorInfo, alignment int64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	writeLE(&buf, uint32(version))
+	writeLE(&buf, uint64(len(tensors)))
+	writeLE(&buf, uint64(len(kvs)))
+
+	for _, p := range kvs {
+		writeString(&buf, p.key)
+		writeLE(&buf, p.value.kind.t)
+		if p.value.kind.t != typeArray {
+			writeScalar(&buf, p.value.v)
+			continue
+		}
+		items := p.value.v.([]any)
+		writeLE(&buf, p.value.kind.elem.t)
+		writeLE(&buf, uint64(len(items)))
+		for _, item := range items {
+			writeScalar(&buf, item)
+		}
+	}
+
+	for _, t := range tensors {
+		writeString(&buf, t.name)
+		writeLE(&buf, uint32(len(t.dims)))
+		writeLE(&buf, t.dims)
+		writeLE(&buf, t.t)
+		writeLE(&buf, t.offset)
+	}
+
+	buf.Write(make([]byte, alignOffset(int64(buf.Len()), alignment)-int64(buf.Len())))
+	return buf.Bytes()
+}
+
+// writeLE writes v in little-endian order. Writes to a bytes.Buffer
+// cannot fail.
+func writeLE(buf *bytes.Buffer, v any) {
+	_ = binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeLE(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func writeScalar(buf *bytes.Buffer, v any) {
+	if s, ok := v.(string); ok {
+		writeString(buf, s)
+		return
+	}
+	writeLE(buf, v)
+}
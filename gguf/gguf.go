@@ -0,0 +1,206 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gguf reads and writes GGUF files, the format used by llama.cpp,
+// converting them from and to safetensors.
+//
+// Only unquantized tensor types with a DType equivalent are supported:
+// F32, F16, BF16, F64, I8, I16, I32 and I64. Quantized block types, such
+// as Q4_0 or Q6_K, are reported as errors.
+//
+// GGUF stores the dimensions of tensors from the fastest-varying one,
+// which are reversed into C-order shapes, and back when writing.
+//
+// GGUF metadata values are typed, while safetensors metadata values are
+// strings. String values are converted as they are, while any other value
+// is encoded as JSON, and its GGUF type is recorded in the metadata under
+// TypesMetadataKey, so that converting a file back to GGUF restores the
+// original types.
+package gguf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// magic is the magic number at the start of GGUF files.
+const magic = "GGUF"
+
+// Load reads a GGUF file from r, which is assumed to have the given size
+// in bytes, returning its tensors and its metadata converted to
+// safetensors metadata.
+func Load(r io.ReaderAt, size int64) (map[string]safetensors.TensorView, map[string]string, error) {
+	f, err := decodeFile(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := toMetadata(f.kvs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tensors := make(map[string]safetensors.TensorView, len(f.tensors))
+	for _, info := range f.tensors {
+		tv, err := readTensor(r, size, f, info)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid tensor %q: %w", info.name, err)
+		}
+		tensors[info.name] = tv
+	}
+	return tensors, metadata, nil
+}
+
+func readTensor(r io.ReaderAt, size int64, f *file, info tensorInfo) (safetensors.TensorView, error) {
+	dType, err := info.t.dType()
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	shape := reversed(info.dims)
+	n, err := tensorio.DataSize(dType.Size(), shape)
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	if info.offset%uint64(f.alignment) != 0 {
+		return safetensors.TensorView{}, fmt.Errorf("offset %d not aligned to %d bytes", info.offset, f.alignment)
+	}
+	if f.dataStart > size || info.offset > uint64(size-f.dataStart) || n > uint64(size-f.dataStart)-info.offset {
+		return safetensors.TensorView{}, fmt.Errorf("data out of bounds: offset %d, size %d", info.offset, n)
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(io.NewSectionReader(r, f.dataStart+int64(info.offset), int64(n)), data); err != nil {
+		return safetensors.TensorView{}, err
+	}
+	return safetensors.NewTensorView(dType, shape, data)
+}
+
+// LoadFile is like Load, reading the named file.
+func LoadFile(name string) (map[string]safetensors.TensorView, map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	return Load(f, fi.Size())
+}
+
+// Save writes the tensors and metadata to w as a GGUF file, with tensors
+// sorted by name.
+//
+// The metadata values are written as strings, unless their types are
+// recorded under TypesMetadataKey. Tensor data is aligned to 32 bytes,
+// unless the "general.alignment" metadata specifies otherwise.
+func Save[V safetensors.View](w io.Writer, tensors map[string]V, metadata map[string]string) error {
+	kvs, err := fromMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	alignment, err := alignmentOf(kvs)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]tensorInfo, len(names))
+	offset := uint64(0)
+	for i, name := range names {
+		info, err := newTensorInfo(name, tensors[name], offset)
+		if err != nil {
+			return fmt.Errorf("invalid tensor %q: %w", name, err)
+		}
+		infos[i] = info
+		offset = uint64(alignOffset(int64(offset+tensors[name].DataLen()), alignment))
+	}
+
+	if _, err = w.Write(encodeHeader(kvs, infos, alignment)); err != nil {
+		return err
+	}
+	return writeData(w, tensors, infos, alignment)
+}
+
+// writeData writes the data of the tensors, in the order of infos, each
+// one padded to the alignment.
+func writeData[V safetensors.View](w io.Writer, tensors map[string]V, infos []tensorInfo, alignment int64) error {
+	padding := make([]byte, alignment)
+	for _, info := range infos {
+		data := tensors[info.name].Data()
+		if uint64(len(data)) != tensors[info.name].DataLen() {
+			return fmt.Errorf("invalid tensor %q: data length %d does not match DataLen %d",
+				info.name, len(data), tensors[info.name].DataLen())
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		n := alignOffset(int64(len(data)), alignment) - int64(len(data))
+		if _, err := w.Write(padding[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTensorInfo(name string, v safetensors.View, offset uint64) (tensorInfo, error) {
+	t, ok := dTypeTensorTypes[v.DType()]
+	if !ok {
+		return tensorInfo{}, fmt.Errorf("DType %s cannot be represented in GGUF", v.DType())
+	}
+	if len(v.Shape()) > maxDims {
+		return tensorInfo{}, fmt.Errorf("%d dimensions, maximum %d", len(v.Shape()), maxDims)
+	}
+	if err := tensorio.CheckDataLen(v.DType().Size(), v.Shape(), v.DataLen()); err != nil {
+		return tensorInfo{}, err
+	}
+	return tensorInfo{name: name, dims: reversed(v.Shape()), t: t, offset: offset}, nil
+}
+
+// ConvertFile converts the GGUF file src to the safetensors file dst,
+// preserving its metadata.
+func ConvertFile(src, dst string) error {
+	tensors, metadata, err := LoadFile(src)
+	if err != nil {
+		return err
+	}
+	return tensorio.CreateFile(dst, func(w io.Writer) error {
+		return safetensors.SerializeToWriter(tensors, metadata, w)
+	})
+}
+
+// ExportFile converts the safetensors file src to the GGUF file dst,
+// preserving its metadata.
+func ExportFile(src, dst string) error {
+	f, err := safetensors.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tensors := make(map[string]safetensors.TensorView, f.Len())
+	for _, t := range f.Tensors() {
+		tensors[t.Name] = t.TensorView
+	}
+	return tensorio.CreateFile(dst, func(w io.Writer) error {
+		return Save(w, tensors, f.Metadata().Metadata())
+	})
+}
+
+// reversed returns a reversed copy of dims.
+func reversed(dims []uint64) []uint64 {
+	r := make([]uint64, len(dims))
+	for i, d := range dims {
+		r[len(dims)-1-i] = d
+	}
+	return r
+}
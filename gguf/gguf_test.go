@@ -0,0 +1,348 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// builder builds GGUF files.
+type builder struct {
+	bytes.Buffer
+}
+
+func (b *builder) le(values ...any) *builder {
+	for _, v := range values {
+		_ = binary.Write(b, binary.LittleEndian, v)
+	}
+	return b
+}
+
+func (b *builder) str(s string) *builder {
+	b.le(uint64(len(s)))
+	b.WriteString(s)
+	return b
+}
+
+func (b *builder) header(version uint32, tensors, kvs uint64) *builder {
+	b.WriteString("GGUF")
+	return b.le(version, tensors, kvs)
+}
+
+func (b *builder) tensor(name string, t uint32, offset uint64, dims ...uint64) *builder {
+	b.str(name).le(uint32(len(dims)), dims, t, offset)
+	return b
+}
+
+func (b *builder) pad() *builder {
+	for b.Len()%32 != 0 {
+		b.WriteByte(0)
+	}
+	return b
+}
+
+func f32Bytes(values ...float32) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return buf.Bytes()
+}
+
+// testFile returns a GGUF file, in the canonical form written by Save.
+func testFile() []byte {
+	b := new(builder).header(3, 2, 4)
+	b.str("general.architecture").le(typeString).str("llama")
+	b.str("llama.context_length").le(typeUint32, uint32(4096))
+	b.str("llama.rope.freq_base").le(typeFloat32, float32(10000))
+	b.str("tokenizer.ggml.tokens").le(typeArray, typeString, uint64(2)).str("a").str("b")
+	b.tensor("b", 30, 0, 2)
+	b.tensor("w", 0, 32, 3, 2)
+	b.pad()
+	b.Write([]byte{1, 2, 3, 4})
+	b.pad()
+	b.Write(f32Bytes(1, 2, 3, 4, 5, 6))
+	b.pad()
+	return b.Bytes()
+}
+
+func TestLoad(t *testing.T) {
+	data := testFile()
+	tensors, metadata, err := Load(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"general.architecture":  "llama",
+		"llama.context_length":  "4096",
+		"llama.rope.freq_base":  "10000",
+		"tokenizer.ggml.tokens": `["a","b"]`,
+		TypesMetadataKey: `{"llama.context_length":"uint32","llama.rope.freq_base":"float32",` +
+			`"tokenizer.ggml.tokens":"array[string]"}`,
+	}, metadata)
+
+	require.Len(t, tensors, 2)
+	assert.Equal(t, safetensors.BF16, tensors["b"].DType())
+	assert.Equal(t, []uint64{2}, tensors["b"].Shape())
+	assert.Equal(t, []byte{1, 2, 3, 4}, tensors["b"].Data())
+	assert.Equal(t, safetensors.F32, tensors["w"].DType())
+	assert.Equal(t, []uint64{2, 3}, tensors["w"].Shape())
+	assert.Equal(t, f32Bytes(1, 2, 3, 4, 5, 6), tensors["w"].Data())
+
+	t.Run("save", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Save(&buf, tensors, metadata))
+		assert.Equal(t, data, buf.Bytes())
+	})
+}
+
+func TestLoadNonFiniteFloats(t *testing.T) {
+	b := new(builder).header(3, 0, 3)
+	b.str("a").le(typeFloat32, math.Float32frombits(0x7fc00000))
+	b.str("b").le(typeFloat64, math.Inf(-1))
+	b.str("c").le(typeArray, typeFloat32, uint64(2), float32(1.5), float32(math.Inf(1)))
+	data := b.pad().Bytes()
+
+	tensors, metadata, err := Load(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"a":              `"NaN"`,
+		"b":              `"-Inf"`,
+		"c":              `[1.5,"+Inf"]`,
+		TypesMetadataKey: `{"a":"float32","b":"float64","c":"array[float32]"}`,
+	}, metadata)
+
+	var buf bytes.Buffer
+	require.NoError(t, Save(&buf, tensors, metadata))
+	assert.Equal(t, data, buf.Bytes())
+
+	metadata["b"] = `"foo"`
+	err = Save(new(bytes.Buffer), tensors, metadata)
+	assert.EqualError(t, err, `invalid value of "b": expected number, actual "foo"`)
+}
+
+func TestLoadErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		file *builder
+		err  string
+	}{
+		{"magic", new(builder).le([]byte("GGML"), uint32(3), uint64(0), uint64(0)), "invalid GGUF file: magic number not found"},
+		{"version 1", new(builder).header(1, 0, 0), "unsupported GGUF version 1"},
+		{"big endian", new(builder).header(3<<24, 0, 0), "big-endian GGUF files are not supported"},
+		{"tensor count", new(builder).header(3, 1<<40, 0), "invalid tensor count: 1099511627776 exceeds the file size"},
+		{
+			"string length",
+			new(builder).header(3, 0, 1).le(uint64(1 << 40)),
+			"invalid metadata: invalid string length: 1099511627776 exceeds the file size",
+		},
+		{
+			"value type",
+			new(builder).header(3, 0, 1).str("a").le(uint32(13)),
+			`invalid metadata: key "a": invalid value type 13`,
+		},
+		{
+			"nested array",
+			new(builder).header(3, 0, 1).str("a").le(typeArray, typeArray, uint64(0)),
+			`invalid metadata: key "a": nested arrays are not supported`,
+		},
+		{
+			"array length",
+			new(builder).header(3, 0, 1).str("a").le(typeArray, typeUint32, uint64(1<<20)),
+			`invalid metadata: key "a": invalid array length: 1048576 exceeds the file size`,
+		},
+		{
+			"duplicate key",
+			new(builder).header(3, 0, 2).str("a").le(typeBool, true).str("a").le(typeBool, true),
+			`invalid metadata: duplicate key "a"`,
+		},
+		{
+			"alignment",
+			new(builder).header(3, 0, 1).str("general.alignment").le(typeUint32, uint32(24)),
+			"invalid metadata: general.alignment must be a power of two of type uint32",
+		},
+		{
+			"reserved key",
+			new(builder).header(3, 0, 1).str(TypesMetadataKey).le(typeBool, true),
+			`reserved metadata key "gguf.types"`,
+		},
+		{
+			"dimensions",
+			new(builder).header(3, 1, 0).tensor("a", 0, 0, 1, 1, 1, 1, 1),
+			`invalid tensor info: tensor "a" has 5 dimensions, maximum 4`,
+		},
+		{
+			"quantized type",
+			new(builder).header(3, 1, 0).tensor("q", 2, 0, 32).pad().le(make([]byte, 18)),
+			`invalid tensor "q": quantized type Q4_0 cannot be represented in safetensors`,
+		},
+		{
+			"unknown type",
+			new(builder).header(3, 1, 0).tensor("a", 100, 0, 1).pad(),
+			`invalid tensor "a": unknown tensor type 100`,
+		},
+		{
+			"unaligned offset",
+			new(builder).header(3, 1, 0).tensor("a", 24, 1, 1).pad().le(make([]byte, 2)),
+			`invalid tensor "a": offset 1 not aligned to 32 bytes`,
+		},
+		{
+			"out of bounds",
+			new(builder).header(3, 1, 0).tensor("a", 0, 0, 2).pad().le(make([]byte, 4)),
+			`invalid tensor "a": data out of bounds: offset 0, size 8`,
+		},
+		{
+			"size overflow",
+			new(builder).header(3, 1, 0).tensor("a", 27, 0, 1<<32, 1<<32).pad(),
+			`invalid tensor "a": shape [4294967296 4294967296]: size overflow`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.file.Bytes()
+			_, _, err := Load(bytes.NewReader(data), int64(len(data)))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	tv, err := safetensors.NewTensorView(safetensors.I64, []uint64{}, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	t.Run("untyped metadata", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Save(&buf, map[string]safetensors.TensorView{"s": tv}, map[string]string{"format": "pt"}))
+		tensors, metadata, err := Load(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"format": "pt"}, metadata)
+		assert.Equal(t, map[string]safetensors.TensorView{"s": tv}, tensors)
+	})
+
+	t.Run("alignment", func(t *testing.T) {
+		var buf bytes.Buffer
+		metadata := map[string]string{"general.alignment": "64", TypesMetadataKey: `{"general.alignment":"uint32"}`}
+		require.NoError(t, Save(&buf, map[string]safetensors.TensorView{"a": tv, "b": tv}, metadata))
+		assert.Equal(t, 0, buf.Len()%64)
+		tensors, actual, err := Load(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		assert.Equal(t, metadata, actual)
+		assert.Len(t, tensors, 2)
+	})
+
+	errorCases := []struct {
+		name     string
+		tensor   safetensors.TensorView
+		metadata map[string]string
+		err      string
+	}{
+		{
+			"unsupported dtype",
+			mustTensorView(t, safetensors.U8, []uint64{1}, []byte{1}),
+			nil,
+			`invalid tensor "t": DType U8 cannot be represented in GGUF`,
+		},
+		{
+			"too many dimensions",
+			mustTensorView(t, safetensors.I8, []uint64{1, 1, 1, 1, 1}, []byte{1}),
+			nil,
+			`invalid tensor "t": 5 dimensions, maximum 4`,
+		},
+		{
+			"invalid types",
+			tv,
+			map[string]string{TypesMetadataKey: "["},
+			`invalid "gguf.types" metadata: unexpected end of JSON input`,
+		},
+		{
+			"invalid type name",
+			tv,
+			map[string]string{"a": "1", TypesMetadataKey: `{"a":"uint128"}`},
+			`invalid value of "a": invalid value type "uint128"`,
+		},
+		{
+			"value out of range",
+			tv,
+			map[string]string{"a": "[1,300]", TypesMetadataKey: `{"a":"array[uint8]"}`},
+			`invalid value of "a": strconv.ParseUint: parsing "300": value out of range`,
+		},
+		{
+			"value of the wrong type",
+			tv,
+			map[string]string{"a": `"x"`, TypesMetadataKey: `{"a":"bool"}`},
+			`invalid value of "a": expected bool, actual x`,
+		},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Save(new(bytes.Buffer), map[string]safetensors.TensorView{"t": tc.tensor}, tc.metadata)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+// lazyView is a View whose data is materialized on each call to Data.
+type lazyView struct {
+	safetensors.TensorView
+	calls *int
+}
+
+func (v lazyView) Data() []byte {
+	*v.calls++
+	return v.TensorView.Data()
+}
+
+func TestSaveLazyView(t *testing.T) {
+	var calls int
+	v := lazyView{TensorView: mustTensorView(t, safetensors.F32, []uint64{2}, f32Bytes(1, 2)), calls: &calls}
+	var buf bytes.Buffer
+	require.NoError(t, Save(&buf, map[string]lazyView{"v": v}, nil))
+	assert.Equal(t, 1, calls)
+
+	short := lazyView{TensorView: mustTensorView(t, safetensors.F32, []uint64{1}, f32Bytes(1)), calls: &calls}
+	err := Save(new(bytes.Buffer), map[string]safetensors.View{"v": shapeView{short, []uint64{2}}}, nil)
+	assert.EqualError(t, err, `invalid tensor "v": expected 8 bytes of data, actual 4`)
+}
+
+// shapeView overrides the shape of a View.
+type shapeView struct {
+	safetensors.View
+	shape []uint64
+}
+
+func (v shapeView) Shape() []uint64 { return v.shape }
+
+func mustTensorView(t *testing.T, dType safetensors.DType, shape []uint64, data []byte) safetensors.TensorView {
+	t.Helper()
+	tv, err := safetensors.NewTensorView(dType, shape, data)
+	require.NoError(t, err)
+	return tv
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "model.gguf")
+	require.NoError(t, os.WriteFile(src, testFile(), 0o600))
+
+	st := filepath.Join(dir, "model.safetensors")
+	require.NoError(t, ConvertFile(src, st))
+	f, err := safetensors.Open(st)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, "llama", f.Metadata().Metadata()["general.architecture"])
+	assert.Equal(t, 2, f.Len())
+
+	dst := filepath.Join(dir, "exported.gguf")
+	require.NoError(t, ExportFile(st, dst))
+	exported, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, testFile(), exported)
+}
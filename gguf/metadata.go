@@ -0,0 +1,256 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TypesMetadataKey is the safetensors metadata key holding the GGUF types
+// of the metadata values which are not strings, as a JSON object mapping
+// keys to type names such as "uint32", "float32" or "array[string]".
+const TypesMetadataKey = "gguf.types"
+
+// alignmentKey is the metadata key of the alignment of tensor data.
+const alignmentKey = "general.alignment"
+
+// defaultAlignment is the alignment of tensor data, unless specified by
+// the "general.alignment" metadata.
+const defaultAlignment = 32
+
+// scalarParsers convert the values decoded from JSON to scalar metadata
+// values.
+var scalarParsers = map[valueType]func(raw any) (any, error){
+	typeUint8:   unsignedParser[uint8](8),
+	typeInt8:    signedParser[int8](8),
+	typeUint16:  unsignedParser[uint16](16),
+	typeInt16:   signedParser[int16](16),
+	typeUint32:  unsignedParser[uint32](32),
+	typeInt32:   signedParser[int32](32),
+	typeFloat32: floatParser[float32](32),
+	typeBool:    parseJSON[bool],
+	typeString:  parseJSON[string],
+	typeUint64:  unsignedParser[uint64](64),
+	typeInt64:   signedParser[int64](64),
+	typeFloat64: floatParser[float64](64),
+}
+
+// toMetadata converts GGUF metadata to safetensors metadata.
+//
+// String values are stored as they are, while other values are encoded as
+// JSON, with their types recorded under TypesMetadataKey. Non-finite
+// floats, which JSON cannot represent, are encoded as the strings "NaN",
+// "+Inf" and "-Inf".
+func toMetadata(kvs []kv) (map[string]string, error) {
+	metadata := make(map[string]string, len(kvs)+1)
+	types := make(map[string]string)
+	for _, p := range kvs {
+		if p.key == TypesMetadataKey {
+			return nil, fmt.Errorf("reserved metadata key %q", p.key)
+		}
+		if s, ok := p.value.v.(string); ok {
+			metadata[p.key] = s
+			continue
+		}
+		b, err := json.Marshal(jsonValue(p.value.v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %q: %w", p.key, err)
+		}
+		metadata[p.key] = string(b)
+		types[p.key] = p.value.kind.String()
+	}
+	if len(types) > 0 {
+		b, err := json.Marshal(types)
+		if err != nil {
+			return nil, err
+		}
+		metadata[TypesMetadataKey] = string(b)
+	}
+	return metadata, nil
+}
+
+// jsonValue returns a metadata value which can be encoded as JSON,
+// replacing non-finite floats with strings.
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case float32:
+		return jsonFloat(float64(x), v)
+	case float64:
+		return jsonFloat(x, v)
+	case []any:
+		// Arrays are homogeneous: only arrays of floats are copied.
+		if len(x) == 0 || !isFloat(x[0]) {
+			return v
+		}
+		items := make([]any, len(x))
+		for i, item := range x {
+			items[i] = jsonValue(item)
+		}
+		return items
+	}
+	return v
+}
+
+func isFloat(v any) bool {
+	switch v.(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
+// jsonFloat returns v, the original value of f, if f is finite, and its
+// string representation otherwise.
+func jsonFloat(f float64, v any) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return v
+}
+
+// fromMetadata converts safetensors metadata to GGUF metadata, sorted by
+// key. Values are strings, unless typed by TypesMetadataKey.
+func fromMetadata(metadata map[string]string) ([]kv, error) {
+	types := make(map[string]string)
+	if s, ok := metadata[TypesMetadataKey]; ok {
+		if err := json.Unmarshal([]byte(s), &types); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %w", TypesMetadataKey, err)
+		}
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if key != TypesMetadataKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	kvs := make([]kv, 0, len(keys))
+	for _, key := range keys {
+		v, err := parseValue(types[key], metadata[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %q: %w", key, err)
+		}
+		kvs = append(kvs, kv{key: key, value: v})
+	}
+	return kvs, nil
+}
+
+// parseValue parses a metadata value of the named type, defaulting to
+// string.
+func parseValue(typeName, s string) (value, error) {
+	if typeName == "" {
+		return value{kind: kind{t: typeString}, v: s}, nil
+	}
+	k, err := parseKind(typeName)
+	if err != nil {
+		return value{}, err
+	}
+	if k.t == typeString {
+		return value{kind: k, v: s}, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var raw any
+	if err = dec.Decode(&raw); err != nil {
+		return value{}, err
+	}
+	if k.t != typeArray {
+		v, err := scalarParsers[k.t](raw)
+		return value{kind: k, v: v}, err
+	}
+
+	items, ok := raw.([]any)
+	if !ok {
+		return value{}, fmt.Errorf("expected %s", k)
+	}
+	for i, item := range items {
+		if items[i], err = scalarParsers[k.elem.t](item); err != nil {
+			return value{}, err
+		}
+	}
+	return value{kind: k, v: items}, nil
+}
+
+func parseJSON[T any](raw any) (any, error) {
+	v, ok := raw.(T)
+	if !ok {
+		return nil, fmt.Errorf("expected %T, actual %v", v, raw)
+	}
+	return v, nil
+}
+
+func number(raw any) (string, error) {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return "", fmt.Errorf("expected number, actual %v", raw)
+	}
+	return string(n), nil
+}
+
+func unsignedParser[T uint8 | uint16 | uint32 | uint64](bitSize int) func(raw any) (any, error) {
+	return func(raw any) (any, error) {
+		s, err := number(raw)
+		if err != nil {
+			return nil, err
+		}
+		u, err := strconv.ParseUint(s, 10, bitSize)
+		return T(u), err
+	}
+}
+
+func signedParser[T int8 | int16 | int32 | int64](bitSize int) func(raw any) (any, error) {
+	return func(raw any) (any, error) {
+		s, err := number(raw)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(s, 10, bitSize)
+		return T(i), err
+	}
+}
+
+func floatParser[T float32 | float64](bitSize int) func(raw any) (any, error) {
+	return func(raw any) (any, error) {
+		// Non-finite values are encoded as strings (see jsonValue).
+		if s, ok := raw.(string); ok {
+			f, err := strconv.ParseFloat(s, bitSize)
+			if err != nil || !(math.IsNaN(f) || math.IsInf(f, 0)) {
+				return nil, fmt.Errorf("expected number, actual %q", s)
+			}
+			return T(f), nil
+		}
+		s, err := number(raw)
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(s, bitSize)
+		return T(f), err
+	}
+}
+
+// alignmentOf returns the alignment of tensor data, which must be a power
+// of two.
+func alignmentOf(kvs []kv) (int64, error) {
+	for _, p := range kvs {
+		if p.key != alignmentKey {
+			continue
+		}
+		a, ok := p.value.v.(uint32)
+		if !ok || a == 0 || a&(a-1) != 0 {
+			return 0, errors.New("invalid metadata: general.alignment must be a power of two of type uint32")
+		}
+		return int64(a), nil
+	}
+	return defaultAlignment, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+)
+
+// tensorType is a GGML tensor type.
+type tensorType uint32
+
+// tensorTypes maps the unquantized GGML tensor types to DTypes.
+var tensorTypes = map[tensorType]safetensors.DType{
+	0:  safetensors.F32,
+	1:  safetensors.F16,
+	24: safetensors.I8,
+	25: safetensors.I16,
+	26: safetensors.I32,
+	27: safetensors.I64,
+	28: safetensors.F64,
+	30: safetensors.BF16,
+}
+
+// dTypeTensorTypes maps DTypes to GGML tensor types.
+var dTypeTensorTypes = func() map[safetensors.DType]tensorType {
+	m := make(map[safetensors.DType]tensorType, len(tensorTypes))
+	for t, dType := range tensorTypes {
+		m[dType] = t
+	}
+	return m
+}()
+
+// quantizedTypeNames are the names of the quantized GGML tensor types,
+// used in error messages.
+var quantizedTypeNames = map[tensorType]string{
+	2: "Q4_0", 3: "Q4_1", 6: "Q5_0", 7: "Q5_1", 8: "Q8_0", 9: "Q8_1",
+	10: "Q2_K", 11: "Q3_K", 12: "Q4_K", 13: "Q5_K", 14: "Q6_K", 15: "Q8_K",
+	16: "IQ2_XXS", 17: "IQ2_XS", 18: "IQ3_XXS", 19: "IQ1_S", 20: "IQ4_NL",
+	21: "IQ3_S", 22: "IQ2_S", 23: "IQ4_XS", 29: "IQ1_M",
+}
+
+// dType returns the DType of the tensor type, or an error for quantized
+// and unknown types.
+func (t tensorType) dType() (safetensors.DType, error) {
+	if dType, ok := tensorTypes[t]; ok {
+		return dType, nil
+	}
+	if name, ok := quantizedTypeNames[t]; ok {
+		return 0, fmt.Errorf("quantized type %s cannot be represented in safetensors", name)
+	}
+	return 0, fmt.Errorf("unknown tensor type %d", t)
+}
+
+// valueType is the type of a metadata value.
+type valueType uint32
+
+const (
+	typeUint8 valueType = iota
+	typeInt8
+	typeUint16
+	typeInt16
+	typeUint32
+	typeInt32
+	typeFloat32
+	typeBool
+	typeString
+	typeArray
+	typeUint64
+	typeInt64
+	typeFloat64
+)
+
+var valueTypeNames = map[valueType]string{
+	typeUint8:   "uint8",
+	typeInt8:    "int8",
+	typeUint16:  "uint16",
+	typeInt16:   "int16",
+	typeUint32:  "uint32",
+	typeInt32:   "int32",
+	typeFloat32: "float32",
+	typeBool:    "bool",
+	typeString:  "string",
+	typeUint64:  "uint64",
+	typeInt64:   "int64",
+	typeFloat64: "float64",
+}
+
+var valueTypesByName = func() map[string]valueType {
+	m := make(map[string]valueType, len(valueTypeNames))
+	for t, name := range valueTypeNames {
+		m[name] = t
+	}
+	return m
+}()
+
+// kind is the full type of a metadata value, including the type of the
+// elements of arrays, which cannot be arrays themselves.
+type kind struct {
+	t valueType
+	// elem is the kind of the elements of arrays.
+	elem *kind
+}
+
+// String returns the name of the kind, such as "uint32" or
+// "array[string]".
+func (k kind) String() string {
+	if k.t == typeArray {
+		return "array[" + k.elem.String() + "]"
+	}
+	return valueTypeNames[k.t]
+}
+
+// parseKind parses the name of a kind, as returned by kind.String.
+func parseKind(s string) (kind, error) {
+	name := s
+	if strings.HasPrefix(s, "array[") && strings.HasSuffix(s, "]") {
+		name = s[len("array[") : len(s)-1]
+	}
+	t, ok := valueTypesByName[name]
+	if !ok {
+		return kind{}, fmt.Errorf("invalid value type %q", s)
+	}
+	if name != s {
+		return kind{t: typeArray, elem: &kind{t: t}}, nil
+	}
+	return kind{t: t}, nil
+}
+
+// value is a metadata value.
+//
+// Scalars are stored as uint8, int8, uint16, int16, uint32, int32,
+// float32, bool, string, uint64, int64 and float64, and arrays as []any.
+type value struct {
+	kind kind
+	v    any
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tensorio provides helpers shared by the packages reading and
+// writing tensors in other formats.
+package tensorio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+)
+
+// DataSize returns the size in bytes of a tensor of the given shape, with
+// elements of elemSize bytes, reporting overflows as errors.
+func DataSize(elemSize uint64, shape []uint64) (uint64, error) {
+	size := elemSize
+	for _, dim := range shape {
+		hi, lo := bits.Mul64(size, dim)
+		if hi != 0 {
+			return 0, fmt.Errorf("shape %v: size overflow", shape)
+		}
+		size = lo
+	}
+	if size > math.MaxInt64 {
+		return 0, fmt.Errorf("shape %v: size overflow", shape)
+	}
+	return size, nil
+}
+
+// CheckDataLen reports an error unless dataLen is the size in bytes of
+// a tensor of the given shape, with elements of elemSize bytes. It allows
+// validating a View without materializing its data.
+func CheckDataLen(elemSize uint64, shape []uint64, dataLen uint64) error {
+	size, err := DataSize(elemSize, shape)
+	if err != nil {
+		return err
+	}
+	if size != dataLen {
+		return fmt.Errorf("expected %d bytes of data, actual %d", size, dataLen)
+	}
+	return nil
+}
+
+// SwapBytes reverses the byte order of each element of the given size.
+func SwapBytes(b []byte, size int) {
+	if size <= 1 {
+		return
+	}
+	for i := 0; i+size <= len(b); i += size {
+		e := b[i : i+size]
+		for l, r := 0, size-1; l < r; l, r = l+1, r-1 {
+			e[l], e[r] = e[r], e[l]
+		}
+	}
+}
+
+// Gather appends to out the elements of a strided view over data, in
+// C order. The offset and strides are measured in elements of the given
+// size in bytes.
+func Gather(out, data []byte, shape, stride []uint64, offset, size uint64) []byte {
+	if len(shape) == 0 {
+		return append(out, data[offset*size:(offset+1)*size]...)
+	}
+	if isContiguous(shape, stride) {
+		n := uint64(1)
+		for _, dim := range shape {
+			n *= dim
+		}
+		return append(out, data[offset*size:(offset+n)*size]...)
+	}
+	for i := uint64(0); i < shape[0]; i++ {
+		out = Gather(out, data, shape[1:], stride[1:], offset+i*stride[0], size)
+	}
+	return out
+}
+
+// isContiguous reports whether the strides describe a C-contiguous layout.
+// The strides of dimensions of size 1 are irrelevant.
+func isContiguous(shape, stride []uint64) bool {
+	expected := uint64(1)
+	for i := len(shape) - 1; i >= 0; i-- {
+		if shape[i] != 1 && stride[i] != expected {
+			return false
+		}
+		expected *= shape[i]
+	}
+	return true
+}
+
+// CreateFile creates the named file and writes it with write, removing it
+// on error.
+func CreateFile(name string, write func(w io.Writer) error) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(name)
+		}
+	}()
+	return write(f)
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tensorio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSize(t *testing.T) {
+	size, err := DataSize(4, []uint64{2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(24), size)
+
+	size, err = DataSize(8, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), size)
+
+	_, err = DataSize(8, []uint64{1 << 32, 1 << 32})
+	assert.EqualError(t, err, "shape [4294967296 4294967296]: size overflow")
+	_, err = DataSize(2, []uint64{1 << 63})
+	assert.EqualError(t, err, "shape [9223372036854775808]: size overflow")
+}
+
+func TestCheckDataLen(t *testing.T) {
+	assert.NoError(t, CheckDataLen(2, []uint64{3}, 6))
+	assert.EqualError(t, CheckDataLen(2, []uint64{3}, 4), "expected 6 bytes of data, actual 4")
+	assert.Error(t, CheckDataLen(8, []uint64{1 << 62}, 0))
+}
+
+func TestSwapBytes(t *testing.T) {
+	b := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	SwapBytes(b, 1)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, b)
+	SwapBytes(b, 2)
+	assert.Equal(t, []byte{2, 1, 4, 3, 6, 5, 8, 7}, b)
+	SwapBytes(b, 2)
+	SwapBytes(b, 4)
+	assert.Equal(t, []byte{4, 3, 2, 1, 8, 7, 6, 5}, b)
+	SwapBytes(b, 4)
+	SwapBytes(b, 8)
+	assert.Equal(t, []byte{8, 7, 6, 5, 4, 3, 2, 1}, b)
+}
+
+func TestGather(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5}
+	// Transposed view of a 2x3 array.
+	assert.Equal(t, []byte{0, 3, 1, 4, 2, 5}, Gather(nil, data, []uint64{3, 2}, []uint64{1, 3}, 0, 1))
+	// Contiguous view, with an offset.
+	assert.Equal(t, []byte{2, 3, 4, 5}, Gather(nil, data, []uint64{2, 2}, []uint64{2, 1}, 2, 1))
+	// Every other element of 2 bytes.
+	assert.Equal(t, []byte{0, 1, 4, 5}, Gather(nil, data, []uint64{2}, []uint64{2}, 0, 2))
+}
+
+func TestCreateFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, CreateFile(name, func(w io.Writer) error {
+		_, err := w.Write([]byte("foo"))
+		return err
+	}))
+	content, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	err = CreateFile(name, func(w io.Writer) error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+	assert.NoFileExists(t, name)
+}
@@ -0,0 +1,618 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// Marshal returns the safetensors encoding of v, whose fields are mapped
+// to tensors as described for MarshalTensors.
+func Marshal(v any) ([]byte, error) {
+	tensors, err := MarshalTensors(v)
+	if err != nil {
+		return nil, err
+	}
+	return Serialize(tensors, nil)
+}
+
+// MarshalTensors maps the fields of v, which must be a struct or a pointer
+// to a struct, to named tensors.
+//
+// The name of each tensor is given by the "safetensors" key in the field's
+// tag, or defaults to the field's name. A tag value of "-" excludes the
+// field. Unexported fields are always excluded.
+//
+// Fields of the following types are mapped to tensors: slices of bool,
+// int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32 and
+// float64; (possibly nested) arrays of such types; slices of such arrays.
+// By default, the shape of the tensor reflects the lengths of slices and
+// arrays. A different shape can be given either statically, with a
+// "shape" tag such as `shape:"2,3"`, or dynamically, with the "shape"
+// option of the "safetensors" tag referring to another field of type
+// []uint64 holding the shape, such as `safetensors:"weight,shape=WShape"`.
+// The field holding the shape is not a tensor itself.
+//
+// Fields of struct type, or pointer to struct, contribute with their own
+// fields, whose names are prefixed by the name of the struct field and
+// a dot, for example "attn.weight". Slices and arrays of structs, or of
+// pointers to structs, work the same way, with the item index acting as
+// further prefix, for example "layers.0.attn.weight". Nil pointers are
+// skipped. Embedded structs without a tag have their fields promoted,
+// with no additional prefix.
+//
+// Fields of other types are ignored, unless explicitly tagged, in which
+// case an error is returned.
+//
+// The data of the returned tensors is a copy of the values of v.
+func MarshalTensors(v any) (map[string]TensorView, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %T: %w", v, err)
+	}
+	tensors := make(map[string]TensorView)
+	if err = marshalStruct(tensors, "", rv); err != nil {
+		return nil, err
+	}
+	return tensors, nil
+}
+
+// Unmarshal copies the tensors from st to the fields of the struct pointed
+// to by v, following the same rules described for MarshalTensors.
+//
+// Slices are allocated with the appropriate length. Nil pointers to
+// structs are allocated as well, unless no tensor name has their prefix,
+// in which case they are left nil. The length of slices of structs is
+// inferred from the highest index found among the tensor names.
+// The DType of each tensor must exactly match the Go type of its field.
+// Fields with a static "shape" tag require the tensor to have the same
+// shape, while fields with a "shape" option are set to the tensor's shape.
+//
+// If some tensors are missing from st, or st contains tensors not
+// corresponding to any field, a *MismatchError is returned, after all
+// other fields have been set.
+func Unmarshal(st SafeTensors, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot unmarshal into %T: expected non-nil pointer to struct", v)
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal into %T: %w", v, err)
+	}
+
+	u := unmarshaler{st: st, used: make(map[string]bool, st.Len())}
+	if err = u.unmarshalStruct("", rv); err != nil {
+		return err
+	}
+
+	var unexpected []string
+	for _, name := range st.Names() {
+		if !u.used[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	if len(u.missing) > 0 || len(unexpected) > 0 {
+		sort.Strings(u.missing)
+		sort.Strings(unexpected)
+		return &MismatchError{Missing: u.missing, Unexpected: unexpected}
+	}
+	return nil
+}
+
+// MismatchError reports the differences between the tensors expected by
+// Unmarshal and the ones actually available.
+type MismatchError struct {
+	// Missing are the sorted names of expected tensors not found.
+	Missing []string
+	// Unexpected are the sorted names of available tensors not expected.
+	Unexpected []string
+}
+
+func (e *MismatchError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing tensors %q", e.Missing))
+	}
+	if len(e.Unexpected) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected tensors %q", e.Unexpected))
+	}
+	return "tensors mismatch: " + strings.Join(parts, ", ")
+}
+
+// structValue dereferences v, which must be a struct or a pointer to it.
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected struct or pointer to struct")
+	}
+	return rv, nil
+}
+
+// fieldKind describes how a struct field is mapped to tensors.
+type fieldKind uint8
+
+const (
+	fieldIgnored fieldKind = iota
+	fieldTensor
+	fieldStruct
+	fieldStructList
+)
+
+// structField describes a struct field mapped to tensors.
+type structField struct {
+	index int
+	kind  fieldKind
+	// name is the tensor name or prefix, empty for embedded structs.
+	name string
+	// shape is the static shape from the "shape" tag, if any.
+	shape []uint64
+	// shapeField is the index of the field holding the shape, or -1.
+	shapeField int
+	layout     tensorLayout
+}
+
+// prefix returns the prefix of the names of the tensors of a nested struct.
+func (f *structField) prefix(parent string) string {
+	if f.name == "" {
+		return parent
+	}
+	return parent + f.name + "."
+}
+
+func structFields(t reflect.Type) ([]structField, error) {
+	shapeFields := make(map[string]bool)
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("safetensors")
+		if !isMarshalableField(sf) || tag == "-" {
+			continue
+		}
+		f, shapeFieldName, err := parseStructField(t, i, tag)
+		if err == nil && f.kind == fieldIgnored && tagged {
+			err = fmt.Errorf("unsupported type %s", sf.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %s: %w", qualifiedFieldName(t, sf), err)
+		}
+		if shapeFieldName != "" {
+			shapeFields[shapeFieldName] = true
+		}
+		fields = append(fields, f)
+	}
+
+	return filterFields(t, fields, shapeFields), nil
+}
+
+// isMarshalableField reports whether the field is exported, or is an
+// embedded struct, whose exported fields are promoted.
+func isMarshalableField(sf reflect.StructField) bool {
+	return sf.IsExported() || (sf.Anonymous && sf.Type.Kind() == reflect.Struct)
+}
+
+func qualifiedFieldName(t reflect.Type, sf reflect.StructField) string {
+	if t.Name() == "" {
+		return sf.Name
+	}
+	return t.Name() + "." + sf.Name
+}
+
+// filterFields removes ignored fields, and fields holding shapes, which
+// are not tensors.
+func filterFields(t reflect.Type, fields []structField, shapeFields map[string]bool) []structField {
+	result := fields[:0]
+	for _, f := range fields {
+		if f.kind != fieldIgnored && !shapeFields[t.Field(f.index).Name] {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+func parseStructField(t reflect.Type, index int, tag string) (structField, string, error) {
+	sf := t.Field(index)
+	name, options, _ := strings.Cut(tag, ",")
+	f := structField{
+		index:      index,
+		kind:       classifyField(sf.Type),
+		name:       name,
+		shapeField: -1,
+	}
+	if f.name == "" && !(sf.Anonymous && f.kind == fieldStruct) {
+		f.name = sf.Name
+	}
+	if f.kind == fieldTensor {
+		f.layout, _ = newTensorLayout(sf.Type)
+	}
+
+	if s, ok := sf.Tag.Lookup("shape"); ok {
+		shape, err := parseShapeTag(s)
+		if err != nil {
+			return structField{}, "", err
+		}
+		f.shape = shape
+	}
+
+	shapeFieldName, err := parseShapeOption(options)
+	if err != nil || shapeFieldName == "" {
+		return f, "", err
+	}
+	f.shapeField, err = findShapeField(t, shapeFieldName)
+	if err != nil {
+		return structField{}, "", err
+	}
+	return f, shapeFieldName, nil
+}
+
+func findShapeField(t reflect.Type, name string) (int, error) {
+	sf, ok := t.FieldByName(name)
+	if !ok || len(sf.Index) != 1 || sf.Type != reflect.TypeOf([]uint64(nil)) {
+		return -1, fmt.Errorf("shape field %q not found or not of type []uint64", name)
+	}
+	return sf.Index[0], nil
+}
+
+func parseShapeTag(s string) ([]uint64, error) {
+	shape := make([]uint64, 0)
+	for _, dim := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(dim), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shape tag %q: %w", s, err)
+		}
+		shape = append(shape, n)
+	}
+	return shape, nil
+}
+
+func parseShapeOption(options string) (string, error) {
+	for _, opt := range strings.Split(options, ",") {
+		if opt == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(opt, "=")
+		if key != "shape" || value == "" {
+			return "", fmt.Errorf("invalid tag option %q", opt)
+		}
+		return value, nil
+	}
+	return "", nil
+}
+
+func classifyField(t reflect.Type) fieldKind {
+	if isStructOrPointer(t) {
+		return fieldStruct
+	}
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && isStructOrPointer(t.Elem()) {
+		return fieldStructList
+	}
+	if _, ok := newTensorLayout(t); ok {
+		return fieldTensor
+	}
+	return fieldIgnored
+}
+
+func isStructOrPointer(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct)
+}
+
+// elementKinds maps the kinds of the Go types supported as tensor elements
+// to their DType.
+var elementKinds = map[reflect.Kind]DType{
+	reflect.Bool:    BOOL,
+	reflect.Uint8:   U8,
+	reflect.Int8:    I8,
+	reflect.Int16:   I16,
+	reflect.Uint16:  U16,
+	reflect.Int32:   I32,
+	reflect.Uint32:  U32,
+	reflect.Float32: F32,
+	reflect.Float64: F64,
+	reflect.Int64:   I64,
+	reflect.Uint64:  U64,
+}
+
+// tensorLayout describes how a Go type is mapped to a tensor.
+type tensorLayout struct {
+	dType DType
+	// dynamic reports whether the outermost dimension is a slice.
+	dynamic bool
+	// dims are the lengths of (nested) arrays.
+	dims []uint64
+	// items is the amount of elements for each item of the outermost
+	// slice or array.
+	items uint64
+}
+
+func newTensorLayout(t reflect.Type) (tensorLayout, bool) {
+	var l tensorLayout
+	if t.Kind() == reflect.Slice {
+		l.dynamic = true
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Array {
+		l.dims = append(l.dims, uint64(t.Len()))
+		t = t.Elem()
+	}
+	dType, ok := elementKinds[t.Kind()]
+	if !ok || (!l.dynamic && len(l.dims) == 0) {
+		return tensorLayout{}, false
+	}
+	l.dType = dType
+	// For slices, items are counted per slice element, otherwise per
+	// element of the outermost array. Any dimension might be zero.
+	itemDims := l.dims
+	if !l.dynamic {
+		itemDims = l.dims[1:]
+	}
+	l.items = 1
+	for _, dim := range itemDims {
+		l.items *= dim
+	}
+	return l, true
+}
+
+func marshalStruct(tensors map[string]TensorView, prefix string, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for i := range fields {
+		if err = marshalField(tensors, prefix, v, &fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalField(tensors map[string]TensorView, prefix string, sv reflect.Value, f *structField) error {
+	fv := sv.Field(f.index)
+	switch f.kind {
+	case fieldStruct:
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			return nil
+		}
+		return marshalStruct(tensors, f.prefix(prefix), reflect.Indirect(fv))
+	case fieldStructList:
+		for i := 0; i < fv.Len(); i++ {
+			item := fv.Index(i)
+			if item.Kind() == reflect.Pointer && item.IsNil() {
+				continue
+			}
+			err := marshalStruct(tensors, prefix+f.name+"."+strconv.Itoa(i)+".", reflect.Indirect(item))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		tv, err := marshalTensor(fv, f, sv)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tensor %q: %w", prefix+f.name, err)
+		}
+		tensors[prefix+f.name] = tv
+		return nil
+	}
+}
+
+func marshalTensor(fv reflect.Value, f *structField, sv reflect.Value) (TensorView, error) {
+	shape := f.shape
+	if f.shapeField >= 0 {
+		shape = sv.Field(f.shapeField).Interface().([]uint64)
+	}
+	if shape == nil {
+		shape = f.layout.dims
+		if f.layout.dynamic {
+			shape = append([]uint64{uint64(fv.Len())}, f.layout.dims...)
+		}
+	}
+
+	data := make([]byte, fv.Len()*int(fv.Type().Elem().Size()))
+	copy(data, valueBytes(fv))
+	if !hostLittleEndian {
+		tensorio.SwapBytes(data, int(f.layout.dType.Size()))
+	}
+	return NewTensorView(f.layout.dType, shape, data)
+}
+
+// valueBytes returns the memory of the elements of a slice or array value,
+// without copying it, unless the value is not addressable.
+func valueBytes(v reflect.Value) []byte {
+	size := v.Len() * int(v.Type().Elem().Size())
+	if size == 0 {
+		return nil
+	}
+	if v.Kind() == reflect.Slice {
+		return unsafe.Slice((*byte)(v.UnsafePointer()), size)
+	}
+	if !v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	return unsafe.Slice((*byte)(v.Addr().UnsafePointer()), size)
+}
+
+type unmarshaler struct {
+	st      SafeTensors
+	used    map[string]bool
+	missing []string
+}
+
+func (u *unmarshaler) unmarshalStruct(prefix string, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for i := range fields {
+		if err = u.unmarshalField(prefix, v, &fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalField(prefix string, sv reflect.Value, f *structField) error {
+	fv := sv.Field(f.index)
+	switch f.kind {
+	case fieldStruct:
+		if fv.Kind() == reflect.Pointer && fv.IsNil() && !u.hasPrefix(f.prefix(prefix)) {
+			// Leave it nil, rather than reporting all its tensors as missing.
+			return nil
+		}
+		return u.unmarshalStruct(f.prefix(prefix), allocIndirect(fv))
+	case fieldStructList:
+		return u.unmarshalStructList(prefix+f.name, fv)
+	default:
+		name := prefix + f.name
+		tv, ok := u.st.Tensor(name)
+		if !ok {
+			u.missing = append(u.missing, name)
+			return nil
+		}
+		u.used[name] = true
+		if err := unmarshalTensor(tv, fv, f, sv); err != nil {
+			return fmt.Errorf("failed to unmarshal tensor %q: %w", name, err)
+		}
+		return nil
+	}
+}
+
+func (u *unmarshaler) unmarshalStructList(name string, fv reflect.Value) error {
+	if fv.Kind() == reflect.Slice {
+		n := u.countItems(name + ".")
+		fv.Set(reflect.MakeSlice(fv.Type(), n, n))
+	}
+	for i := 0; i < fv.Len(); i++ {
+		err := u.unmarshalStruct(name+"."+strconv.Itoa(i)+".", allocIndirect(fv.Index(i)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasPrefix reports whether any tensor name has the given prefix.
+func (u *unmarshaler) hasPrefix(prefix string) bool {
+	for _, name := range u.st.Names() {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// countItems returns the highest index plus one among the names of the
+// tensors in the form "<prefix><index>.<anything>".
+func (u *unmarshaler) countItems(prefix string) int {
+	n := 0
+	for _, name := range u.st.Names() {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		index, _, ok := strings.Cut(rest, ".")
+		if i, err := strconv.Atoi(index); ok && err == nil && i >= n {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// allocIndirect dereferences v if it is a pointer, allocating a new value
+// if it is nil.
+func allocIndirect(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Pointer {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
+
+func unmarshalTensor(tv TensorView, fv reflect.Value, f *structField, sv reflect.Value) error {
+	numElements, err := checkUnmarshalTensor(tv, fv.Type(), f)
+	if err != nil {
+		return err
+	}
+	if f.layout.dynamic {
+		// With zero-sized items, the slice is left empty.
+		n := 0
+		if f.layout.items > 0 {
+			n = int(numElements / f.layout.items)
+		}
+		fv.Set(reflect.MakeSlice(fv.Type(), n, n))
+	}
+	if f.shapeField >= 0 {
+		sv.Field(f.shapeField).Set(reflect.ValueOf(append([]uint64{}, tv.Shape()...)))
+	}
+
+	data := valueBytes(fv)
+	copy(data, tv.Data())
+	if !hostLittleEndian {
+		tensorio.SwapBytes(data, int(tv.DType().Size()))
+	}
+	if tv.DType() == BOOL {
+		// Any byte other than 0 and 1 is not a valid Go bool.
+		for i, b := range data {
+			if b > 1 {
+				data[i] = 1
+			}
+		}
+	}
+	return nil
+}
+
+// checkUnmarshalTensor checks whether the tensor can be unmarshaled into
+// a field, returning its number of elements.
+func checkUnmarshalTensor(tv TensorView, t reflect.Type, f *structField) (uint64, error) {
+	if tv.DType() != f.layout.dType {
+		return 0, fmt.Errorf("cannot unmarshal DType %s into Go type %s", tv.DType(), t)
+	}
+	if f.shape != nil && !equalShapes(f.shape, tv.Shape()) {
+		return 0, fmt.Errorf("shape mismatch: expected %v, actual %v", f.shape, tv.Shape())
+	}
+	numElements := tv.DataLen() / tv.DType().Size()
+	if !validElementCount(numElements, f.layout) {
+		return 0, fmt.Errorf("cannot unmarshal %d elements into Go type %s", numElements, t)
+	}
+	return numElements, nil
+}
+
+// validElementCount reports whether a tensor with n elements fits the
+// layout of a Go type.
+func validElementCount(n uint64, l tensorLayout) bool {
+	switch {
+	case l.items == 0:
+		return n == 0
+	case l.dynamic:
+		return n%l.items == 0
+	default:
+		return n == l.items*l.dims[0]
+	}
+}
+
+func equalShapes(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
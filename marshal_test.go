@@ -0,0 +1,252 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAttention struct {
+	Weight []float32 `safetensors:"weight,shape=WeightShape"`
+	Bias   []float32 `safetensors:"bias"`
+
+	WeightShape []uint64
+}
+
+type testNorm struct {
+	Scale [2]float64 `safetensors:"scale"`
+}
+
+type testLayer struct {
+	Attn testAttention `safetensors:"attn"`
+	Norm *testNorm     `safetensors:"norm"`
+}
+
+type testEmbedded struct {
+	Steps []int64 `safetensors:"steps"`
+}
+
+type testModel struct {
+	testEmbedded
+
+	Embeddings [][3]float32 `safetensors:"wte"`
+	Mask       []bool       `safetensors:"mask" shape:"2,2"`
+	Table      [2][2]uint16 `safetensors:"table"`
+	Layers     []testLayer  `safetensors:"layers"`
+	Ignored    []float32    `safetensors:"-"`
+	NumHeads   int
+	unexported []float32
+}
+
+func newTestModel() testModel {
+	return testModel{
+		testEmbedded: testEmbedded{Steps: []int64{42}},
+		Embeddings:   [][3]float32{{1, 2, 3}, {4, 5, 6}},
+		Mask:         []bool{true, false, false, true},
+		Table:        [2][2]uint16{{1, 2}, {3, 4}},
+		Layers: []testLayer{
+			{
+				Attn: testAttention{
+					Weight:      []float32{1, 2, 3, 4, 5, 6},
+					Bias:        []float32{7, 8},
+					WeightShape: []uint64{3, 2},
+				},
+				Norm: &testNorm{Scale: [2]float64{0.5, 1.5}},
+			},
+			{
+				Attn: testAttention{
+					Weight:      []float32{9, 10},
+					Bias:        []float32{11},
+					WeightShape: []uint64{1, 2},
+				},
+			},
+		},
+		Ignored:  []float32{1},
+		NumHeads: 12,
+	}
+}
+
+func TestMarshalTensors(t *testing.T) {
+	tensors, err := MarshalTensors(newTestModel())
+	require.NoError(t, err)
+
+	expected := map[string]struct {
+		dType DType
+		shape []uint64
+	}{
+		"steps":                {I64, []uint64{1}},
+		"wte":                  {F32, []uint64{2, 3}},
+		"mask":                 {BOOL, []uint64{2, 2}},
+		"table":                {U16, []uint64{2, 2}},
+		"layers.0.attn.weight": {F32, []uint64{3, 2}},
+		"layers.0.attn.bias":   {F32, []uint64{2}},
+		"layers.0.norm.scale":  {F64, []uint64{2}},
+		"layers.1.attn.weight": {F32, []uint64{1, 2}},
+		"layers.1.attn.bias":   {F32, []uint64{1}},
+	}
+	require.Len(t, tensors, len(expected))
+	for name, e := range expected {
+		tv, ok := tensors[name]
+		require.Truef(t, ok, "tensor %q", name)
+		assert.Equal(t, e.dType, tv.DType(), "tensor %q", name)
+		assert.Equal(t, e.shape, tv.Shape(), "tensor %q", name)
+	}
+
+	values, err := Values[float32](tensors["wte"])
+	require.NoError(t, err)
+	assert.Equal(t, []float32{1, 2, 3, 4, 5, 6}, values)
+	assert.Equal(t, []byte{1, 0, 0, 1}, tensors["mask"].Data())
+	assert.Equal(t, []byte{1, 0, 2, 0, 3, 0, 4, 0}, tensors["table"].Data())
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	model := newTestModel()
+	out, err := Marshal(&model)
+	require.NoError(t, err)
+	st, err := Deserialize(out)
+	require.NoError(t, err)
+
+	var loaded testModel
+	require.NoError(t, Unmarshal(st, &loaded))
+
+	model.Ignored = nil
+	model.NumHeads = 0
+	assert.Equal(t, model, loaded)
+}
+
+func TestMarshalZeroLength(t *testing.T) {
+	type zero struct {
+		A [0]float32    `safetensors:"a"`
+		B []float32     `safetensors:"b"`
+		C [][0]float32  `safetensors:"c"`
+		D [2][0]float32 `safetensors:"d"`
+	}
+	out, err := Marshal(zero{B: []float32{1}, C: make([][0]float32, 3)})
+	require.NoError(t, err)
+	st, err := Deserialize(out)
+	require.NoError(t, err)
+
+	for name, shape := range map[string][]uint64{"a": {0}, "b": {1}, "c": {3, 0}, "d": {2, 0}} {
+		tv, ok := st.Tensor(name)
+		require.Truef(t, ok, "tensor %q", name)
+		assert.Equal(t, shape, tv.Shape(), "tensor %q", name)
+	}
+
+	var loaded zero
+	require.NoError(t, Unmarshal(st, &loaded))
+	assert.Equal(t, zero{B: []float32{1}, C: [][0]float32{}}, loaded)
+
+	t.Run("mismatch", func(t *testing.T) {
+		var v struct {
+			A [0]float32 `safetensors:"b"`
+		}
+		assert.EqualError(t, Unmarshal(st, &v), `failed to unmarshal tensor "b": cannot unmarshal 1 elements into Go type [0]float32`)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("mismatch", func(t *testing.T) {
+		type small struct {
+			A []float32 `safetensors:"a"`
+			B []float32 `safetensors:"b"`
+		}
+		type other struct {
+			A []float32 `safetensors:"a"`
+			C []float32 `safetensors:"c"`
+		}
+		out, err := Marshal(other{A: []float32{1}, C: []float32{2}})
+		require.NoError(t, err)
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+
+		var v small
+		err = Unmarshal(st, &v)
+		assert.EqualError(t, err, `tensors mismatch: missing tensors ["b"], unexpected tensors ["c"]`)
+
+		var mismatch *MismatchError
+		require.ErrorAs(t, err, &mismatch)
+		assert.Equal(t, []string{"b"}, mismatch.Missing)
+		assert.Equal(t, []string{"c"}, mismatch.Unexpected)
+		assert.Equal(t, []float32{1}, v.A)
+	})
+
+	t.Run("DType mismatch", func(t *testing.T) {
+		out, err := Marshal(struct {
+			A []float32 `safetensors:"a"`
+		}{A: []float32{1}})
+		require.NoError(t, err)
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+
+		var v struct {
+			A []int32 `safetensors:"a"`
+		}
+		err = Unmarshal(st, &v)
+		assert.EqualError(t, err, `failed to unmarshal tensor "a": cannot unmarshal DType F32 into Go type []int32`)
+	})
+
+	t.Run("shape mismatch", func(t *testing.T) {
+		out, err := Marshal(struct {
+			A []float32 `safetensors:"a"`
+		}{A: []float32{1, 2, 3}})
+		require.NoError(t, err)
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+
+		var v1 struct {
+			A []float32 `safetensors:"a" shape:"1,3"`
+		}
+		err = Unmarshal(st, &v1)
+		assert.EqualError(t, err, `failed to unmarshal tensor "a": shape mismatch: expected [1 3], actual [3]`)
+
+		var v2 struct {
+			A [2]float32 `safetensors:"a"`
+		}
+		err = Unmarshal(st, &v2)
+		assert.EqualError(t, err, `failed to unmarshal tensor "a": cannot unmarshal 3 elements into Go type [2]float32`)
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		err := Unmarshal(SafeTensors{}, testModel{})
+		assert.EqualError(t, err, "cannot unmarshal into safetensors.testModel: expected non-nil pointer to struct")
+
+		var n int
+		err = Unmarshal(SafeTensors{}, &n)
+		assert.EqualError(t, err, "cannot unmarshal into *int: expected struct or pointer to struct")
+	})
+}
+
+func TestMarshalTensors_Errors(t *testing.T) {
+	_, err := MarshalTensors(42)
+	assert.EqualError(t, err, "cannot marshal int: expected struct or pointer to struct")
+
+	_, err = MarshalTensors(struct {
+		A string `safetensors:"a"`
+	}{})
+	assert.EqualError(t, err, "invalid field A: unsupported type string")
+
+	_, err = MarshalTensors(struct {
+		A []float32 `safetensors:"a" shape:"2,x"`
+	}{})
+	assert.ErrorContains(t, err, `invalid field A: invalid shape tag "2,x"`)
+
+	_, err = MarshalTensors(struct {
+		A []float32 `safetensors:"a,shape=S"`
+	}{})
+	assert.EqualError(t, err, `invalid field A: shape field "S" not found or not of type []uint64`)
+
+	_, err = MarshalTensors(struct {
+		A []float32 `safetensors:"a,foo"`
+	}{})
+	assert.EqualError(t, err, `invalid field A: invalid tag option "foo"`)
+
+	_, err = MarshalTensors(struct {
+		A []float32 `safetensors:"a" shape:"2,2"`
+	}{A: []float32{1, 2, 3}})
+	assert.ErrorContains(t, err, `failed to marshal tensor "a": invalid tensor view`)
+}
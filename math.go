@@ -10,7 +10,7 @@ import "fmt"
 func checkedMul(a, b uint64) (uint64, error) {
 	c := a * b
 	if a > 1 && b > 1 && c/a != b {
-		return c, fmt.Errorf("multiplication overflow: %d * %d", a, b)
+		return c, fmt.Errorf("%w: %d * %d", ErrOverflow, a, b)
 	}
 	return c, nil
 }
@@ -0,0 +1,206 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+)
+
+// ConflictPolicy determines how Merge handles tensors with the same name
+// in different inputs.
+type ConflictPolicy int
+
+const (
+	// ConflictError reports an error for any duplicate name.
+	ConflictError ConflictPolicy = iota
+	// ConflictFirstWins keeps the tensor of the first input.
+	ConflictFirstWins
+	// ConflictLastWins keeps the tensor of the last input.
+	ConflictLastWins
+	// ConflictIdentical keeps the tensor of the first input, reporting an
+	// error unless all the duplicates have the same DType, shape and data.
+	ConflictIdentical
+)
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	ConflictError:     "error",
+	ConflictFirstWins: "first-wins",
+	ConflictLastWins:  "last-wins",
+	ConflictIdentical: "identical",
+}
+
+// String returns the name of the policy.
+func (p ConflictPolicy) String() string {
+	if s, ok := conflictPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// MergeInput is an input of Merge.
+type MergeInput struct {
+	SafeTensors SafeTensors
+	// Prefix is prepended to the names of the tensors of the input.
+	Prefix string
+}
+
+// MergeOptions allows to customize the behavior of Merge.
+type MergeOptions struct {
+	// Include, if not empty, lists the glob patterns, as accepted by
+	// path.Match, of the names of the tensors to merge. Patterns are
+	// matched against prefixed names.
+	Include []string
+	// Exclude lists the glob patterns of the names of the tensors to
+	// leave out, taking precedence over Include.
+	Exclude []string
+	// Conflict is the policy for tensors with the same name.
+	Conflict ConflictPolicy
+	// Checksums, if true, adds the checksums of the merged tensors to the
+	// metadata, as with WithChecksums. Otherwise, the checksums of the
+	// inputs, which no longer apply, are dropped.
+	Checksums bool
+}
+
+// Merge writes to w a safetensors file with the tensors of all the inputs
+// selected by the options, and their merged __metadata__.
+//
+// Metadata entries with the same key must have the same value, unless
+// the policy is ConflictFirstWins or ConflictLastWins, which are applied
+// as for tensors.
+//
+// The data of the tensors is written directly from the inputs, as with
+// SerializeToWriter, so that inputs obtained with Open are never fully
+// loaded in memory.
+func Merge(w io.Writer, inputs []MergeInput, opts MergeOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	m := merger{
+		opts:      opts,
+		tensors:   make(map[string]TensorView),
+		tensorSrc: make(map[string]int),
+		metadata:  make(map[string]string),
+		keySrc:    make(map[string]int),
+	}
+	for i, input := range inputs {
+		if err := m.addTensors(i, input); err != nil {
+			return err
+		}
+		if err := m.addMetadata(i, input.SafeTensors.metadata.metadata); err != nil {
+			return err
+		}
+	}
+
+	var serializeOpts []SerializeOption
+	if opts.Checksums {
+		serializeOpts = append(serializeOpts, WithChecksums())
+	}
+	return SerializeToWriter(m.tensors, m.metadata, w, serializeOpts...)
+}
+
+func (o MergeOptions) validate() error {
+	if _, ok := conflictPolicyNames[o.Conflict]; !ok {
+		return fmt.Errorf("invalid conflict policy %d", int(o.Conflict))
+	}
+	for _, patterns := range [][]string{o.Include, o.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// selected reports whether the tensor with the given name is merged.
+func (o MergeOptions) selected(name string) bool {
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return false
+	}
+	return !matchAny(o.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// merger accumulates the tensors and metadata of the inputs of Merge,
+// with the index of the input each one comes from.
+type merger struct {
+	opts      MergeOptions
+	tensors   map[string]TensorView
+	tensorSrc map[string]int
+	metadata  map[string]string
+	keySrc    map[string]int
+}
+
+func (m *merger) addTensors(i int, input MergeInput) error {
+	for _, nv := range input.SafeTensors.Tensors() {
+		name := input.Prefix + nv.Name
+		if !m.opts.selected(name) {
+			continue
+		}
+		prev, ok := m.tensors[name]
+		if ok {
+			equal := m.opts.Conflict == ConflictIdentical && equalTensors(prev, nv.TensorView)
+			keep, err := m.resolve(fmt.Sprintf("tensor %q", name), m.tensorSrc[name], i, equal)
+			if err != nil {
+				return err
+			}
+			if keep {
+				continue
+			}
+		}
+		m.tensors[name] = nv.TensorView
+		m.tensorSrc[name] = i
+	}
+	return nil
+}
+
+func (m *merger) addMetadata(i int, metadata map[string]string) error {
+	for key, value := range metadata {
+		if key == ChecksumsMetadataKey {
+			continue
+		}
+		prev, ok := m.metadata[key]
+		if ok {
+			keep, err := m.resolve(fmt.Sprintf("metadata key %q", key), m.keySrc[key], i, prev == value)
+			if err != nil {
+				return err
+			}
+			if keep {
+				continue
+			}
+		}
+		m.metadata[key] = value
+		m.keySrc[key] = i
+	}
+	return nil
+}
+
+// resolve applies the conflict policy to an item found in inputs i and j,
+// reporting whether the item of input i is kept. Unless the policy picks
+// one of the items, they are only allowed if equal.
+func (m *merger) resolve(item string, i, j int, equal bool) (bool, error) {
+	switch {
+	case m.opts.Conflict == ConflictFirstWins || equal:
+		return true, nil
+	case m.opts.Conflict == ConflictLastWins:
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %s in inputs %d and %d", ErrMergeConflict, item, i, j)
+}
+
+func equalTensors(a, b TensorView) bool {
+	return a.DType() == b.DType() && equalShapes(a.Shape(), b.Shape()) && bytes.Equal(a.Data(), b.Data())
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	newST := func(t *testing.T, tensors map[string]TensorView, metadata map[string]string, opts ...SerializeOption) SafeTensors {
+		t.Helper()
+		out, err := Serialize(tensors, metadata, opts...)
+		require.NoError(t, err)
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+		return st
+	}
+	merge := func(t *testing.T, inputs []MergeInput, opts MergeOptions) (SafeTensors, error) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := Merge(&buf, inputs, opts); err != nil {
+			return SafeTensors{}, err
+		}
+		st, err := Deserialize(buf.Bytes())
+		require.NoError(t, err)
+		return st, nil
+	}
+
+	base := newST(t, map[string]TensorView{
+		"embed":   newArangeU8(t, 4),
+		"h.0.w":   newArangeU8(t, 2, 2),
+		"lm_head": newArangeU8(t, 3),
+	}, map[string]string{"format": "pt", "model": "base"}, WithChecksums())
+	head := newST(t, map[string]TensorView{
+		"lm_head": newF32Tensor(t, 1, 2, 3),
+		"scores":  newF32Tensor(t, 4),
+	}, map[string]string{"format": "pt"})
+
+	t.Run("prefixes", func(t *testing.T) {
+		st, err := merge(t, []MergeInput{{SafeTensors: base, Prefix: "llm."}, {SafeTensors: head, Prefix: "head."}}, MergeOptions{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"llm.embed", "llm.h.0.w", "llm.lm_head", "head.lm_head", "head.scores"}, st.Names())
+		assert.Equal(t, map[string]string{"format": "pt", "model": "base"}, st.Metadata().Metadata())
+		tv, _ := st.Tensor("head.lm_head")
+		assert.Equal(t, newF32Tensor(t, 1, 2, 3), tv)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		st, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: head}}, MergeOptions{
+			Include: []string{"h.*", "lm_head", "scores"},
+			Exclude: []string{"lm_head"},
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"h.0.w", "scores"}, st.Names())
+	})
+
+	t.Run("conflict error", func(t *testing.T) {
+		_, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: head}}, MergeOptions{})
+		assert.ErrorIs(t, err, ErrMergeConflict)
+		assert.EqualError(t, err, `merge conflict: tensor "lm_head" in inputs 0 and 1`)
+	})
+
+	t.Run("first wins", func(t *testing.T) {
+		st, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: head}}, MergeOptions{Conflict: ConflictFirstWins})
+		require.NoError(t, err)
+		tv, _ := st.Tensor("lm_head")
+		assert.Equal(t, newArangeU8(t, 3), tv)
+		assert.Len(t, st.Names(), 4)
+	})
+
+	t.Run("last wins", func(t *testing.T) {
+		st, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: head}}, MergeOptions{Conflict: ConflictLastWins})
+		require.NoError(t, err)
+		tv, _ := st.Tensor("lm_head")
+		assert.Equal(t, newF32Tensor(t, 1, 2, 3), tv)
+	})
+
+	t.Run("identical", func(t *testing.T) {
+		opts := MergeOptions{Conflict: ConflictIdentical}
+		st, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: base}}, opts)
+		require.NoError(t, err)
+		assert.Len(t, st.Names(), 3)
+
+		_, err = merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: base}, {SafeTensors: head}}, opts)
+		assert.EqualError(t, err, `merge conflict: tensor "lm_head" in inputs 0 and 2`)
+	})
+
+	t.Run("metadata conflict", func(t *testing.T) {
+		other := newST(t, map[string]TensorView{"x": newArangeU8(t, 1)}, map[string]string{"model": "other"})
+		_, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: other}}, MergeOptions{})
+		assert.EqualError(t, err, `merge conflict: metadata key "model" in inputs 0 and 1`)
+
+		st, err := merge(t, []MergeInput{{SafeTensors: base}, {SafeTensors: other}}, MergeOptions{Conflict: ConflictLastWins})
+		require.NoError(t, err)
+		assert.Equal(t, "other", st.Metadata().Metadata()["model"])
+	})
+
+	t.Run("checksums", func(t *testing.T) {
+		inputs := []MergeInput{{SafeTensors: base, Prefix: "a."}}
+		st, err := merge(t, inputs, MergeOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, st.Metadata().Metadata(), ChecksumsMetadataKey)
+
+		st, err = merge(t, inputs, MergeOptions{Checksums: true})
+		require.NoError(t, err)
+		assert.NoError(t, Verify(st))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := merge(t, nil, MergeOptions{Include: []string{"["}})
+		assert.EqualError(t, err, `invalid pattern "[": syntax error in pattern`)
+		_, err = merge(t, nil, MergeOptions{Conflict: 10})
+		assert.EqualError(t, err, "invalid conflict policy 10")
+	})
+}
@@ -41,38 +41,30 @@ func newMetadata(metadata map[string]string, tensors []NamedTensorInfo) Metadata
 // correspond to the end of the data buffer.
 func (m Metadata) validate() (uint64, error) {
 	start := uint64(0)
+	names := m.names()
 	for i, info := range m.tensors {
 		s := info.DataOffsets[0]
 		e := info.DataOffsets[1]
 
 		if s != start || e < s {
-			tensorName := "no_tensor"
-			for name, index := range m.indexMap {
-				if index == uint64(i) {
-					tensorName = name
-					break
-				}
+			return 0, &OffsetError{
+				Tensor:        names[i],
+				Offsets:       info.DataOffsets,
+				ExpectedBegin: start,
 			}
-			return 0, fmt.Errorf("invalid metadata offset for tensor %q", tensorName)
 		}
 		start = e
 
-		numElements := uint64(1)
-		for _, v := range info.Shape {
-			var err error
-			numElements, err = checkedMul(numElements, v)
-			if err != nil {
-				return 0, fmt.Errorf("metadata validation error: failed to compute num elements from shape: %w", err)
-			}
-		}
-
-		var err error
-		numBytes, err := checkedMul(numElements, info.DType.Size())
+		numBytes, err := dataLen(info.DType, info.Shape)
 		if err != nil {
-			return 0, fmt.Errorf("metadata validation error: failed to compute num bytes from num elements: %w", err)
+			return 0, fmt.Errorf("metadata validation error: %w", err)
 		}
 		if e-s != numBytes {
-			return 0, fmt.Errorf("metadata validation error: info data offsets mismatch")
+			return 0, &OffsetMismatchError{
+				Tensor:   names[i],
+				Expected: numBytes,
+				Actual:   e - s,
+			}
 		}
 	}
 	return start, nil
@@ -87,6 +79,15 @@ func (m Metadata) Tensors() map[string]*TensorInfo {
 	return result
 }
 
+// names returns the names of all tensors, sorted by data offsets.
+func (m Metadata) names() []string {
+	names := make([]string, len(m.indexMap))
+	for name, index := range m.indexMap {
+		names[index] = name
+	}
+	return names
+}
+
 // Metadata returns the tensors' metadata.
 func (m Metadata) Metadata() map[string]string {
 	return m.metadata
@@ -0,0 +1,282 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// UpdateMetadataAt replaces the __metadata__ of the file read by r,
+// overwriting only the header bytes through w.
+//
+// The tensors, and their data offsets, are left unchanged: only the value
+// of __metadata__ is replaced in the header bytes. The new header is padded with spaces to the length of the existing one. If it is larger,
+// ErrHeaderDoesNotFit is returned, and nothing is written: CopyWithMetadata
+// can be used instead.
+func UpdateMetadataAt(w io.WriterAt, r *Reader, metadata map[string]string) error {
+	header, err := r.editedHeader(metadata)
+	if err != nil {
+		return err
+	}
+	size := r.dataOffset - 8
+	if int64(len(header)) > size {
+		return fmt.Errorf("%w: max %d bytes, actual %d", ErrHeaderDoesNotFit, size, len(header))
+	}
+	header = appendSpaces(header, int(size)-len(header))
+	if _, err = w.WriteAt(header, 8); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	return nil
+}
+
+// CopyWithMetadata writes to w a copy of the file read by r, replacing its
+// __metadata__. The data of all tensors is copied once, unchanged.
+func CopyWithMetadata(w io.Writer, r *Reader, metadata map[string]string) error {
+	header, err := r.editedHeader(metadata)
+	if err != nil {
+		return err
+	}
+	header = appendSpaces(header, (8-len(header)%8)%8)
+	pd := preparedData{n: uint64(len(header)), headerBytes: header}
+	if err = writeHeader(w, pd); err != nil {
+		return err
+	}
+	data := io.NewSectionReader(r.r, r.dataOffset, int64(r.metadata.bufferEnd()))
+	if _, err = io.Copy(w, data); err != nil {
+		return fmt.Errorf("failed to copy tensors data: %w", err)
+	}
+	return nil
+}
+
+// UpdateMetadataFile replaces the __metadata__ of the named file.
+//
+// The header is rewritten in place when possible (see UpdateMetadataAt).
+// Otherwise, the whole file is rewritten (see CopyWithMetadata) to a
+// temporary file in the same directory, which then replaces the original.
+func UpdateMetadataFile(name string, metadata map[string]string) error {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := newFileReader(f)
+	if err != nil {
+		return err
+	}
+
+	err = UpdateMetadataAt(f, r, metadata)
+	if err == nil {
+		return f.Close()
+	}
+	if !errors.Is(err, ErrHeaderDoesNotFit) {
+		return err
+	}
+	return rewriteFile(name, f, r, metadata)
+}
+
+// newFileReader creates a Reader for the whole file f.
+func newFileReader(f *os.File) (*Reader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(f, fi.Size())
+}
+
+// rewriteFile replaces the named file with a copy of the file read by r
+// having the given metadata. The original file f is closed before being
+// replaced.
+func rewriteFile(name string, f *os.File, r *Reader, metadata map[string]string) (err error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = CopyWithMetadata(tmp, r, metadata); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// editedHeader returns the JSON header of the file read by r, without
+// padding, with the given __metadata__.
+//
+// Only the value of __metadata__ is replaced: the rest of the header is
+// kept byte for byte, preserving the order of the keys and the escaping of
+// the strings. If the metadata is equal to the existing one, the header is
+// unchanged. As with Serialize, the new value escapes HTML characters, and
+// it is left out if the metadata is empty.
+func (r *Reader) editedHeader(metadata map[string]string) ([]byte, error) {
+	header := make([]byte, r.dataOffset-8)
+	if _, err := io.ReadFull(io.NewSectionReader(r.r, 8, int64(len(header))), header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	h, err := scanHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	header = header[:h.end]
+	if equalMetadata(r.metadata.metadata, metadata) {
+		return header, nil
+	}
+
+	var value []byte
+	if len(metadata) > 0 {
+		if value, err = json.Marshal(metadata); err != nil {
+			return nil, fmt.Errorf("failed to JSON-marshal metadata: %w", err)
+		}
+	}
+	return h.splice(header, value), nil
+}
+
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if v, ok := b[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// headerLayout describes the positions of the members of a JSON header.
+type headerLayout struct {
+	// open is the offset after the opening brace, and end the offset
+	// after the closing one.
+	open, end int
+	members   []memberSpan
+	// meta is the index of the __metadata__ member, or -1.
+	meta int
+}
+
+// memberSpan is the position of a member of a JSON object.
+type memberSpan struct {
+	keyStart, valueStart, valueEnd int
+}
+
+// scanHeader returns the layout of a JSON header.
+func scanHeader(header []byte) (headerLayout, error) {
+	h := headerLayout{meta: -1}
+	dec := json.NewDecoder(bytes.NewReader(header))
+	if _, err := dec.Token(); err != nil {
+		return headerLayout{}, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	h.open = int(dec.InputOffset())
+	var value json.RawMessage
+	for dec.More() {
+		keyStart := skipSeparators(header, int(dec.InputOffset()))
+		key, err := dec.Token()
+		if err == nil {
+			err = dec.Decode(&value)
+		}
+		if err != nil {
+			return headerLayout{}, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+		if key == "__metadata__" {
+			if h.meta >= 0 {
+				return headerLayout{}, fmt.Errorf("%w: duplicate key %q", ErrInvalidHeader, key)
+			}
+			h.meta = len(h.members)
+		}
+		end := int(dec.InputOffset())
+		h.members = append(h.members, memberSpan{keyStart: keyStart, valueStart: end - len(value), valueEnd: end})
+	}
+	if _, err := dec.Token(); err != nil {
+		return headerLayout{}, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	h.end = int(dec.InputOffset())
+	return h, nil
+}
+
+// skipSeparators returns the offset of the first byte of b, from i, which
+// is neither JSON whitespace nor a comma.
+func skipSeparators(b []byte, i int) int {
+	for i < len(b) && bytes.IndexByte([]byte(" \t\r\n,"), b[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// splice returns header with the value of __metadata__ replaced, or with
+// the member removed if value is nil. A new member is inserted first, as
+// the reference implementation does.
+func (h headerLayout) splice(header, value []byte) []byte {
+	var start, end int
+	switch {
+	case h.meta >= 0 && value != nil:
+		start, end = h.members[h.meta].valueStart, h.members[h.meta].valueEnd
+	case h.meta >= 0:
+		start, end = h.removal()
+	case value != nil:
+		start, end = h.open, h.open
+		value = append([]byte(`"__metadata__":`), value...)
+		if len(h.members) > 0 {
+			value = append(value, ',')
+		}
+	default:
+		return header
+	}
+	out := make([]byte, 0, len(header)-(end-start)+len(value))
+	out = append(out, header[:start]...)
+	out = append(out, value...)
+	return append(out, header[end:]...)
+}
+
+// removal returns the span of the __metadata__ member, together with one
+// of the commas around it, if any.
+func (h headerLayout) removal() (int, int) {
+	i := h.meta
+	switch {
+	case i+1 < len(h.members):
+		return h.members[i].keyStart, h.members[i+1].keyStart
+	case i > 0:
+		return h.members[i-1].valueEnd, h.members[i].valueEnd
+	}
+	return h.members[i].keyStart, h.members[i].valueEnd
+}
+
+// bufferEnd returns the size of the data buffer described by m.
+func (m Metadata) bufferEnd() uint64 {
+	if len(m.tensors) == 0 {
+		return 0
+	}
+	return m.tensors[len(m.tensors)-1].DataOffsets[1]
+}
+
+// appendSpaces appends n spaces to b.
+func appendSpaces(b []byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		b = append(b, ' ')
+	}
+	return b
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateMetadata(t *testing.T) {
+	tensors := map[string]TensorView{
+		"a": newArangeU8(t, 2, 3),
+		"b": newArangeU8(t, 5),
+	}
+	original, err := Serialize(tensors, map[string]string{"license": "<a&b>", "foo": "bar"})
+	require.NoError(t, err)
+
+	checkFile := func(t *testing.T, name string, metadata map[string]string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		st, err := ParseOptions{Strict: true}.Deserialize(data)
+		require.NoError(t, err)
+		assert.Equal(t, metadata, st.Metadata().Metadata())
+		for name, expected := range tensors {
+			actual, ok := st.Tensor(name)
+			require.True(t, ok)
+			assert.Equal(t, expected.Data(), actual.Data())
+		}
+		return data
+	}
+
+	t.Run("unchanged metadata", func(t *testing.T) {
+		name := writeTempFile(t, original)
+		err := UpdateMetadataFile(name, map[string]string{"license": "<a&b>", "foo": "bar"})
+		require.NoError(t, err)
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, original, data)
+	})
+
+	t.Run("in place", func(t *testing.T) {
+		name := writeTempFile(t, original)
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		require.NoError(t, err)
+		defer f.Close()
+		r, err := NewReader(f, int64(len(original)))
+		require.NoError(t, err)
+
+		require.NoError(t, UpdateMetadataAt(f, r, map[string]string{"license": "MIT"}))
+		require.NoError(t, f.Close())
+
+		data := checkFile(t, name, map[string]string{"license": "MIT"})
+		assert.Equal(t, len(original), len(data))
+		assert.Equal(t, original[:8], data[:8])
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		name := writeTempFile(t, original)
+		require.NoError(t, UpdateMetadataFile(name, nil))
+		data := checkFile(t, name, nil)
+		assert.Equal(t, len(original), len(data))
+	})
+
+	t.Run("does not fit", func(t *testing.T) {
+		long := map[string]string{"license": strings.Repeat("x", 100)}
+		name := writeTempFile(t, original)
+
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		require.NoError(t, err)
+		r, err := NewReader(f, int64(len(original)))
+		require.NoError(t, err)
+		err = UpdateMetadataAt(f, r, long)
+		assert.ErrorIs(t, err, ErrHeaderDoesNotFit)
+		require.NoError(t, f.Close())
+		checkFile(t, name, map[string]string{"license": "<a&b>", "foo": "bar"})
+
+		require.NoError(t, UpdateMetadataFile(name, long))
+		data := checkFile(t, name, long)
+		assert.Greater(t, len(data), len(original))
+		assert.Zero(t, binary.LittleEndian.Uint64(data)%8, "data must stay aligned")
+
+		fi, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		r, err := NewReader(bytes.NewReader(original), int64(len(original)))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, CopyWithMetadata(&buf, r, map[string]string{"foo": "bar"}))
+
+		st, err := Deserialize(buf.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"foo": "bar"}, st.Metadata().Metadata())
+		assert.Equal(t, 2, st.Len())
+	})
+}
+
+func TestEditHeaderBytes(t *testing.T) {
+	// Headers as written by other tools: unescaped strings, __metadata__
+	// first, tensors in offset order, and arbitrary whitespace.
+	b := `"b":{"dtype":"U8","shape":[1],"data_offsets":[0,1]}`
+	a := `"a":{"dtype":"U8","shape":[2],"data_offsets":[1,3]}`
+	edit := func(t *testing.T, header string, metadata map[string]string) string {
+		t.Helper()
+		dataLen := 0
+		if strings.Contains(header, a) {
+			dataLen = 3
+		}
+		header += strings.Repeat(" ", (8-len(header)%8)%8)
+		file := buildRaw(header, dataLen)
+		r, err := NewReader(bytes.NewReader(file), int64(len(file)))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, CopyWithMetadata(&buf, r, metadata))
+		out := buf.Bytes()
+		assert.Equal(t, file[len(file)-dataLen:], out[len(out)-dataLen:])
+		return strings.TrimRight(string(out[8:len(out)-dataLen]), " ")
+	}
+
+	testCases := []struct {
+		name     string
+		header   string
+		metadata map[string]string
+		expected string
+	}{
+		{"unchanged", `{"__metadata__":{"l":"<a&b>"},` + b + `,` + a + `}`, map[string]string{"l": "<a&b>"},
+			`{"__metadata__":{"l":"<a&b>"},` + b + `,` + a + `}`},
+		{"replace", `{"__metadata__":{"l":"<a&b>"},` + b + `,` + a + `}`, map[string]string{"l": "MIT"},
+			`{"__metadata__":{"l":"MIT"},` + b + `,` + a + `}`},
+		{"escape", `{"__metadata__":{"l":"MIT"},` + b + `,` + a + `}`, map[string]string{"l": "<a&b>"},
+			`{"__metadata__":{"l":"\u003ca\u0026b\u003e"},` + b + `,` + a + `}`},
+		{"remove first", `{"__metadata__":{"l":"MIT"},` + b + `,` + a + `}`, nil,
+			`{` + b + `,` + a + `}`},
+		{"remove last", `{` + b + `, ` + a + ` , "__metadata__" : {"l":"MIT"} }`, nil,
+			`{` + b + `, ` + a + ` }`},
+		{"remove only", `{"__metadata__":{"l":"MIT"}}`, nil, `{}`},
+		{"insert", `{ ` + b + `,` + a + `}`, map[string]string{"l": "MIT"},
+			`{"__metadata__":{"l":"MIT"}, ` + b + `,` + a + `}`},
+		{"insert only", `{}`, map[string]string{"l": "MIT"}, `{"__metadata__":{"l":"MIT"}}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, edit(t, tc.header, tc.metadata))
+		})
+	}
+}
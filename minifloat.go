@@ -0,0 +1,208 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"math"
+	"math/bits"
+)
+
+// minifloat describes a binary floating point format narrower than
+// float32, allowing conversions to and from float32 values, and from
+// float64 and integer values.
+type minifloat struct {
+	expBits  uint32
+	mantBits uint32
+	// hasInf reports whether the format follows IEEE 754 conventions for
+	// the all-ones exponent, representing infinities and NaNs.
+	// Otherwise, the only NaN value is the one with all bits set (except the
+	// sign), and there are no infinities.
+	hasInf bool
+	// saturate reports whether finite values too large to be represented
+	// are converted to the largest finite value, rather than to infinity.
+	saturate bool
+}
+
+func (mf minifloat) bias() int32 {
+	return 1<<(mf.expBits-1) - 1
+}
+
+func (mf minifloat) expMask() uint32 {
+	return 1<<mf.expBits - 1
+}
+
+func (mf minifloat) mantMask() uint32 {
+	return 1<<mf.mantBits - 1
+}
+
+// infBits returns the bits of the positive infinity value, or of the
+// largest finite value if the format has no infinities.
+func (mf minifloat) infBits() uint32 {
+	if mf.hasInf {
+		return mf.expMask() << mf.mantBits
+	}
+	return mf.maxBits()
+}
+
+// maxBits returns the bits of the largest positive finite value.
+func (mf minifloat) maxBits() uint32 {
+	if mf.hasInf {
+		return mf.expMask()<<mf.mantBits - 1
+	}
+	return mf.expMask()<<mf.mantBits | (mf.mantMask() - 1)
+}
+
+// nanBits returns the bits of a positive NaN value, preserving the most
+// significant bits of the payload of a NaN with the given mantissa width
+// when possible.
+func (mf minifloat) nanBits(mant uint64, width uint32) uint32 {
+	if !mf.hasInf {
+		return mf.expMask()<<mf.mantBits | mf.mantMask()
+	}
+	payload := uint32(mant >> (width - mf.mantBits))
+	if payload == 0 {
+		payload = 1 << (mf.mantBits - 1)
+	}
+	return mf.expMask()<<mf.mantBits | payload
+}
+
+func (mf minifloat) isNaN(v uint32) bool {
+	exp, mant := (v>>mf.mantBits)&mf.expMask(), v&mf.mantMask()
+	if mf.hasInf {
+		return exp == mf.expMask() && mant != 0
+	}
+	return exp == mf.expMask() && mant == mf.mantMask()
+}
+
+func (mf minifloat) isInf(v uint32) bool {
+	return mf.hasInf && v&(mf.expMask()<<mf.mantBits|mf.mantMask()) == mf.expMask()<<mf.mantBits
+}
+
+// fromFloat32 converts a float32 value to the bits of the nearest
+// representable value, rounding half to even.
+func (mf minifloat) fromFloat32(f float32) uint32 {
+	bits := math.Float32bits(f)
+	return mf.fromIEEE(uint64(bits>>31), uint64(bits>>23&0xff), uint64(bits&0x7fffff), 8, 23)
+}
+
+// fromFloat64 converts a float64 value to the bits of the nearest
+// representable value, rounding half to even. Unlike a conversion through
+// float32, it rounds only once.
+func (mf minifloat) fromFloat64(f float64) uint32 {
+	bits := math.Float64bits(f)
+	return mf.fromIEEE(bits>>63, bits>>52&0x7ff, bits&(1<<52-1), 11, 52)
+}
+
+// fromIEEE converts the sign, biased exponent and mantissa of an IEEE 754
+// binary value, with the given field widths, to the bits of the nearest
+// representable value.
+func (mf minifloat) fromIEEE(sign, exp, mant uint64, expBits, mantBits uint32) uint32 {
+	signBit := uint32(sign) << (mf.expBits + mf.mantBits)
+	switch {
+	case exp == 1<<expBits-1 && mant != 0:
+		return signBit | mf.nanBits(mant, mantBits)
+	case exp == 1<<expBits-1:
+		return signBit | mf.infBits()
+	}
+
+	// Subnormal values (and zero) have the minimum exponent, without the
+	// implicit leading bit.
+	bias := int32(1)<<(expBits-1) - 1
+	e := 1 - bias
+	if exp != 0 {
+		e = int32(exp) - bias
+		mant |= 1 << mantBits
+	}
+	return mf.withSign(sign, mf.roundFinite(e, mant, mantBits))
+}
+
+// fromInt converts an integer, given as sign and absolute value, to the
+// bits of the nearest representable value, rounding half to even.
+func (mf minifloat) fromInt(neg bool, abs uint64) uint32 {
+	var sign uint64
+	if neg {
+		sign = 1
+	}
+	if abs == 0 {
+		return mf.withSign(sign, 0)
+	}
+	// abs is mant * 2^(exp-width), with at least as many mantissa bits as
+	// the format.
+	exp := int32(bits.Len64(abs) - 1)
+	width := uint32(exp)
+	if width < mf.mantBits {
+		abs <<= mf.mantBits - width
+		width = mf.mantBits
+	}
+	return mf.withSign(sign, mf.roundFinite(exp, abs, width))
+}
+
+// withSign returns the bits of a rounded absolute value v with the given
+// sign, replacing values too large to be represented with the largest
+// finite value or with infinity.
+func (mf minifloat) withSign(sign uint64, v uint32) uint32 {
+	if v > mf.maxBits() {
+		if mf.saturate {
+			v = mf.maxBits()
+		} else {
+			v = mf.infBits()
+		}
+	}
+	return uint32(sign)<<(mf.expBits+mf.mantBits) | v
+}
+
+// roundFinite converts the finite absolute value mant * 2^(exp-width),
+// where mant is less than 2^(width+1), rounding half to even. The result
+// might exceed the largest finite value of the format.
+func (mf minifloat) roundFinite(exp int32, mant uint64, width uint32) uint32 {
+	shift := width - mf.mantBits
+	emin := 1 - mf.bias()
+	if exp < emin {
+		// Subnormal value (or zero) for the target format.
+		extra := uint32(emin - exp)
+		if shift+extra > width+1 {
+			return 0
+		}
+		return uint32(roundShiftRightEven(mant, shift+extra))
+	}
+
+	// The rounded mantissa includes the implicit leading bit, if any,
+	// whose removal makes any carry propagate to the exponent.
+	q := uint32(roundShiftRightEven(mant, shift))
+	return uint32(exp+mf.bias())<<mf.mantBits + q - 1<<mf.mantBits
+}
+
+// roundShiftRightEven returns v >> s, rounded half to even.
+func roundShiftRightEven(v uint64, s uint32) uint64 {
+	if s == 0 {
+		return v
+	}
+	q := v >> s
+	rem := v & (1<<s - 1)
+	half := uint64(1) << (s - 1)
+	if rem > half || (rem == half && q&1 == 1) {
+		q++
+	}
+	return q
+}
+
+// toFloat32 converts the bits of a value to float32. The conversion is
+// always exact.
+func (mf minifloat) toFloat32(v uint32) float32 {
+	sign := (v >> (mf.expBits + mf.mantBits)) & 1 << 31
+	exp := (v >> mf.mantBits) & mf.expMask()
+	mant := v & mf.mantMask()
+
+	switch {
+	case mf.isNaN(v):
+		return math.Float32frombits(sign | 0x7f800000 | mant<<(23-mf.mantBits))
+	case mf.isInf(v):
+		return math.Float32frombits(sign | 0x7f800000)
+	case exp == 0:
+		f := float32(math.Ldexp(float64(mant), int(1-mf.bias()-int32(mf.mantBits))))
+		return math.Float32frombits(sign | math.Float32bits(f))
+	}
+	return math.Float32frombits(sign | uint32(int32(exp)-mf.bias()+127)<<23 | mant<<(23-mf.mantBits))
+}
@@ -0,0 +1,26 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package safetensors
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile falls back to reading the whole file into memory on platforms
+// where memory-mapping is not supported.
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, int64(size)), b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func munmapFile([]byte) error {
+	return nil
+}
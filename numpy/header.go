@@ -0,0 +1,198 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package numpy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// headerParser parses the header of .npy files, which is the literal of a
+// Python dict such as
+//
+//	{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }
+//
+// Only the subset of the Python syntax written by NumPy is supported.
+type headerParser struct {
+	s   string
+	pos int
+}
+
+// headerFields parse the values of the keys of the header dict.
+var headerFields = map[string]func(p *headerParser, h *header) error{
+	"descr": func(p *headerParser, h *header) (err error) {
+		h.descr, err = p.parseString()
+		return err
+	},
+	"fortran_order": func(p *headerParser, h *header) (err error) {
+		h.fortranOrder, err = p.parseBool()
+		return err
+	},
+	"shape": func(p *headerParser, h *header) (err error) {
+		h.shape, err = p.parseShape()
+		return err
+	},
+}
+
+func parseHeader(s string) (header, error) {
+	p := &headerParser{s: s}
+	var h header
+	if err := p.expect('{'); err != nil {
+		return header{}, err
+	}
+	seen := make(map[string]bool, len(headerFields))
+	for !p.consume('}') {
+		if err := p.parseField(&h, seen); err != nil {
+			return header{}, err
+		}
+		if !p.consume(',') && p.peek() != '}' {
+			return header{}, p.unexpected("',' or '}'")
+		}
+	}
+	if p.skipSpace(); p.pos != len(p.s) {
+		return header{}, p.unexpected("end of header")
+	}
+	for key := range headerFields {
+		if !seen[key] {
+			return header{}, fmt.Errorf("missing key %q", key)
+		}
+	}
+	return h, nil
+}
+
+// parseField parses a "key: value" pair.
+func (p *headerParser) parseField(h *header, seen map[string]bool) error {
+	key, err := p.parseString()
+	if err != nil {
+		return err
+	}
+	parse, ok := headerFields[key]
+	if !ok {
+		return fmt.Errorf("unexpected key %q", key)
+	}
+	if seen[key] {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	seen[key] = true
+	if err = p.expect(':'); err != nil {
+		return err
+	}
+	if err = parse(p, h); err != nil {
+		return fmt.Errorf("invalid value of %q: %w", key, err)
+	}
+	return nil
+}
+
+func (p *headerParser) parseString() (string, error) {
+	p.skipSpace()
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", p.unexpected("string")
+	}
+	end := strings.IndexByte(p.s[p.pos+1:], quote)
+	if end < 0 {
+		return "", errors.New("unterminated string")
+	}
+	s := p.s[p.pos+1 : p.pos+1+end]
+	if strings.IndexByte(s, '\\') >= 0 {
+		return "", errors.New("escape sequences are not supported")
+	}
+	p.pos += end + 2
+	return s, nil
+}
+
+func (p *headerParser) parseBool() (bool, error) {
+	p.skipSpace()
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "True"):
+		p.pos += len("True")
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "False"):
+		p.pos += len("False")
+		return false, nil
+	}
+	return false, p.unexpected("True or False")
+}
+
+// parseShape parses a tuple of non-negative integers.
+func (p *headerParser) parseShape() ([]uint64, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	shape := make([]uint64, 0)
+	for !p.consume(')') {
+		dim, err := p.parseUint()
+		if err != nil {
+			return nil, err
+		}
+		shape = append(shape, dim)
+		if !p.consume(',') && p.peek() != ')' {
+			return nil, p.unexpected("',' or ')'")
+		}
+	}
+	return shape, nil
+}
+
+// parseUint parses a non-negative integer, with the optional "L" suffix
+// of long integers written by Python 2.
+func (p *headerParser) parseUint() (uint64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, p.unexpected("integer")
+	}
+	n, err := strconv.ParseUint(p.s[start:p.pos], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == 'L' {
+		p.pos++
+	}
+	return n, nil
+}
+
+// expect consumes the given delimiter, preceded by optional spaces.
+func (p *headerParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.unexpected(strconv.QuoteRune(rune(c)))
+	}
+	return nil
+}
+
+// consume consumes the given delimiter, preceded by optional spaces, and
+// reports whether it was found.
+func (p *headerParser) consume(c byte) bool {
+	if p.skipSpace(); p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// peek returns the next byte, or 0 at the end of the header.
+func (p *headerParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *headerParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *headerParser) unexpected(expected string) error {
+	if p.pos >= len(p.s) {
+		return fmt.Errorf("expected %s, found end of header", expected)
+	}
+	return fmt.Errorf("expected %s at offset %d, found %q", expected, p.pos, p.s[p.pos])
+}
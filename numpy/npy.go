@@ -0,0 +1,253 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package numpy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// magic is the prefix of every .npy file.
+const magic = "\x93NUMPY"
+
+// maxHeaderSize is the maximum size in bytes of a .npy header accepted
+// when reading, the same default limit used by NumPy.
+const maxHeaderSize = 10000
+
+// headerAlignment is the alignment of the data of .npy files.
+const headerAlignment = 64
+
+// dTypes maps NumPy type codes, without the byte order character, to
+// DTypes.
+var dTypes = map[string]safetensors.DType{
+	"b1": safetensors.BOOL,
+	"u1": safetensors.U8,
+	"i1": safetensors.I8,
+	"i2": safetensors.I16,
+	"u2": safetensors.U16,
+	"f2": safetensors.F16,
+	"i4": safetensors.I32,
+	"u4": safetensors.U32,
+	"f4": safetensors.F32,
+	"i8": safetensors.I64,
+	"u8": safetensors.U64,
+	"f8": safetensors.F64,
+}
+
+// descrs maps DTypes to the NumPy type descriptors used when writing.
+var descrs = map[safetensors.DType]string{
+	safetensors.BOOL: "|b1",
+	safetensors.U8:   "|u1",
+	safetensors.I8:   "|i1",
+	safetensors.I16:  "<i2",
+	safetensors.U16:  "<u2",
+	safetensors.F16:  "<f2",
+	safetensors.I32:  "<i4",
+	safetensors.U32:  "<u4",
+	safetensors.F32:  "<f4",
+	safetensors.I64:  "<i8",
+	safetensors.U64:  "<u8",
+	safetensors.F64:  "<f8",
+}
+
+// header is the content of the header of a .npy file.
+type header struct {
+	descr        string
+	fortranOrder bool
+	shape        []uint64
+}
+
+// ReadNPY reads an array in the .npy format from r.
+//
+// Big-endian arrays are converted to little-endian, and Fortran-order
+// arrays are converted to C order. Arrays of types without a DType
+// equivalent, such as complex numbers or structured types, are rejected.
+func ReadNPY(r io.Reader) (safetensors.TensorView, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	dType, bigEndian, err := parseDescr(h.descr)
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	size, err := tensorio.DataSize(dType.Size(), h.shape)
+	if err != nil {
+		return safetensors.TensorView{}, fmt.Errorf("invalid .npy %w", err)
+	}
+
+	// The data is read incrementally, rather than allocated upfront, so
+	// that a bogus shape cannot trigger a huge allocation.
+	data, err := io.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return safetensors.TensorView{}, fmt.Errorf("failed to read .npy data: %w", err)
+	}
+	if uint64(len(data)) != size {
+		return safetensors.TensorView{}, fmt.Errorf("invalid .npy data: expected %d bytes, actual %d", size, len(data))
+	}
+
+	if bigEndian {
+		tensorio.SwapBytes(data, int(dType.Size()))
+	}
+	if h.fortranOrder && len(h.shape) > 1 {
+		data = fortranToC(data, h.shape, dType.Size())
+	}
+	return safetensors.NewTensorView(dType, h.shape, data)
+}
+
+// WriteNPY writes v to w in the .npy format, as a little-endian C-order
+// array.
+//
+// BF16 and 8-bit floating point tensors cannot be written, since NumPy
+// has no equivalent types.
+func WriteNPY(w io.Writer, v safetensors.View) error {
+	descr, ok := descrs[v.DType()]
+	if !ok {
+		return fmt.Errorf("DType %s cannot be represented in NumPy", v.DType())
+	}
+	// Validate the view before writing anything.
+	if err := tensorio.CheckDataLen(v.DType().Size(), v.Shape(), v.DataLen()); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(formatHeader(descr, v.Shape())); err != nil {
+		return err
+	}
+	data := v.Data()
+	if uint64(len(data)) != v.DataLen() {
+		return fmt.Errorf("data length %d does not match DataLen %d", len(data), v.DataLen())
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// formatHeader returns the magic string, the version and the header of a
+// C-order array. The header is padded with spaces and terminated by a
+// newline so that the data is aligned, as written by NumPy. Version 2.0 is
+// only used for headers too large for version 1.0.
+func formatHeader(descr string, shape []uint64) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "{'descr': '%s', 'fortran_order': False, 'shape': (", descr)
+	for i, dim := range shape {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.FormatUint(dim, 10))
+	}
+	if len(shape) == 1 {
+		sb.WriteByte(',')
+	}
+	sb.WriteString("), }")
+
+	// The header length takes 2 bytes in version 1.0, and 4 bytes in
+	// version 2.0.
+	major, prefix := byte(1), len(magic)+4
+	if sb.Len()+1+headerAlignment > math.MaxUint16 {
+		major, prefix = 2, len(magic)+6
+	}
+	if rem := (prefix + sb.Len() + 1) % headerAlignment; rem != 0 {
+		sb.WriteString(strings.Repeat(" ", headerAlignment-rem))
+	}
+	sb.WriteByte('\n')
+
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.Write([]byte{major, 0})
+	if major == 1 {
+		_ = binary.Write(&buf, binary.LittleEndian, uint16(sb.Len()))
+	} else {
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(sb.Len()))
+	}
+	buf.WriteString(sb.String())
+	return buf.Bytes()
+}
+
+// readHeader reads the magic string, the version and the header of a .npy
+// file.
+func readHeader(r io.Reader) (header, error) {
+	prefix := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return header{}, fmt.Errorf("failed to read .npy header: %w", err)
+	}
+	if string(prefix[:len(magic)]) != magic {
+		return header{}, errors.New("invalid .npy file: magic string not found")
+	}
+
+	var size uint32
+	switch major := prefix[len(magic)]; major {
+	case 1:
+		var size16 uint16
+		if err := binary.Read(r, binary.LittleEndian, &size16); err != nil {
+			return header{}, fmt.Errorf("failed to read .npy header: %w", err)
+		}
+		size = uint32(size16)
+	case 2, 3:
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return header{}, fmt.Errorf("failed to read .npy header: %w", err)
+		}
+	default:
+		return header{}, fmt.Errorf("unsupported .npy format version %d.%d", major, prefix[len(magic)+1])
+	}
+	if size > maxHeaderSize {
+		return header{}, fmt.Errorf("invalid .npy header: size %d exceeds the maximum of %d", size, maxHeaderSize)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return header{}, fmt.Errorf("failed to read .npy header: %w", err)
+	}
+	h, err := parseHeader(string(buf))
+	if err != nil {
+		return header{}, fmt.Errorf("invalid .npy header: %w", err)
+	}
+	return h, nil
+}
+
+// parseDescr returns the DType of a NumPy type descriptor, such as "<f4",
+// and whether its data is big-endian.
+func parseDescr(descr string) (dType safetensors.DType, bigEndian bool, err error) {
+	if len(descr) != 3 {
+		return 0, false, fmt.Errorf("unsupported NumPy type %q", descr)
+	}
+	dType, ok := dTypes[descr[1:]]
+	if !ok {
+		return 0, false, fmt.Errorf("unsupported NumPy type %q", descr)
+	}
+	switch descr[0] {
+	case '<':
+		return dType, false, nil
+	case '>':
+		return dType, true, nil
+	case '|':
+		if dType.Size() == 1 {
+			return dType, false, nil
+		}
+	}
+	return 0, false, fmt.Errorf("invalid byte order of NumPy type %q", descr)
+}
+
+// fortranToC converts the data of a Fortran-order array to C order.
+func fortranToC(data []byte, shape []uint64, size uint64) []byte {
+	stride := make([]uint64, len(shape))
+	n := uint64(1)
+	for i, dim := range shape {
+		stride[i] = n
+		n *= dim
+	}
+	out := make([]byte, 0, len(data))
+	if n == 0 {
+		return out
+	}
+	return tensorio.Gather(out, data, shape, stride, 0, size)
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package numpy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// npy returns a version 1.0 .npy file with the given header dict.
+func npy(header string, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(magic + "\x01\x00")
+	_ = binary.Write(buf, binary.LittleEndian, uint16(len(header)+1))
+	buf.WriteString(header + "\n")
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func f32Bytes(values ...float32) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return buf.Bytes()
+}
+
+func TestReadNPY(t *testing.T) {
+	testCases := []struct {
+		name  string
+		npy   []byte
+		dType safetensors.DType
+		shape []uint64
+		data  []byte
+	}{
+		{
+			"little endian",
+			npy("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }", f32Bytes(1, 2, 3, 4, 5, 6)),
+			safetensors.F32, []uint64{2, 3}, f32Bytes(1, 2, 3, 4, 5, 6),
+		},
+		{
+			"big endian",
+			npy("{'descr': '>i2', 'fortran_order': False, 'shape': (2,), }", []byte{0, 1, 1, 0}),
+			safetensors.I16, []uint64{2}, []byte{1, 0, 0, 1},
+		},
+		{
+			"fortran order",
+			npy("{'descr': '<f4', 'fortran_order': True, 'shape': (2, 3), }", f32Bytes(1, 4, 2, 5, 3, 6)),
+			safetensors.F32, []uint64{2, 3}, f32Bytes(1, 2, 3, 4, 5, 6),
+		},
+		{
+			"scalar",
+			npy("{'descr': '|b1', 'fortran_order': False, 'shape': (), }", []byte{1}),
+			safetensors.BOOL, []uint64{}, []byte{1},
+		},
+		{
+			"empty",
+			npy("{'descr': '<i8', 'fortran_order': True, 'shape': (0, 2), }", nil),
+			safetensors.I64, []uint64{0, 2}, []byte{},
+		},
+		{
+			"python 2",
+			npy(`{"shape": (2L,), "fortran_order": False, "descr": "|u1"}`, []byte{7, 8}),
+			safetensors.U8, []uint64{2}, []byte{7, 8},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tv, err := ReadNPY(bytes.NewReader(tc.npy))
+			require.NoError(t, err)
+			assert.Equal(t, tc.dType, tv.DType())
+			assert.Equal(t, tc.shape, tv.Shape())
+			assert.Equal(t, tc.data, tv.Data())
+		})
+	}
+
+	t.Run("version 3.0", func(t *testing.T) {
+		h := "{'descr': '<u2', 'fortran_order': False, 'shape': (1,), }\n"
+		buf := new(bytes.Buffer)
+		buf.WriteString(magic + "\x03\x00")
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(h)))
+		buf.WriteString(h + "\x01\x02")
+		tv, err := ReadNPY(buf)
+		require.NoError(t, err)
+		assert.Equal(t, safetensors.U16, tv.DType())
+		assert.Equal(t, []byte{1, 2}, tv.Data())
+	})
+}
+
+func TestReadNPYErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		npy  []byte
+		err  string
+	}{
+		{"empty", nil, "failed to read .npy header: EOF"},
+		{"magic", []byte("\x93NUMPX\x01\x00"), "invalid .npy file: magic string not found"},
+		{"version", []byte(magic + "\x04\x00"), "unsupported .npy format version 4.0"},
+		{"header too large", []byte(magic + "\x02\x00\xff\xff\xff\xff"), "invalid .npy header: size 4294967295 exceeds the maximum of 10000"},
+		{"truncated header", []byte(magic + "\x01\x00\x10\x00{"), "failed to read .npy header: unexpected EOF"},
+		{
+			"unsupported type",
+			npy("{'descr': '<c8', 'fortran_order': False, 'shape': (1,), }", nil),
+			`unsupported NumPy type "<c8"`,
+		},
+		{
+			"structured type",
+			npy("{'descr': [('a', '<f4')], 'fortran_order': False, 'shape': (1,), }", nil),
+			`invalid .npy header: invalid value of "descr": expected string at offset 10, found '['`,
+		},
+		{
+			"object type",
+			npy("{'descr': '|O', 'fortran_order': False, 'shape': (1,), }", nil),
+			`unsupported NumPy type "|O"`,
+		},
+		{
+			"byte order",
+			npy("{'descr': '|f4', 'fortran_order': False, 'shape': (1,), }", nil),
+			`invalid byte order of NumPy type "|f4"`,
+		},
+		{
+			"missing key",
+			npy("{'descr': '<f4', 'shape': (1,), }", nil),
+			`invalid .npy header: missing key "fortran_order"`,
+		},
+		{
+			"unexpected key",
+			npy("{'descr': '<f4', 'fortran_order': False, 'shape': (1,), 'foo': 1}", nil),
+			`invalid .npy header: unexpected key "foo"`,
+		},
+		{
+			"duplicate key",
+			npy("{'descr': '<f4', 'descr': '<f4'}", nil),
+			`invalid .npy header: duplicate key "descr"`,
+		},
+		{
+			"invalid bool",
+			npy("{'fortran_order': 0}", nil),
+			`invalid .npy header: invalid value of "fortran_order": expected True or False at offset 18, found '0'`,
+		},
+		{
+			"negative dimension",
+			npy("{'shape': (-1,)}", nil),
+			`invalid .npy header: invalid value of "shape": expected integer at offset 11, found '-'`,
+		},
+		{
+			"trailing data",
+			npy("{'descr': '<f4', 'fortran_order': False, 'shape': (1,)} x", nil),
+			`invalid .npy header: expected end of header at offset 56, found 'x'`,
+		},
+		{
+			"unterminated dict",
+			npy("{'descr': '<f4'", nil),
+			`invalid .npy header: expected ',' or '}', found end of header`,
+		},
+		{
+			"size overflow",
+			npy("{'descr': '<f4', 'fortran_order': False, 'shape': (4294967296, 4294967296), }", nil),
+			"invalid .npy shape [4294967296 4294967296]: size overflow",
+		},
+		{
+			"truncated data",
+			npy("{'descr': '<f4', 'fortran_order': False, 'shape': (1000000000000,), }", f32Bytes(1)),
+			"invalid .npy data: expected 4000000000000 bytes, actual 4",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadNPY(bytes.NewReader(tc.npy))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestWriteNPY(t *testing.T) {
+	t.Run("as numpy.save", func(t *testing.T) {
+		tv, err := safetensors.NewTensorView(safetensors.F32, []uint64{2, 3}, f32Bytes(1, 2, 3, 4, 5, 6))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, WriteNPY(&buf, tv))
+
+		h := "{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }" + strings.Repeat(" ", 58) + "\n"
+		assert.Equal(t, npy(h[:len(h)-1], f32Bytes(1, 2, 3, 4, 5, 6)), buf.Bytes())
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, shape := range [][]uint64{{}, {1}, {2, 1, 3}, {0}} {
+			size := uint64(8)
+			for _, dim := range shape {
+				size *= dim
+			}
+			data := make([]byte, size)
+			tv, err := safetensors.NewTensorView(safetensors.I64, shape, data)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, WriteNPY(&buf, tv))
+			assert.Equal(t, 0, (bytes.IndexByte(buf.Bytes(), '\n')+1)%64, "alignment %v", shape)
+
+			actual, err := ReadNPY(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, tv, actual)
+		}
+	})
+
+	t.Run("unsupported dtype", func(t *testing.T) {
+		tv, err := safetensors.NewTensorView(safetensors.BF16, []uint64{1}, []byte{0, 0})
+		require.NoError(t, err)
+		assert.EqualError(t, WriteNPY(new(bytes.Buffer), tv), "DType BF16 cannot be represented in NumPy")
+	})
+
+	t.Run("lazy view", func(t *testing.T) {
+		tv, err := safetensors.NewTensorView(safetensors.F32, []uint64{2}, f32Bytes(1, 2))
+		require.NoError(t, err)
+		v := &countingView{TensorView: tv}
+		require.NoError(t, WriteNPY(new(bytes.Buffer), v))
+		assert.Equal(t, 1, v.calls)
+
+		err = WriteNPY(new(bytes.Buffer), shapeView{v, []uint64{3}})
+		assert.EqualError(t, err, "expected 12 bytes of data, actual 8")
+	})
+}
+
+// countingView is a View counting the calls to Data.
+type countingView struct {
+	safetensors.TensorView
+	calls int
+}
+
+func (v *countingView) Data() []byte {
+	v.calls++
+	return v.TensorView.Data()
+}
+
+// shapeView overrides the shape of a View.
+type shapeView struct {
+	safetensors.View
+	shape []uint64
+}
+
+func (v shapeView) Shape() []uint64 { return v.shape }
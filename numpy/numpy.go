@@ -0,0 +1,130 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package numpy reads and writes NumPy arrays in the .npy format, and
+// archives of arrays in the .npz format, converting them from and to
+// safetensors.
+//
+// Only arrays of booleans, integers and floating point numbers with a
+// DType equivalent are supported. Object arrays, which require pickle,
+// are never loaded.
+package numpy
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// npyExt is the extension of the names of the arrays in .npz archives.
+const npyExt = ".npy"
+
+// LoadNPZ reads a .npz archive from r, which is assumed to have the given
+// size in bytes, and returns its arrays indexed by name.
+//
+// Both archives written by numpy.savez and by numpy.savez_compressed are
+// supported. Files of the archive whose names do not end in ".npy" are
+// ignored.
+func LoadNPZ(r io.ReaderAt, size int64) (map[string]safetensors.TensorView, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .npz archive: %w", err)
+	}
+	tensors := make(map[string]safetensors.TensorView, len(zr.File))
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, npyExt) {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name, npyExt)
+		if _, ok := tensors[name]; ok {
+			return nil, fmt.Errorf("invalid .npz archive: duplicate array %q", name)
+		}
+		tv, err := readNPZFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array %q: %w", name, err)
+		}
+		tensors[name] = tv
+	}
+	return tensors, nil
+}
+
+func readNPZFile(f *zip.File) (safetensors.TensorView, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	defer rc.Close()
+	return ReadNPY(rc)
+}
+
+// LoadNPZFile is like LoadNPZ, reading the named file.
+func LoadNPZFile(name string) (map[string]safetensors.TensorView, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return LoadNPZ(f, fi.Size())
+}
+
+// SaveNPZ writes the tensors to w as an uncompressed .npz archive, as
+// written by numpy.savez, sorted by name.
+func SaveNPZ[V safetensors.View](w io.Writer, tensors map[string]V) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name + npyExt, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err = WriteNPY(fw, tensors[name]); err != nil {
+			return fmt.Errorf("failed to write tensor %q: %w", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// ConvertFile converts the .npz archive src to the safetensors file dst,
+// with the "format" metadata set to "np".
+func ConvertFile(src, dst string) error {
+	tensors, err := LoadNPZFile(src)
+	if err != nil {
+		return err
+	}
+	return tensorio.CreateFile(dst, func(w io.Writer) error {
+		return safetensors.SerializeToWriter(tensors, map[string]string{"format": "np"}, w)
+	})
+}
+
+// ExportFile converts the safetensors file src to the .npz archive dst.
+// The metadata of src is not preserved.
+func ExportFile(src, dst string) error {
+	f, err := safetensors.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tensors := make(map[string]safetensors.TensorView, f.Len())
+	for _, t := range f.Tensors() {
+		tensors[t.Name] = t.TensorView
+	}
+	return tensorio.CreateFile(dst, func(w io.Writer) error {
+		return SaveNPZ(w, tensors)
+	})
+}
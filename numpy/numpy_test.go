@@ -0,0 +1,125 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package numpy
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// npz returns a .npz archive with the given files, compressed as by
+// numpy.savez_compressed.
+func npz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestLoadNPZ(t *testing.T) {
+	archive := npz(t, map[string][]byte{
+		"w.npy":       npy("{'descr': '<f4', 'fortran_order': True, 'shape': (2, 2), }", f32Bytes(1, 3, 2, 4)),
+		"layer/b.npy": npy("{'descr': '|u1', 'fortran_order': False, 'shape': (3,), }", []byte{1, 2, 3}),
+		"README.txt":  []byte("ignored"),
+	})
+	tensors, err := LoadNPZ(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	require.Len(t, tensors, 2)
+	assert.Equal(t, []uint64{2, 2}, tensors["w"].Shape())
+	assert.Equal(t, f32Bytes(1, 2, 3, 4), tensors["w"].Data())
+	assert.Equal(t, safetensors.U8, tensors["layer/b"].DType())
+	assert.Equal(t, []byte{1, 2, 3}, tensors["layer/b"].Data())
+
+	t.Run("invalid array", func(t *testing.T) {
+		archive := npz(t, map[string][]byte{"a.npy": []byte("foo")})
+		_, err := LoadNPZ(bytes.NewReader(archive), int64(len(archive)))
+		assert.EqualError(t, err, `invalid array "a": failed to read .npy header: unexpected EOF`)
+	})
+
+	t.Run("not a zip archive", func(t *testing.T) {
+		_, err := LoadNPZ(bytes.NewReader([]byte("foo")), 3)
+		assert.ErrorContains(t, err, "invalid .npz archive")
+	})
+}
+
+func TestSaveNPZ(t *testing.T) {
+	a, err := safetensors.NewTensorView(safetensors.F64, []uint64{1}, make([]byte, 8))
+	require.NoError(t, err)
+	b, err := safetensors.NewTensorView(safetensors.I32, []uint64{2, 1}, []byte{1, 0, 0, 0, 2, 0, 0, 0})
+	require.NoError(t, err)
+	tensors := map[string]safetensors.TensorView{"b": b, "a": a}
+
+	var buf bytes.Buffer
+	require.NoError(t, SaveNPZ(&buf, tensors))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "a.npy", zr.File[0].Name)
+	assert.Equal(t, "b.npy", zr.File[1].Name)
+	assert.Equal(t, zip.Store, zr.File[0].Method)
+
+	loaded, err := LoadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, tensors, loaded)
+
+	t.Run("unsupported dtype", func(t *testing.T) {
+		c, err := safetensors.NewTensorView(safetensors.F8_E4M3, []uint64{1}, []byte{0})
+		require.NoError(t, err)
+		err = SaveNPZ(new(bytes.Buffer), map[string]safetensors.TensorView{"c": c})
+		assert.EqualError(t, err, `failed to write tensor "c": DType F8_E4M3 cannot be represented in NumPy`)
+	})
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "arrays.npz")
+	require.NoError(t, os.WriteFile(src, npz(t, map[string][]byte{
+		"x.npy": npy("{'descr': '>f4', 'fortran_order': False, 'shape': (2,), }", []byte{0x3f, 0x80, 0, 0, 0x40, 0, 0, 0}),
+	}), 0o600))
+
+	st := filepath.Join(dir, "arrays.safetensors")
+	require.NoError(t, ConvertFile(src, st))
+	f, err := safetensors.Open(st)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, map[string]string{"format": "np"}, f.Metadata().Metadata())
+	tv, ok := f.Tensor("x")
+	require.True(t, ok)
+	assert.Equal(t, f32Bytes(1, 2), tv.Data())
+
+	dst := filepath.Join(dir, "exported.npz")
+	require.NoError(t, ExportFile(st, dst))
+	tensors, err := LoadNPZFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]safetensors.TensorView{"x": tv}, tensors)
+
+	t.Run("removes the output on error", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.safetensors")
+		c, err := safetensors.NewTensorView(safetensors.BF16, []uint64{1}, []byte{0, 0})
+		require.NoError(t, err)
+		data, err := safetensors.Serialize(map[string]safetensors.TensorView{"c": c}, nil)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(bad, data, 0o600))
+
+		out := filepath.Join(dir, "bad.npz")
+		assert.Error(t, ExportFile(bad, out))
+		assert.NoFileExists(t, out)
+	})
+}
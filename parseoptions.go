@@ -0,0 +1,171 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// defaultMaxHeaderSize is the maximum header size used when
+// ParseOptions.MaxHeaderSize is not set.
+const defaultMaxHeaderSize = 100_000_000
+
+// ParseOptions control how the header of a safetensors file is parsed
+// and validated.
+//
+// The zero value provides the default behavior of package-level functions
+// such as Deserialize, NewReader or Open. Setting Strict and the limits is
+// recommended when the data comes from an untrusted source.
+type ParseOptions struct {
+	// MaxHeaderSize is the maximum size of the JSON header, in bytes.
+	// If zero, a default limit of 100 MB is used.
+	MaxHeaderSize uint64
+	// MaxTensors is the maximum number of tensors allowed in the header.
+	// If zero, the number of tensors is not limited.
+	MaxTensors int
+	// MaxNameLength is the maximum length of each tensor name, in bytes.
+	// If zero, the length is not limited.
+	//
+	// MaxTensors and MaxNameLength are checked before the header is
+	// decoded, bounding the memory used to decode it.
+	MaxNameLength int
+	// Strict enables additional checks on the JSON header: it must start
+	// with '{', it must not contain duplicate keys at any level, and the
+	// padding after the JSON object must only consist of spaces.
+	Strict bool
+	// VerifyChecksums verifies the checksums of all tensors, stored in
+	// __metadata__ (see WithChecksums). The verification fails if the
+	// checksums are missing. For a Reader, the checksum of each tensor is
+	// verified when it is read.
+	VerifyChecksums bool
+}
+
+func (o ParseOptions) maxHeaderSize() uint64 {
+	if o.MaxHeaderSize == 0 {
+		return defaultMaxHeaderSize
+	}
+	return o.MaxHeaderSize
+}
+
+// checkLimits verifies the tensor count and name length limits on the raw
+// JSON header, before it is decoded, so that the limits also bound the
+// memory used to decode it. Duplicate names count as separate tensors.
+func (o ParseOptions) checkLimits(header []byte) error {
+	if o.MaxTensors <= 0 && o.MaxNameLength <= 0 {
+		return nil
+	}
+	count, longest, ok := scanNames(header)
+	if !ok {
+		// Syntax errors are reported by the decoding.
+		return nil
+	}
+	if o.MaxTensors > 0 && count > o.MaxTensors {
+		return &LimitError{Err: ErrTooManyTensors, Max: uint64(o.MaxTensors), Actual: uint64(count)}
+	}
+	if o.MaxNameLength > 0 && longest > o.MaxNameLength {
+		return &LimitError{Err: ErrNameTooLong, Max: uint64(o.MaxNameLength), Actual: uint64(longest)}
+	}
+	return nil
+}
+
+// scanNames returns the number of tensors of the JSON header and the
+// length of the longest name, skipping the values without decoding them.
+// It reports false if the header is not a valid JSON object.
+func scanNames(header []byte) (count, longest int, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(header))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, false
+	}
+	var value json.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		if name := tok.(string); name != "__metadata__" {
+			count++
+			if len(name) > longest {
+				longest = len(name)
+			}
+		}
+		if err = dec.Decode(&value); err != nil {
+			return 0, 0, false
+		}
+	}
+	return count, longest, true
+}
+
+// checkStrictHeader performs the additional validation of strict mode
+// on the raw JSON header.
+func checkStrictHeader(header []byte) error {
+	if len(header) == 0 || header[0] != '{' {
+		return fmt.Errorf("%w: must start with '{'", ErrInvalidHeader)
+	}
+	dec := json.NewDecoder(bytes.NewReader(header))
+	dec.UseNumber()
+	if err := checkDuplicateKeys(dec); err != nil {
+		return err
+	}
+	for _, b := range header[dec.InputOffset():] {
+		if b != ' ' {
+			return fmt.Errorf("%w: padding must only contain spaces", ErrInvalidHeader)
+		}
+	}
+	return nil
+}
+
+// checkDuplicateKeys consumes the next JSON value from the decoder,
+// reporting an error if any object contains the same key more than once.
+func checkDuplicateKeys(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	switch delim {
+	case '{':
+		return checkObjectKeys(dec)
+	case '[':
+		for dec.More() {
+			if err = checkDuplicateKeys(dec); err != nil {
+				return err
+			}
+		}
+	}
+	return readClosingDelim(dec)
+}
+
+// checkObjectKeys consumes the members of a JSON object, after its opening
+// delimiter, reporting an error on duplicate keys.
+func checkObjectKeys(dec *json.Decoder) error {
+	keys := make(map[string]struct{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+		key := tok.(string)
+		if _, ok := keys[key]; ok {
+			return fmt.Errorf("%w: duplicate key %q", ErrInvalidHeader, key)
+		}
+		keys[key] = struct{}{}
+		if err = checkDuplicateKeys(dec); err != nil {
+			return err
+		}
+	}
+	return readClosingDelim(dec)
+}
+
+func readClosingDelim(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildRaw builds a safetensors buffer from a raw header and data.
+func buildRaw(header string, dataLen int) []byte {
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+	buf = append(buf, header...)
+	return append(buf, make([]byte, dataLen)...)
+}
+
+func TestParseOptionsStrict(t *testing.T) {
+	strict := ParseOptions{Strict: true}
+
+	t.Run("valid header", func(t *testing.T) {
+		buf := buildRaw(`{"__metadata__":{"a":"b"},"x":{"dtype":"U8","shape":[2],"data_offsets":[0,2]}}  `, 2)
+		st, err := strict.Deserialize(buf)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x"}, st.Names())
+	})
+
+	t.Run("duplicate tensor name", func(t *testing.T) {
+		buf := buildRaw(`{"x":{"dtype":"U8","shape":[2],"data_offsets":[0,2]},`+
+			`"x":{"dtype":"U8","shape":[1],"data_offsets":[0,1]}}`, 1)
+
+		_, err := Deserialize(buf)
+		require.NoError(t, err, "default mode keeps the last key")
+
+		_, err = strict.Deserialize(buf)
+		assert.EqualError(t, err, `invalid header deserialization: duplicate key "x"`)
+	})
+
+	t.Run("duplicate nested key", func(t *testing.T) {
+		buf := buildRaw(`{"x":{"dtype":"U8","dtype":"I8","shape":[1],"data_offsets":[0,1]}}`, 1)
+		_, err := strict.Deserialize(buf)
+		assert.EqualError(t, err, `invalid header deserialization: duplicate key "dtype"`)
+	})
+
+	t.Run("duplicate metadata key", func(t *testing.T) {
+		buf := buildRaw(`{"__metadata__":{"a":"b","a":"c"}}`, 0)
+		_, err := strict.Deserialize(buf)
+		assert.EqualError(t, err, `invalid header deserialization: duplicate key "a"`)
+	})
+
+	t.Run("leading whitespace", func(t *testing.T) {
+		buf := buildRaw(` {}`, 0)
+
+		_, err := Deserialize(buf)
+		require.NoError(t, err)
+
+		_, err = strict.Deserialize(buf)
+		assert.EqualError(t, err, "invalid header deserialization: must start with '{'")
+	})
+
+	t.Run("non-space padding", func(t *testing.T) {
+		buf := buildRaw("{}\n\t", 0)
+
+		_, err := Deserialize(buf)
+		require.NoError(t, err)
+
+		_, err = strict.Deserialize(buf)
+		assert.EqualError(t, err, "invalid header deserialization: padding must only contain spaces")
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		buf := buildRaw(`{"x":`, 0)
+		_, err := strict.Deserialize(buf)
+		assert.ErrorContains(t, err, "invalid header deserialization")
+	})
+}
+
+func TestParseOptionsLimits(t *testing.T) {
+	header := `{"a":{"dtype":"U8","shape":[1],"data_offsets":[0,1]},` +
+		`"long_name":{"dtype":"U8","shape":[1],"data_offsets":[1,2]}}`
+	buf := buildRaw(header, 2)
+
+	t.Run("max header size", func(t *testing.T) {
+		_, err := ParseOptions{MaxHeaderSize: 16}.Deserialize(buf)
+		assert.EqualError(t, err, "header too large: max 16, actual 113")
+
+		_, err = ParseOptions{MaxHeaderSize: uint64(len(header))}.Deserialize(buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("max tensors", func(t *testing.T) {
+		_, err := ParseOptions{MaxTensors: 1}.Deserialize(buf)
+		assert.EqualError(t, err, "too many tensors: max 1, actual 2")
+
+		_, err = ParseOptions{MaxTensors: 2}.Deserialize(buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("max name length", func(t *testing.T) {
+		_, err := ParseOptions{MaxNameLength: 4}.Deserialize(buf)
+		assert.EqualError(t, err, "tensor name too long: max 4, actual 9")
+
+		_, err = ParseOptions{MaxNameLength: 9}.Deserialize(buf)
+		assert.NoError(t, err)
+	})
+
+	t.Run("before decoding", func(t *testing.T) {
+		// The entries are not decoded, and duplicates are counted.
+		invalid := buildRaw(`{"a":{"dtype":"??"},"a":{"dtype":"??"},"__metadata__":[]}`, 0)
+		_, err := ParseOptions{MaxTensors: 1}.Deserialize(invalid)
+		assert.EqualError(t, err, "too many tensors: max 1, actual 2")
+		_, err = ParseOptions{MaxTensors: 2}.Deserialize(invalid)
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		_, err := ParseOptions{MaxTensors: 1}.NewReader(bytes.NewReader(buf), int64(len(buf)))
+		assert.EqualError(t, err, "too many tensors: max 1, actual 2")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		name := writeTempFile(t, buf)
+		_, err := ParseOptions{MaxNameLength: 4}.Open(name)
+		assert.EqualError(t, err, "tensor name too long: max 4, actual 9")
+	})
+}
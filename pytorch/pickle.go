@@ -0,0 +1,560 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pytorch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// unpickler is a virtual machine interpreting the restricted subset of the
+// pickle protocol used by torch.save.
+//
+// No code is ever executed: globals are resolved through the callables
+// allow-listed in the globals table, and persistent IDs through
+// persistentLoad.
+type unpickler struct {
+	r     *bufio.Reader
+	stack []any
+	// marks are the positions of the stack saved by MARK.
+	marks []int
+	memo  map[uint32]any
+	// persistentLoad resolves persistent IDs.
+	persistentLoad func(pid any) (any, error)
+	// stopped is set by STOP.
+	stopped bool
+}
+
+// Python values which have no direct Go counterpart.
+type (
+	// tuple is a Python tuple.
+	tuple []any
+	// list is a Python list. It is a pointer, since lists are mutable
+	// and may be referenced more than once through the memo.
+	list struct{ items []any }
+	// global is a reference to a Python object by module and name.
+	global struct{ module, name string }
+)
+
+// dict is a Python dict, preserving insertion order.
+type dict struct {
+	keys   []any
+	values map[any]any
+}
+
+func newDict() *dict {
+	return &dict{values: make(map[any]any)}
+}
+
+func (d *dict) set(key, value any) error {
+	switch key.(type) {
+	case string, int64, float64, bool, nil:
+	default:
+		return fmt.Errorf("unsupported dict key type %T", key)
+	}
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+	return nil
+}
+
+// opcode handlers, indexed by opcode byte.
+var opcodes [256]func(u *unpickler) error
+
+func init() {
+	for op, fn := range map[byte]func(u *unpickler) error{
+		0x80: (*unpickler).loadProto,
+		0x95: (*unpickler).loadFrame,
+		'.':  (*unpickler).loadStop,
+		'(':  (*unpickler).loadMark,
+		'N':  func(u *unpickler) error { u.push(nil); return nil },
+		0x88: func(u *unpickler) error { u.push(true); return nil },
+		0x89: func(u *unpickler) error { u.push(false); return nil },
+		'J':  func(u *unpickler) error { return u.loadInt(4, true) },
+		'K':  func(u *unpickler) error { return u.loadInt(1, false) },
+		'M':  func(u *unpickler) error { return u.loadInt(2, false) },
+		0x8a: (*unpickler).loadLong1,
+		'G':  (*unpickler).loadBinFloat,
+		'X':  func(u *unpickler) error { return u.loadString(4, true) },
+		0x8c: func(u *unpickler) error { return u.loadString(1, true) },
+		0x8d: func(u *unpickler) error { return u.loadString(8, true) },
+		// BINSTRING and SHORT_BINSTRING are byte strings, decoded as by
+		// Python with encoding="bytes".
+		'T':  func(u *unpickler) error { return u.loadString(4, false) },
+		'U':  func(u *unpickler) error { return u.loadString(1, false) },
+		'B':  func(u *unpickler) error { return u.loadString(4, false) },
+		'C':  func(u *unpickler) error { return u.loadString(1, false) },
+		')':  func(u *unpickler) error { u.push(tuple{}); return nil },
+		't':  (*unpickler).loadTuple,
+		0x85: func(u *unpickler) error { return u.loadTupleN(1) },
+		0x86: func(u *unpickler) error { return u.loadTupleN(2) },
+		0x87: func(u *unpickler) error { return u.loadTupleN(3) },
+		']':  func(u *unpickler) error { u.push(&list{}); return nil },
+		'l':  (*unpickler).loadList,
+		'a':  (*unpickler).loadAppend,
+		'e':  (*unpickler).loadAppends,
+		'}':  func(u *unpickler) error { u.push(newDict()); return nil },
+		'd':  (*unpickler).loadDict,
+		's':  (*unpickler).loadSetItem,
+		'u':  (*unpickler).loadSetItems,
+		'q':  func(u *unpickler) error { return u.loadPut(1) },
+		'r':  func(u *unpickler) error { return u.loadPut(4) },
+		0x94: (*unpickler).loadMemoize,
+		'h':  func(u *unpickler) error { return u.loadGet(1) },
+		'j':  func(u *unpickler) error { return u.loadGet(4) },
+		'c':  (*unpickler).loadGlobal,
+		0x93: (*unpickler).loadStackGlobal,
+		'R':  (*unpickler).loadReduce,
+		0x81: (*unpickler).loadNewObj,
+		'b':  (*unpickler).loadBuild,
+		'Q':  (*unpickler).loadBinPersID,
+		'0':  func(u *unpickler) error { _, err := u.pop(); return err },
+	} {
+		opcodes[op] = fn
+	}
+}
+
+// unpickle decodes a single pickled object from r.
+func unpickle(r io.Reader, persistentLoad func(pid any) (any, error)) (any, error) {
+	u := &unpickler{
+		r:              bufio.NewReader(r),
+		memo:           make(map[uint32]any),
+		persistentLoad: persistentLoad,
+	}
+	for !u.stopped {
+		op, err := u.r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pickle opcode: %w", noEOF(err))
+		}
+		fn := opcodes[op]
+		if fn == nil {
+			return nil, fmt.Errorf("unsupported pickle opcode 0x%02x", op)
+		}
+		if err = fn(u); err != nil {
+			return nil, err
+		}
+	}
+	return u.pop()
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, since the pickle stream
+// must be terminated by STOP.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (u *unpickler) push(v any) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (any, error) {
+	n := len(u.stack)
+	if n == 0 || (len(u.marks) > 0 && u.marks[len(u.marks)-1] == n) {
+		return nil, errors.New("pickle stack underflow")
+	}
+	v := u.stack[n-1]
+	u.stack = u.stack[:n-1]
+	return v, nil
+}
+
+func (u *unpickler) top() (any, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("pickle stack underflow")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark pops all the items pushed after the last MARK.
+func (u *unpickler) popMark() ([]any, error) {
+	n := len(u.marks)
+	if n == 0 {
+		return nil, errors.New("pickle mark not found")
+	}
+	pos := u.marks[n-1]
+	u.marks = u.marks[:n-1]
+	items := append([]any(nil), u.stack[pos:]...)
+	u.stack = u.stack[:pos]
+	return items, nil
+}
+
+func (u *unpickler) read(n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("pickle argument too large: %d bytes", n)
+	}
+	var buf []byte
+	var err error
+	if n <= readChunkSize {
+		buf = make([]byte, n)
+		_, err = io.ReadFull(u.r, buf)
+	} else {
+		// The buffer grows as the data is read, so that the declared
+		// length alone does not allocate memory.
+		buf, err = io.ReadAll(io.LimitReader(u.r, int64(n)))
+		if err == nil && uint64(len(buf)) < n {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pickle argument: %w", noEOF(err))
+	}
+	return buf, nil
+}
+
+// readChunkSize is the size of the arguments which are read at once.
+const readChunkSize = 1 << 16
+
+// readUint reads an unsigned little-endian integer of size bytes.
+func (u *unpickler) readUint(size int) (uint64, error) {
+	buf, err := u.read(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var b [8]byte
+	copy(b[:], buf)
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func (u *unpickler) loadProto() error {
+	proto, err := u.r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if proto > 5 {
+		return fmt.Errorf("unsupported pickle protocol %d", proto)
+	}
+	return nil
+}
+
+// loadFrame ignores the frame size: frames are only a hint for buffering.
+func (u *unpickler) loadFrame() error {
+	_, err := u.readUint(8)
+	return err
+}
+
+func (u *unpickler) loadStop() error {
+	u.stopped = true
+	return nil
+}
+
+func (u *unpickler) loadMark() error {
+	u.marks = append(u.marks, len(u.stack))
+	return nil
+}
+
+func (u *unpickler) loadInt(size int, signed bool) error {
+	v, err := u.readUint(size)
+	if err != nil {
+		return err
+	}
+	if signed {
+		u.push(int64(int32(v)))
+	} else {
+		u.push(int64(v))
+	}
+	return nil
+}
+
+func (u *unpickler) loadLong1() error {
+	n, err := u.r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	buf, err := u.read(uint64(n))
+	if err != nil {
+		return err
+	}
+	// Two's complement little-endian.
+	be := make([]byte, len(buf))
+	for i, b := range buf {
+		be[len(buf)-1-i] = b
+	}
+	v := new(big.Int).SetBytes(be)
+	if len(buf) > 0 && buf[len(buf)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(buf))))
+	}
+	if !v.IsInt64() {
+		return fmt.Errorf("pickle integer %v out of range", v)
+	}
+	u.push(v.Int64())
+	return nil
+}
+
+func (u *unpickler) loadBinFloat() error {
+	buf, err := u.read(8)
+	if err != nil {
+		return err
+	}
+	u.push(math.Float64frombits(binary.BigEndian.Uint64(buf)))
+	return nil
+}
+
+// loadString loads a length-prefixed string, or bytes if !unicode.
+func (u *unpickler) loadString(lenSize int, unicode bool) error {
+	n, err := u.readUint(lenSize)
+	if err != nil {
+		return err
+	}
+	buf, err := u.read(n)
+	if err != nil {
+		return err
+	}
+	if unicode {
+		u.push(string(buf))
+	} else {
+		u.push(buf)
+	}
+	return nil
+}
+
+func (u *unpickler) loadTuple() error {
+	items, err := u.popMark()
+	if err != nil {
+		return err
+	}
+	u.push(tuple(items))
+	return nil
+}
+
+func (u *unpickler) loadTupleN(n int) error {
+	items := make(tuple, n)
+	for i := n - 1; i >= 0; i-- {
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		items[i] = v
+	}
+	u.push(items)
+	return nil
+}
+
+func (u *unpickler) loadList() error {
+	items, err := u.popMark()
+	if err != nil {
+		return err
+	}
+	u.push(&list{items: items})
+	return nil
+}
+
+func (u *unpickler) loadAppend() error {
+	v, err := u.pop()
+	if err != nil {
+		return err
+	}
+	return u.appendItems([]any{v})
+}
+
+func (u *unpickler) loadAppends() error {
+	items, err := u.popMark()
+	if err != nil {
+		return err
+	}
+	return u.appendItems(items)
+}
+
+func (u *unpickler) appendItems(items []any) error {
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	l, ok := v.(*list)
+	if !ok {
+		return fmt.Errorf("cannot append to %T", v)
+	}
+	l.items = append(l.items, items...)
+	return nil
+}
+
+func (u *unpickler) loadDict() error {
+	items, err := u.popMark()
+	if err != nil {
+		return err
+	}
+	d := newDict()
+	if err = setItems(d, items); err != nil {
+		return err
+	}
+	u.push(d)
+	return nil
+}
+
+func (u *unpickler) loadSetItem() error {
+	value, err := u.pop()
+	if err != nil {
+		return err
+	}
+	key, err := u.pop()
+	if err != nil {
+		return err
+	}
+	return u.setItems([]any{key, value})
+}
+
+func (u *unpickler) loadSetItems() error {
+	items, err := u.popMark()
+	if err != nil {
+		return err
+	}
+	return u.setItems(items)
+}
+
+func (u *unpickler) setItems(items []any) error {
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	d, ok := v.(*dict)
+	if !ok {
+		return fmt.Errorf("cannot set items of %T", v)
+	}
+	return setItems(d, items)
+}
+
+// setItems sets the alternating keys and values of items.
+func setItems(d *dict, items []any) error {
+	if len(items)%2 != 0 {
+		return errors.New("odd number of dict items")
+	}
+	for i := 0; i < len(items); i += 2 {
+		if err := d.set(items[i], items[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpickler) loadPut(size int) error {
+	idx, err := u.readUint(size)
+	if err != nil {
+		return err
+	}
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	u.memo[uint32(idx)] = v
+	return nil
+}
+
+func (u *unpickler) loadMemoize() error {
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	u.memo[uint32(len(u.memo))] = v
+	return nil
+}
+
+func (u *unpickler) loadGet(size int) error {
+	idx, err := u.readUint(size)
+	if err != nil {
+		return err
+	}
+	v, ok := u.memo[uint32(idx)]
+	if !ok {
+		return fmt.Errorf("pickle memo key %d not found", idx)
+	}
+	u.push(v)
+	return nil
+}
+
+func (u *unpickler) loadGlobal() error {
+	module, err := u.readLine()
+	if err != nil {
+		return err
+	}
+	name, err := u.readLine()
+	if err != nil {
+		return err
+	}
+	u.push(global{module: module, name: name})
+	return nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+	line, err := u.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read pickle argument: %w", noEOF(err))
+	}
+	return line[:len(line)-1], nil
+}
+
+func (u *unpickler) loadStackGlobal() error {
+	name, err := u.pop()
+	if err != nil {
+		return err
+	}
+	module, err := u.pop()
+	if err != nil {
+		return err
+	}
+	ms, ok1 := module.(string)
+	ns, ok2 := name.(string)
+	if !ok1 || !ok2 {
+		return errors.New("invalid STACK_GLOBAL arguments")
+	}
+	u.push(global{module: ms, name: ns})
+	return nil
+}
+
+func (u *unpickler) loadReduce() error {
+	args, err := u.pop()
+	if err != nil {
+		return err
+	}
+	fn, err := u.pop()
+	if err != nil {
+		return err
+	}
+	v, err := call(fn, args)
+	if err != nil {
+		return err
+	}
+	u.push(v)
+	return nil
+}
+
+// loadNewObj handles NEWOBJ as a call of the class with the arguments.
+func (u *unpickler) loadNewObj() error {
+	return u.loadReduce()
+}
+
+func (u *unpickler) loadBuild() error {
+	state, err := u.pop()
+	if err != nil {
+		return err
+	}
+	obj, err := u.top()
+	if err != nil {
+		return err
+	}
+	return build(obj, state)
+}
+
+func (u *unpickler) loadBinPersID() error {
+	pid, err := u.pop()
+	if err != nil {
+		return err
+	}
+	if u.persistentLoad == nil {
+		return errors.New("unsupported persistent ID")
+	}
+	v, err := u.persistentLoad(pid)
+	if err != nil {
+		return err
+	}
+	u.push(v)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pytorch
+
+import (
+	"bytes"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpickle(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pickle   string
+		expected any
+	}{
+		{"none", "\x80\x02N.", nil},
+		{"bools", "\x80\x02\x88\x89\x86.", tuple{true, false}},
+		{"small ints", "\x80\x02K\xffM\x00\x01J\xff\xff\xff\xff\x87.", tuple{int64(255), int64(256), int64(-1)}},
+		{"long1", "\x80\x02\x8a\x02\x00\xff\x8a\x08\x00\x00\x00\x00\x00\x00\x00\x80\x8a\x00\x87.",
+			tuple{int64(-256), int64(math.MinInt64), int64(0)}},
+		{"float", "\x80\x02G\x3f\xf8\x00\x00\x00\x00\x00\x00.", 1.5},
+		{"strings", "\x80\x02X\x01\x00\x00\x00a\x8c\x01bC\x01c\x87.", tuple{"a", "b", []byte("c")}},
+		{"binstrings", "\x80\x02T\x01\x00\x00\x00aU\x01b\x86.", tuple{[]byte("a"), []byte("b")}},
+		{"list", "\x80\x02]q\x00(K\x01K\x02eh\x00K\x03a\x86.", tuple{&list{items: []any{int64(1), int64(2), int64(3)}}, &list{items: []any{int64(1), int64(2), int64(3)}}}},
+		{"protocol 4", "\x80\x04\x95\x10\x00\x00\x00\x00\x00\x00\x00\x8c\x01a\x94K\x01\x86\x94h\x00\x86.", tuple{tuple{"a", int64(1)}, "a"}},
+		{"pop", "\x80\x02K\x01K\x020.", int64(1)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := unpickle(bytes.NewReader([]byte(tc.pickle)), nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+
+	t.Run("dict", func(t *testing.T) {
+		v, err := unpickle(bytes.NewReader([]byte("\x80\x02}(X\x01\x00\x00\x00bK\x01X\x01\x00\x00\x00aK\x02uX\x01\x00\x00\x00bK\x03s.")), nil)
+		require.NoError(t, err)
+		d, ok := v.(*dict)
+		require.True(t, ok)
+		assert.Equal(t, []any{"b", "a"}, d.keys)
+		assert.Equal(t, map[any]any{"a": int64(2), "b": int64(3)}, d.values)
+	})
+
+	t.Run("stack global", func(t *testing.T) {
+		v, err := unpickle(bytes.NewReader([]byte("\x80\x04\x8c\x05torch\x8c\x06Tensor\x93.")), nil)
+		require.NoError(t, err)
+		assert.Equal(t, global{module: "torch", name: "Tensor"}, v)
+	})
+
+	errorCases := []struct {
+		name   string
+		pickle string
+		err    string
+	}{
+		{"empty", "", "failed to read pickle opcode: unexpected EOF"},
+		{"missing stop", "\x80\x02N", "failed to read pickle opcode: unexpected EOF"},
+		{"unsupported opcode", "\x80\x02i", "unsupported pickle opcode 0x69"},
+		{"unsupported protocol", "\x80\x06N.", "unsupported pickle protocol 6"},
+		{"stack underflow", "\x80\x02\x86.", "pickle stack underflow"},
+		{"pop across mark", "\x80\x02K\x01(\x85.", "pickle stack underflow"},
+		{"missing mark", "\x80\x02t.", "pickle mark not found"},
+		{"memo not found", "\x80\x02h\x01.", "pickle memo key 1 not found"},
+		{"truncated argument", "\x80\x02X\x05\x00\x00\x00ab", "failed to read pickle argument: unexpected EOF"},
+		{"truncated large argument", "\x80\x02X\xff\xff\xff\x7fab", "failed to read pickle argument: unexpected EOF"},
+		{"unhashable key", "\x80\x02})K\x01s.", "unsupported dict key type pytorch.tuple"},
+		{"long1 out of range", "\x80\x02\x8a\x09\x00\x00\x00\x00\x00\x00\x00\x00\x01.", "pickle integer 18446744073709551616 out of range"},
+		{"persistent ID", "\x80\x02NQ.", "unsupported persistent ID"},
+		{"call non-global", "\x80\x02K\x01)R.", "cannot call int64"},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := unpickle(bytes.NewReader([]byte(tc.pickle)), nil)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestUnpickleDeclaredLength(t *testing.T) {
+	// A declared length of about 2 GB must not be allocated upfront.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := unpickle(bytes.NewReader([]byte("\x80\x02X\xff\xff\xff\x7fab")), nil)
+	runtime.ReadMemStats(&after)
+	assert.Error(t, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pytorch reads PyTorch checkpoints saved with torch.save, such as
+// pytorch_model.bin files, and converts them to safetensors.
+//
+// Only the zip-based format, used by default since PyTorch 1.6, is
+// supported. The pickled data is interpreted by a restricted unpickler,
+// which never executes code: only the globals needed to rebuild state
+// dicts of tensors are allowed, and any other global is rejected.
+package pytorch
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// Load reads a PyTorch checkpoint from r, which is assumed to have the
+// given size in bytes, and returns its tensors.
+//
+// The checkpoint must contain a dict. Nested dicts are flattened, joining
+// their keys with ".", so that the tensors of a checkpoint containing
+// {"model": {"w": tensor}} are returned as {"model.w": tensor}. Values
+// which are not tensors nor dicts, such as epoch counters, are ignored.
+//
+// Tensors sharing the same storage are copied independently. Tensors with
+// more data than their storage, such as views expanded with zero strides,
+// are not supported.
+func Load(r io.ReaderAt, size int64) (map[string]safetensors.TensorView, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PyTorch archive (legacy formats are not supported): %w", err)
+	}
+	a, err := newArchive(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := a.open("data.pkl")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	root, err := unpickle(f, persistentLoad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpickle data.pkl: %w", err)
+	}
+	d, ok := root.(*dict)
+	if !ok {
+		return nil, fmt.Errorf("invalid checkpoint: expected dict, actual %T", root)
+	}
+
+	tensors := make(map[string]safetensors.TensorView)
+	if err = a.collect(tensors, "", d); err != nil {
+		return nil, err
+	}
+	return tensors, nil
+}
+
+// LoadFile is like Load, reading the named file.
+func LoadFile(name string) (map[string]safetensors.TensorView, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Load(f, fi.Size())
+}
+
+// ConvertFile converts the PyTorch checkpoint src to the safetensors
+// file dst, with the "format" metadata set to "pt".
+func ConvertFile(src, dst string) error {
+	tensors, err := LoadFile(src)
+	if err != nil {
+		return err
+	}
+	return tensorio.CreateFile(dst, func(w io.Writer) error {
+		return safetensors.SerializeToWriter(tensors, map[string]string{"format": "pt"}, w)
+	})
+}
+
+// archive provides access to the records of a PyTorch zip archive.
+type archive struct {
+	files map[string]*zip.File
+	// prefix is the top-level directory of all records.
+	prefix string
+	// bigEndian reports whether storages are big-endian.
+	bigEndian bool
+	// storages caches the data of each storage by key.
+	storages map[string][]byte
+	// visiting are the dicts being collected, for detecting cycles.
+	visiting map[*dict]bool
+}
+
+func newArchive(zr *zip.Reader) (*archive, error) {
+	a := &archive{
+		files:    make(map[string]*zip.File, len(zr.File)),
+		storages: make(map[string][]byte),
+		visiting: make(map[*dict]bool),
+	}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+		if path.Base(f.Name) == "data.pkl" {
+			a.prefix = strings.TrimSuffix(f.Name, "data.pkl")
+		}
+	}
+	if _, ok := a.files[a.prefix+"data.pkl"]; !ok {
+		return nil, errors.New("invalid PyTorch archive: data.pkl not found")
+	}
+	if order, err := a.readAll("byteorder"); err == nil {
+		a.bigEndian = strings.TrimSpace(string(order)) == "big"
+	}
+	return a, nil
+}
+
+func (a *archive) open(name string) (io.ReadCloser, error) {
+	f, ok := a.files[a.prefix+name]
+	if !ok {
+		return nil, fmt.Errorf("invalid PyTorch archive: %s not found", name)
+	}
+	return f.Open()
+}
+
+func (a *archive) readAll(name string) ([]byte, error) {
+	f, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// storageData returns the data of a storage, converted to little-endian.
+func (a *archive) storageData(s *storage) ([]byte, error) {
+	if data, ok := a.storages[s.key]; ok {
+		return data, nil
+	}
+	data, err := a.readAll("data/" + s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage %q: %w", s.key, err)
+	}
+	if a.bigEndian {
+		tensorio.SwapBytes(data, int(s.dType.Size()))
+	}
+	a.storages[s.key] = data
+	return data, nil
+}
+
+// collect adds to tensors the tensors of d, flattening nested dicts.
+func (a *archive) collect(tensors map[string]safetensors.TensorView, prefix string, d *dict) error {
+	if a.visiting[d] {
+		return fmt.Errorf("invalid checkpoint: recursive dict %q", prefix)
+	}
+	a.visiting[d] = true
+	defer delete(a.visiting, d)
+
+	for _, k := range d.keys {
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		name := prefix + key
+		switch v := d.values[k].(type) {
+		case *tensor:
+			tv, err := a.materialize(v)
+			if err != nil {
+				return fmt.Errorf("invalid tensor %q: %w", name, err)
+			}
+			tensors[name] = tv
+		case *dict:
+			if err := a.collect(tensors, name+".", v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pytorch
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pickler builds pickle streams, as produced by torch.save.
+type pickler struct {
+	bytes.Buffer
+}
+
+func (p *pickler) op(ops ...byte) *pickler {
+	p.Write(ops)
+	return p
+}
+
+func (p *pickler) str(s string) *pickler {
+	p.WriteByte('X')
+	_ = binary.Write(p, binary.LittleEndian, uint32(len(s)))
+	p.WriteString(s)
+	return p
+}
+
+func (p *pickler) int(n int32) *pickler {
+	p.WriteByte('J')
+	_ = binary.Write(p, binary.LittleEndian, n)
+	return p
+}
+
+func (p *pickler) global(module, name string) *pickler {
+	p.WriteString("c" + module + "\n" + name + "\n")
+	return p
+}
+
+// ints pushes a tuple of integers.
+func (p *pickler) ints(values ...int32) *pickler {
+	p.op('(')
+	for _, v := range values {
+		p.int(v)
+	}
+	return p.op('t')
+}
+
+// tensor pushes a tensor rebuilt with _rebuild_tensor_v2.
+func (p *pickler) tensor(storageType, key string, numel, offset int32, shape, stride []int32) *pickler {
+	p.global("torch._utils", "_rebuild_tensor_v2")
+	p.op('(')
+	p.op('(').str("storage").global("torch", storageType).str(key).str("cpu").int(numel).op('t', 'Q')
+	p.int(offset).ints(shape...).ints(stride...)
+	p.op(0x89)
+	p.global("collections", "OrderedDict").op(')', 'R')
+	return p.op('t', 'R')
+}
+
+// writeArchive writes a PyTorch zip archive with the given pickle and
+// storages, returning its content.
+func writeArchive(t *testing.T, pkl []byte, storages map[string][]byte, byteOrder string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, data []byte) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "archive/" + name, Method: zip.Store})
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	write("data.pkl", pkl)
+	if byteOrder != "" {
+		write("byteorder", []byte(byteOrder))
+	}
+	for key, data := range storages {
+		write("data/"+key, data)
+	}
+	write("version", []byte("3\n"))
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func f32Bytes(values ...float32) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, values)
+	return buf.Bytes()
+}
+
+func load(t *testing.T, archive []byte) (map[string]safetensors.TensorView, error) {
+	t.Helper()
+	return Load(bytes.NewReader(archive), int64(len(archive)))
+}
+
+func TestLoad(t *testing.T) {
+	p := new(pickler)
+	p.op(0x80, 2)
+	p.global("collections", "OrderedDict").op(')', 'R', 'q', 0)
+	p.op('(')
+	// Contiguous 2x3 tensor.
+	p.str("w").tensor("FloatStorage", "0", 6, 0, []int32{2, 3}, []int32{3, 1})
+	// Transposed view of the same storage.
+	p.str("w_t").tensor("FloatStorage", "0", 6, 0, []int32{3, 2}, []int32{1, 3})
+	// Scalar with an offset in the same storage.
+	p.str("s").tensor("FloatStorage", "0", 6, 4, []int32{}, []int32{})
+	// Parameter wrapping a tensor.
+	p.str("p").global("torch._utils", "_rebuild_parameter")
+	p.op('(').tensor("LongStorage", "1", 1, 0, []int32{1}, []int32{1})
+	p.op(0x88).global("collections", "OrderedDict").op(')', 'R', 't', 'R')
+	// Nested dict, and a value which is not a tensor.
+	p.str("sub").op('}', '(').str("b").tensor("ByteStorage", "2", 2, 0, []int32{2}, []int32{1}).op('u')
+	p.str("epoch").int(3)
+	p.op('u', '.')
+
+	storages := map[string][]byte{
+		"0": f32Bytes(1, 2, 3, 4, 5, 6),
+		"1": {42, 0, 0, 0, 0, 0, 0, 0},
+		"2": {7, 8},
+	}
+
+	t.Run("tensors", func(t *testing.T) {
+		tensors, err := load(t, writeArchive(t, p.Bytes(), storages, "little"))
+		require.NoError(t, err)
+		require.Len(t, tensors, 5)
+
+		check := func(name string, dType safetensors.DType, shape []uint64, data []byte) {
+			tv, ok := tensors[name]
+			require.True(t, ok, name)
+			assert.Equal(t, dType, tv.DType(), name)
+			assert.Equal(t, shape, tv.Shape(), name)
+			assert.Equal(t, data, tv.Data(), name)
+		}
+		check("w", safetensors.F32, []uint64{2, 3}, f32Bytes(1, 2, 3, 4, 5, 6))
+		check("w_t", safetensors.F32, []uint64{3, 2}, f32Bytes(1, 4, 2, 5, 3, 6))
+		check("s", safetensors.F32, []uint64{}, f32Bytes(5))
+		check("p", safetensors.I64, []uint64{1}, []byte{42, 0, 0, 0, 0, 0, 0, 0})
+		check("sub.b", safetensors.U8, []uint64{2}, []byte{7, 8})
+	})
+
+	t.Run("big endian", func(t *testing.T) {
+		be := map[string][]byte{
+			"0": {0x3f, 0x80, 0, 0, 0x40, 0, 0, 0, 0x40, 0x40, 0, 0, 0x40, 0x80, 0, 0, 0x40, 0xa0, 0, 0, 0x40, 0xc0, 0, 0},
+			"1": {0, 0, 0, 0, 0, 0, 0, 42},
+			"2": {7, 8},
+		}
+		tensors, err := load(t, writeArchive(t, p.Bytes(), be, "big"))
+		require.NoError(t, err)
+		assert.Equal(t, f32Bytes(1, 2, 3, 4, 5, 6), tensors["w"].Data())
+		assert.Equal(t, []byte{42, 0, 0, 0, 0, 0, 0, 0}, tensors["p"].Data())
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "pytorch_model.bin")
+		dst := filepath.Join(dir, "model.safetensors")
+		require.NoError(t, os.WriteFile(src, writeArchive(t, p.Bytes(), storages, ""), 0o600))
+
+		require.NoError(t, ConvertFile(src, dst))
+		f, err := safetensors.Open(dst)
+		require.NoError(t, err)
+		defer f.Close()
+		assert.Equal(t, map[string]string{"format": "pt"}, f.Metadata().Metadata())
+		assert.Equal(t, 5, f.Len())
+		tv, _ := f.Tensor("w_t")
+		assert.Equal(t, f32Bytes(1, 4, 2, 5, 3, 6), tv.Data())
+	})
+
+	t.Run("state dict metadata", func(t *testing.T) {
+		// torch.save(model.state_dict()) sets the _metadata attribute of
+		// the OrderedDict, which is pickled as the state of BUILD.
+		p := new(pickler)
+		p.op(0x80, 2)
+		p.global("collections", "OrderedDict").op(')', 'R')
+		p.op('(').str("w").tensor("FloatStorage", "0", 6, 0, []int32{2, 3}, []int32{3, 1}).op('u')
+		p.op('}').str("_metadata")
+		p.global("collections", "OrderedDict").op(')', 'R')
+		p.op('(').str("").op('}').str("version").int(1).op('s', 'u')
+		p.op('s', 'b', '.')
+
+		v, err := unpickle(bytes.NewReader(p.Bytes()), persistentLoad)
+		require.NoError(t, err)
+		require.IsType(t, &dict{}, v)
+		assert.Equal(t, []any{"w"}, v.(*dict).keys)
+
+		tensors, err := load(t, writeArchive(t, p.Bytes(), storages, ""))
+		require.NoError(t, err)
+		assert.Len(t, tensors, 1)
+		assert.Equal(t, f32Bytes(1, 2, 3, 4, 5, 6), tensors["w"].Data())
+	})
+
+	t.Run("missing storage", func(t *testing.T) {
+		_, err := load(t, writeArchive(t, p.Bytes(), map[string][]byte{"0": storages["0"]}, ""))
+		assert.ErrorContains(t, err, `invalid tensor "p": failed to read storage "1"`)
+	})
+
+	t.Run("storage too small", func(t *testing.T) {
+		small := map[string][]byte{"0": f32Bytes(1, 2), "1": storages["1"], "2": storages["2"]}
+		_, err := load(t, writeArchive(t, p.Bytes(), small, ""))
+		assert.ErrorContains(t, err, `invalid tensor "w": storage "0" too small`)
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("not a zip archive", func(t *testing.T) {
+		_, err := load(t, []byte("foo"))
+		assert.ErrorContains(t, err, "invalid PyTorch archive")
+	})
+
+	t.Run("disallowed global", func(t *testing.T) {
+		p := new(pickler)
+		p.op(0x80, 2).global("os", "system").op('(').str("echo").op('t', 'R', '.')
+		_, err := load(t, writeArchive(t, p.Bytes(), nil, ""))
+		assert.EqualError(t, err, "failed to unpickle data.pkl: global os.system is not allowed")
+	})
+
+	t.Run("disallowed storage type", func(t *testing.T) {
+		p := new(pickler)
+		p.op(0x80, 2, '}').str("a").tensor("FooStorage", "0", 1, 0, []int32{1}, []int32{1}).op('s', '.')
+		_, err := load(t, writeArchive(t, p.Bytes(), nil, ""))
+		assert.ErrorContains(t, err, "unsupported storage type")
+	})
+
+	t.Run("not a dict", func(t *testing.T) {
+		p := new(pickler)
+		p.op(0x80, 2).int(1).op('.')
+		_, err := load(t, writeArchive(t, p.Bytes(), nil, ""))
+		assert.EqualError(t, err, "invalid checkpoint: expected dict, actual int64")
+	})
+
+	t.Run("recursive dict", func(t *testing.T) {
+		p := new(pickler)
+		p.op(0x80, 2, '}', 'q', 0).str("a").op('h', 0, 's', '.')
+		_, err := load(t, writeArchive(t, p.Bytes(), nil, ""))
+		assert.ErrorContains(t, err, "recursive dict")
+	})
+
+	t.Run("huge shape", func(t *testing.T) {
+		for _, tc := range []struct {
+			shape  []int32
+			stride []int32
+			err    string
+		}{
+			{[]int32{1 << 20, 1 << 20, 1 << 20}, []int32{0, 0, 0}, "tensor too large"},
+			{[]int32{1 << 30, 1 << 30, 1 << 30}, []int32{0, 0, 0}, "tensor size overflow"},
+			// Overlapping strides, within the storage.
+			{[]int32{1, 3, 3}, []int32{0, 1, 1}, `tensor too large: 9 elements of 1 bytes, storage "0" has 5 bytes`},
+		} {
+			p := new(pickler)
+			p.op(0x80, 2, '}').str("a")
+			p.tensor("ByteStorage", "0", 5, 0, tc.shape, tc.stride).op('s', '.')
+			_, err := load(t, writeArchive(t, p.Bytes(), map[string][]byte{"0": {1, 2, 3, 4, 5}}, ""))
+			assert.ErrorContains(t, err, tc.err)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pytorch
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// storageTypes maps the allow-listed torch storage classes to DTypes.
+var storageTypes = map[string]safetensors.DType{
+	"BoolStorage":     safetensors.BOOL,
+	"ByteStorage":     safetensors.U8,
+	"CharStorage":     safetensors.I8,
+	"ShortStorage":    safetensors.I16,
+	"IntStorage":      safetensors.I32,
+	"LongStorage":     safetensors.I64,
+	"HalfStorage":     safetensors.F16,
+	"BFloat16Storage": safetensors.BF16,
+	"FloatStorage":    safetensors.F32,
+	"DoubleStorage":   safetensors.F64,
+}
+
+// callables are the allow-listed globals which can be called by REDUCE,
+// indexed by "module.name".
+var callables map[string]func(args tuple) (any, error)
+
+// types are the allow-listed globals which are only used as values.
+var types = map[string]bool{
+	"torch.Tensor":                 true,
+	"torch.nn.parameter.Parameter": true,
+}
+
+func init() {
+	callables = map[string]func(args tuple) (any, error){
+		"collections.OrderedDict":                    rebuildOrderedDict,
+		"torch._utils._rebuild_tensor":               rebuildTensor,
+		"torch._utils._rebuild_tensor_v2":            rebuildTensor,
+		"torch._utils._rebuild_parameter":            rebuildParameter,
+		"torch._utils._rebuild_parameter_with_state": rebuildParameter,
+		"torch._tensor._rebuild_from_type_v2":        rebuildFromType,
+	}
+}
+
+// storage is a reference to the data of a torch storage, stored in the
+// archive as a separate file.
+type storage struct {
+	dType safetensors.DType
+	key   string
+}
+
+// tensor is a view over a storage.
+type tensor struct {
+	storage *storage
+	// offset, shape and stride are measured in elements.
+	offset uint64
+	shape  []uint64
+	stride []uint64
+}
+
+// call calls an allow-listed global with the given arguments.
+func call(fn, args any) (any, error) {
+	g, ok := fn.(global)
+	if !ok {
+		return nil, fmt.Errorf("cannot call %T", fn)
+	}
+	callable, ok := callables[g.module+"."+g.name]
+	if !ok {
+		return nil, fmt.Errorf("global %s.%s is not allowed", g.module, g.name)
+	}
+	t, ok := args.(tuple)
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments for %s.%s: %T", g.module, g.name, args)
+	}
+	return callable(t)
+}
+
+// build applies the state of BUILD to obj.
+//
+// The state is ignored: for tensors, it holds attributes such as those of
+// parameters, and for dicts, it holds the attributes of the object, such
+// as the _metadata of the OrderedDict of a state dict, which are not items.
+func build(obj, state any) error {
+	switch obj.(type) {
+	case *tensor, *dict:
+		return nil
+	}
+	return fmt.Errorf("cannot build %T with state %T", obj, state)
+}
+
+// persistentLoad resolves the persistent IDs of torch storages, that is
+// tuples ("storage", storage_type, key, location, numel).
+func persistentLoad(pid any) (any, error) {
+	t, ok := pid.(tuple)
+	if !ok || len(t) != 5 || t[0] != "storage" {
+		return nil, fmt.Errorf("unsupported persistent ID %v", pid)
+	}
+	g, ok := t[1].(global)
+	dType, known := storageTypes[g.name]
+	if !ok || g.module != "torch" || !known {
+		return nil, fmt.Errorf("unsupported storage type %v", t[1])
+	}
+	key, ok := t[2].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid storage key %v", t[2])
+	}
+	return &storage{dType: dType, key: key}, nil
+}
+
+func rebuildOrderedDict(args tuple) (any, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("unsupported OrderedDict arguments")
+	}
+	return newDict(), nil
+}
+
+// rebuildTensor implements both _rebuild_tensor and _rebuild_tensor_v2,
+// whose first arguments are storage, storage_offset, size and stride.
+func rebuildTensor(args tuple) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("invalid tensor arguments: expected at least 4, actual %d", len(args))
+	}
+	s, ok := args[0].(*storage)
+	if !ok {
+		return nil, fmt.Errorf("invalid tensor storage %T", args[0])
+	}
+	offset, ok := args[1].(int64)
+	if !ok || offset < 0 {
+		return nil, fmt.Errorf("invalid tensor storage offset %v", args[1])
+	}
+	shape, err := toUints(args[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid tensor size: %w", err)
+	}
+	stride, err := toUints(args[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid tensor stride: %w", err)
+	}
+	if len(shape) != len(stride) {
+		return nil, fmt.Errorf("tensor size and stride have different lengths")
+	}
+	return &tensor{storage: s, offset: uint64(offset), shape: shape, stride: stride}, nil
+}
+
+// rebuildParameter returns the data of the parameter, which is its
+// first argument.
+func rebuildParameter(args tuple) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing parameter data")
+	}
+	if _, ok := args[0].(*tensor); !ok {
+		return nil, fmt.Errorf("invalid parameter data %T", args[0])
+	}
+	return args[0], nil
+}
+
+// rebuildFromType implements _rebuild_from_type_v2(func, new_type, args,
+// state), only for plain tensors and parameters.
+func rebuildFromType(args tuple) (any, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("invalid _rebuild_from_type_v2 arguments")
+	}
+	g, ok := args[1].(global)
+	if !ok || !types[g.module+"."+g.name] {
+		return nil, fmt.Errorf("unsupported tensor type %v", args[1])
+	}
+	return call(args[0], args[2])
+}
+
+// toUints converts a tuple of non-negative integers.
+func toUints(v any) ([]uint64, error) {
+	t, ok := v.(tuple)
+	if !ok {
+		return nil, fmt.Errorf("expected tuple, actual %T", v)
+	}
+	result := make([]uint64, len(t))
+	for i, item := range t {
+		n, ok := item.(int64)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("expected non-negative integer, actual %v", item)
+		}
+		result[i] = uint64(n)
+	}
+	return result, nil
+}
+
+// materialize copies the data of the tensor, in C order, from its storage.
+func (a *archive) materialize(t *tensor) (safetensors.TensorView, error) {
+	data, err := a.storageData(t.storage)
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	size := t.storage.dType.Size()
+	numElements, end, err := extent(t)
+	if err != nil {
+		return safetensors.TensorView{}, err
+	}
+	if end > uint64(len(data))/size {
+		return safetensors.TensorView{}, fmt.Errorf("storage %q too small: %d bytes, required %d elements of %d bytes",
+			t.storage.key, len(data), end, size)
+	}
+	// Zero or overlapping strides would allow a tiny storage to declare
+	// arbitrarily many elements: the output must not be larger than the
+	// storage it is copied from.
+	if hi, lo := bits.Mul64(numElements, size); hi != 0 || lo > uint64(len(data)) {
+		return safetensors.TensorView{}, fmt.Errorf("tensor too large: %d elements of %d bytes, storage %q has %d bytes",
+			numElements, size, t.storage.key, len(data))
+	}
+
+	out := make([]byte, 0, numElements*size)
+	if numElements > 0 {
+		out = tensorio.Gather(out, data, t.shape, t.stride, t.offset, size)
+	}
+	return safetensors.NewTensorView(t.storage.dType, t.shape, out)
+}
+
+// extent returns the number of elements of the tensor, and the index of
+// the storage element past the last one it refers to, which is 0 for
+// empty tensors. Overflows are reported as errors.
+func extent(t *tensor) (numElements, end uint64, err error) {
+	numElements, end = 1, t.offset+1
+	for i, dim := range t.shape {
+		if dim == 0 {
+			return 0, 0, nil
+		}
+		hi, lo := bits.Mul64(numElements, dim)
+		hi2, span := bits.Mul64(dim-1, t.stride[i])
+		var carry uint64
+		end, carry = bits.Add64(end, span, 0)
+		if hi != 0 || hi2 != 0 || carry != 0 {
+			return 0, 0, fmt.Errorf("tensor size overflow")
+		}
+		numElements = lo
+	}
+	return numElements, end, nil
+}
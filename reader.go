@@ -0,0 +1,253 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Reader provides lazy access to the tensors of a safetensors file
+// available through an io.ReaderAt.
+//
+// Only the header is read when the Reader is created: the data of each
+// tensor is read on demand.
+type Reader struct {
+	r          io.ReaderAt
+	metadata   Metadata
+	dataOffset int64
+	// checksums are verified when reading tensors, if not nil.
+	checksums map[string]string
+}
+
+// NewReader returns a new Reader reading a safetensors file from r,
+// which is assumed to have the given size in bytes.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	return ParseOptions{}.NewReader(r, size)
+}
+
+// NewReader is like the package-level NewReader function, but it parses
+// the header according to the options.
+func (o ParseOptions) NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < 8 {
+		return nil, ErrHeaderTooSmall
+	}
+
+	var nbArr [8]byte
+	if err := readFullAt(r, nbArr[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read header size: %w", err)
+	}
+	n, err := o.readHeaderSize(nbArr[:])
+	if err != nil {
+		return nil, err
+	}
+
+	stop := n + 8
+	if stop > uint64(size) {
+		return nil, ErrInvalidHeaderLength
+	}
+
+	header := make([]byte, n)
+	if err = readFullAt(r, header, 8); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	metadata, bufferEnd, err := o.parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if bufferEnd+stop != uint64(size) {
+		return nil, ErrIncompleteBuffer
+	}
+
+	var checksums map[string]string
+	if o.VerifyChecksums {
+		if checksums, err = readChecksums(metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Reader{
+		r:          r,
+		metadata:   metadata,
+		dataOffset: int64(stop),
+		checksums:  checksums,
+	}, nil
+}
+
+// Metadata returns the parsed header.
+func (r *Reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// The Names of all tensors.
+func (r *Reader) Names() []string {
+	return r.metadata.names()
+}
+
+// Len returns how many tensors are stored within the file.
+func (r *Reader) Len() int {
+	return len(r.metadata.tensors)
+}
+
+// Tensor reads the data of a specific tensor by name, and returns a
+// view over it.
+//
+// An error is returned if the tensor does not exist, or if its data
+// cannot be read. If the Reader was created with
+// ParseOptions.VerifyChecksums, the checksum of the data is verified too.
+func (r *Reader) Tensor(name string) (TensorView, error) {
+	index, ok := r.metadata.indexMap[name]
+	if !ok {
+		return TensorView{}, &TensorNotFoundError{Name: name}
+	}
+	info := &r.metadata.tensors[index]
+
+	data := make([]byte, info.DataOffsets[1]-info.DataOffsets[0])
+	err := readFullAt(r.r, data, r.dataOffset+int64(info.DataOffsets[0]))
+	if err != nil {
+		return TensorView{}, fmt.Errorf("failed to read data of tensor %q: %w", name, err)
+	}
+	return r.newTensorView(name, info, data)
+}
+
+// ReadTensors is like Tensor, reading the named tensors at once and
+// returning them in the same order.
+//
+// The data of tensors which are stored contiguously in the file is read
+// with a single read, which makes a difference when reads are expensive,
+// such as over a network.
+func (r *Reader) ReadTensors(names ...string) ([]NamedTensorView, error) {
+	infos := make([]*TensorInfo, len(names))
+	order := make([]int, len(names))
+	for i, name := range names {
+		index, ok := r.metadata.indexMap[name]
+		if !ok {
+			return nil, &TensorNotFoundError{Name: name}
+		}
+		infos[i] = &r.metadata.tensors[index]
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return infos[order[a]].DataOffsets[0] < infos[order[b]].DataOffsets[0]
+	})
+
+	result := make([]NamedTensorView, len(names))
+	for len(order) > 0 {
+		n := contiguousPrefix(order, infos)
+		if err := r.readSpan(names, infos, order[:n], result); err != nil {
+			return nil, err
+		}
+		order = order[n:]
+	}
+	return result, nil
+}
+
+// contiguousPrefix returns how many of the tensors, sorted by offset, are
+// stored contiguously from the first one.
+func contiguousPrefix(order []int, infos []*TensorInfo) int {
+	end := infos[order[0]].DataOffsets[1]
+	n := 1
+	for ; n < len(order); n++ {
+		offsets := infos[order[n]].DataOffsets
+		if offsets[0] > end {
+			break
+		}
+		if offsets[1] > end {
+			end = offsets[1]
+		}
+	}
+	return n
+}
+
+// readSpan reads the data of contiguous tensors with a single read,
+// storing the views in result.
+func (r *Reader) readSpan(names []string, infos []*TensorInfo, span []int, result []NamedTensorView) error {
+	begin := infos[span[0]].DataOffsets[0]
+	end := begin
+	for _, i := range span {
+		if infos[i].DataOffsets[1] > end {
+			end = infos[i].DataOffsets[1]
+		}
+	}
+	data := make([]byte, end-begin)
+	if err := readFullAt(r.r, data, r.dataOffset+int64(begin)); err != nil {
+		return fmt.Errorf("failed to read data of tensor %q: %w", names[span[0]], err)
+	}
+	for _, i := range span {
+		offsets := infos[i].DataOffsets
+		tv, err := r.newTensorView(names[i], infos[i], data[offsets[0]-begin:offsets[1]-begin:offsets[1]-begin])
+		if err != nil {
+			return err
+		}
+		result[i] = NamedTensorView{Name: names[i], TensorView: tv}
+	}
+	return nil
+}
+
+// newTensorView returns a view over the data read for a tensor, verifying
+// its checksum if needed.
+func (r *Reader) newTensorView(name string, info *TensorInfo, data []byte) (TensorView, error) {
+	if r.checksums != nil {
+		if err := verifyTensor(r.checksums, name, data); err != nil {
+			return TensorView{}, err
+		}
+	}
+	return TensorView{
+		dType: info.DType,
+		shape: info.Shape,
+		data:  data,
+	}, nil
+}
+
+// Slice reads a sub-region of a specific tensor by name, and returns a
+// view over it. See TensorView.Slice for the meaning of ranges.
+//
+// Only the bytes of the selected region are read, coalescing the
+// contiguous ones in as few reads as possible. For this reason, checksums
+// are never verified.
+func (r *Reader) Slice(name string, ranges ...Range) (TensorView, error) {
+	index, ok := r.metadata.indexMap[name]
+	if !ok {
+		return TensorView{}, &TensorNotFoundError{Name: name}
+	}
+	info := &r.metadata.tensors[index]
+
+	plan, err := planSlice(info.DType, info.Shape, ranges)
+	if err != nil {
+		return TensorView{}, fmt.Errorf("failed to slice tensor %q: %w", name, err)
+	}
+
+	data := make([]byte, plan.size)
+	base := r.dataOffset + int64(info.DataOffsets[0])
+	pos := uint64(0)
+	for _, run := range plan.runs {
+		n := run.end - run.begin
+		err = readFullAt(r.r, data[pos:pos+n], base+int64(run.begin))
+		if err != nil {
+			return TensorView{}, fmt.Errorf("failed to read data of tensor %q: %w", name, err)
+		}
+		pos += n
+	}
+
+	return TensorView{
+		dType: info.DType,
+		shape: plan.shape,
+		data:  data,
+	}, nil
+}
+
+// readFullAt reads exactly len(p) bytes from r at offset off.
+func readFullAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
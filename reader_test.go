@@ -0,0 +1,163 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReader(t *testing.T) {
+	t.Run("valid file", func(t *testing.T) {
+		serialized := []byte("\x88\x00\x00\x00\x00\x00\x00\x00" +
+			`{"a":{"dtype":"I32","shape":[2],"data_offsets":[0,8]},"b":{"dtype":"U8","shape":[3],"data_offsets":[8,11]},"__metadata__":{"foo":"bar"}}` +
+			"\x01\x00\x00\x00\x02\x00\x00\x00\x03\x04\x05")
+
+		r, err := NewReader(bytes.NewReader(serialized), int64(len(serialized)))
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, r.Len())
+		assert.Equal(t, []string{"a", "b"}, r.Names())
+		assert.Equal(t, map[string]string{"foo": "bar"}, r.Metadata().Metadata())
+
+		a, err := r.Tensor("a")
+		require.NoError(t, err)
+		assert.Equal(t, I32, a.DType())
+		assert.Equal(t, []uint64{2}, a.Shape())
+		assert.Equal(t, []byte{1, 0, 0, 0, 2, 0, 0, 0}, a.Data())
+
+		b, err := r.Tensor("b")
+		require.NoError(t, err)
+		assert.Equal(t, U8, b.DType())
+		assert.Equal(t, []uint64{3}, b.Shape())
+		assert.Equal(t, []byte{3, 4, 5}, b.Data())
+
+		_, err = r.Tensor("c")
+		assert.EqualError(t, err, `tensor "c" not found`)
+	})
+
+	t.Run("only the header is read", func(t *testing.T) {
+		serialized := []byte("<\x00\x00\x00\x00\x00\x00\x00" +
+			`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0,16]}}`)
+		ra := &limitedReaderAt{r: bytes.NewReader(serialized), limit: int64(len(serialized))}
+
+		r, err := NewReader(ra, int64(len(serialized))+16)
+		require.NoError(t, err)
+
+		_, err = r.Tensor("test")
+		assert.EqualError(t, err, `failed to read data of tensor "test": unexpected EOF`)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		ra := &limitedReaderAt{r: bytes.NewReader(nil), err: errors.New("boom")}
+		_, err := NewReader(ra, 100)
+		assert.EqualError(t, err, "failed to read header size: boom")
+	})
+
+	t.Run("header too small", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			data := make([]byte, i)
+			_, err := NewReader(bytes.NewReader(data), int64(i))
+			assert.EqualErrorf(t, err, "header too small", "data len = %d", i)
+		}
+	})
+
+	t.Run("invalid header length", func(t *testing.T) {
+		serialized := []byte("<\x00\x00\x00\x00\x00\x00\x00")
+		_, err := NewReader(bytes.NewReader(serialized), int64(len(serialized)))
+		assert.EqualError(t, err, "invalid header length")
+	})
+
+	t.Run("metadata incomplete buffer", func(t *testing.T) {
+		serialized := []byte("<\x00\x00\x00\x00\x00\x00\x00" +
+			`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0,16]}}` +
+			"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+		_, err := NewReader(bytes.NewReader(serialized), int64(len(serialized))+1)
+		assert.EqualError(t, err, "metadata incomplete buffer")
+		_, err = NewReader(bytes.NewReader(serialized), int64(len(serialized))-1)
+		assert.EqualError(t, err, "metadata incomplete buffer")
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		serialized := []byte("<\x00\x00\x00\x00\x00\x00\x00" +
+			`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0, 4]}}`)
+		_, err := NewReader(bytes.NewReader(serialized), int64(len(serialized)))
+		assert.EqualError(t, err, `metadata validation error: info data offsets mismatch: tensor "test" has 4 bytes of data, expected 16`)
+	})
+}
+
+// limitedReaderAt is an io.ReaderAt which fails reading beyond limit,
+// or always fails with err, if set.
+type limitedReaderAt struct {
+	r     io.ReaderAt
+	limit int64
+	err   error
+}
+
+func (l *limitedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if off+int64(len(p)) > l.limit {
+		return 0, io.EOF
+	}
+	return l.r.ReadAt(p, off)
+}
+
+func TestReaderReadTensors(t *testing.T) {
+	tensors := map[string]TensorView{
+		"a": newArangeU8(t, 2),
+		"b": newArangeU8(t, 3),
+		"c": newArangeU8(t, 4),
+	}
+	serialized, err := Serialize(tensors, nil, WithChecksums())
+	require.NoError(t, err)
+
+	ra := &recordingReaderAt{r: bytes.NewReader(serialized)}
+	r, err := ParseOptions{VerifyChecksums: true}.NewReader(ra, int64(len(serialized)))
+	require.NoError(t, err)
+	dataOffset := r.dataOffset
+
+	t.Run("contiguous", func(t *testing.T) {
+		ra.reads = nil
+		views, err := r.ReadTensors("c", "a", "b")
+		require.NoError(t, err)
+		assert.Equal(t, []NamedTensorView{
+			{Name: "c", TensorView: tensors["c"]},
+			{Name: "a", TensorView: tensors["a"]},
+			{Name: "b", TensorView: tensors["b"]},
+		}, views)
+		assert.Equal(t, [][2]int64{{dataOffset, 9}}, ra.reads)
+	})
+
+	t.Run("with gaps", func(t *testing.T) {
+		ra.reads = nil
+		views, err := r.ReadTensors("c", "a", "c")
+		require.NoError(t, err)
+		require.Len(t, views, 3)
+		assert.Equal(t, tensors["a"], views[1].TensorView)
+		assert.Equal(t, tensors["c"], views[2].TensorView)
+		assert.Equal(t, [][2]int64{{dataOffset, 2}, {dataOffset + 5, 4}}, ra.reads)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := r.ReadTensors("a", "x")
+		assert.EqualError(t, err, `tensor "x" not found`)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		corrupt := bytes.Clone(serialized)
+		corrupt[len(corrupt)-1]++
+		r, err := ParseOptions{VerifyChecksums: true}.NewReader(bytes.NewReader(corrupt), int64(len(corrupt)))
+		require.NoError(t, err)
+		_, err = r.ReadTensors("a", "c")
+		assert.EqualError(t, err, `checksum mismatch: corrupt tensors ["c"]`)
+	})
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlpodyssey/safetensors"
+)
+
+// Paths served by a Handler.
+const (
+	headerPath  = "/header"
+	tensorsPath = "/tensors/"
+)
+
+// Headers describing the tensor served by a Handler.
+const (
+	DTypeHeader = "X-Safetensors-Dtype"
+	ShapeHeader = "X-Safetensors-Shape"
+)
+
+// Handler serves the header and the tensors of a SafeTensors over HTTP.
+//
+// The following endpoints are served, for GET and HEAD requests:
+//
+//	/header          the header, as JSON
+//	/tensors/<name>  the raw data of a tensor
+//
+// The data of a tensor can be sliced with the "slice" query parameter,
+// listing comma-separated Python-like ranges such as "0:2,:,1:" which
+// select the indices of the corresponding dimensions, as described by
+// safetensors.TensorView.Slice. Range requests are supported, and the
+// DType and the shape of the served tensor are reported by the
+// X-Safetensors-Dtype and X-Safetensors-Shape headers.
+//
+// Responses have an ETag, so that conditional requests are supported.
+// The ETag of a tensor is its checksum, if the SafeTensors has checksums
+// (see safetensors.WithChecksums), or else derived from its name and the
+// identity of the content given to NewHandler.
+type Handler struct {
+	st       safetensors.SafeTensors
+	identity string
+	header   []byte
+	// checksums are the checksums of the tensors, if any.
+	checksums map[string]string
+}
+
+// NewHandler returns a Handler serving st.
+//
+// The identity, if not empty, is a string which changes whenever the
+// content of st changes, such as the one returned by FileIdentity, and is
+// used to derive the ETags of tensors without checksum. If both are
+// missing, tensors are served without ETag.
+func NewHandler(st safetensors.SafeTensors, identity string) (*Handler, error) {
+	header, err := json.Marshal(st.Metadata())
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{st: st, identity: identity, header: header}
+	if s, ok := st.Metadata().Metadata()[safetensors.ChecksumsMetadataKey]; ok {
+		if err = json.Unmarshal([]byte(s), &h.checksums); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %w", safetensors.ChecksumsMetadataKey, err)
+		}
+	}
+	return h, nil
+}
+
+// FileIdentity returns a string identifying the content of a file, based
+// on its size and modification time.
+func FileIdentity(fi os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", fi.Size(), fi.ModTime().UnixNano())
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case r.URL.Path == headerPath:
+		h.serveHeader(w, r)
+	case strings.HasPrefix(r.URL.Path, tensorsPath):
+		h.serveTensor(w, r, strings.TrimPrefix(r.URL.Path, tensorsPath))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveHeader(w http.ResponseWriter, r *http.Request) {
+	sum := sha256.Sum256(h.header)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(hex.EncodeToString(sum[:16])))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(h.header))
+}
+
+func (h *Handler) serveTensor(w http.ResponseWriter, r *http.Request, name string) {
+	tv, ok := h.st.Tensor(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("tensor %q not found", name), http.StatusNotFound)
+		return
+	}
+	etag := h.tensorETag(name)
+
+	if spec := r.URL.Query().Get("slice"); spec != "" {
+		ranges, err := parseSlice(spec, tv.Shape())
+		if err == nil {
+			tv, err = tv.Slice(ranges...)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid slice %q: %v", spec, err), http.StatusBadRequest)
+			return
+		}
+		if etag != "" {
+			etag += ":" + formatRanges(ranges)
+		}
+	}
+
+	shape, _ := json.Marshal(tv.Shape())
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(DTypeHeader, tv.DType().String())
+	w.Header().Set(ShapeHeader, string(shape))
+	if etag != "" {
+		w.Header().Set("ETag", strconv.Quote(etag))
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tv.Data()))
+}
+
+// tensorETag returns the unquoted ETag of a tensor, or the empty string
+// if it cannot be derived.
+func (h *Handler) tensorETag(name string) string {
+	if sum, ok := h.checksums[name]; ok {
+		return "sha256-" + strings.ToLower(sum)
+	}
+	if h.identity == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(h.identity + "\x00" + name))
+	return hex.EncodeToString(sum[:16])
+}
+
+// parseSlice parses the ranges of a slice, such as "0:2,:,1:", for a
+// tensor of the given shape. Omitted starts and stops default to the
+// bounds of the dimension.
+func parseSlice(spec string, shape []uint64) ([]safetensors.Range, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) > len(shape) {
+		return nil, fmt.Errorf("%d ranges for %d dimensions", len(parts), len(shape))
+	}
+	ranges := make([]safetensors.Range, len(parts))
+	for i, part := range parts {
+		start, stop, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		rng := safetensors.Range{Start: 0, Stop: shape[i]}
+		var err error
+		if start != "" {
+			if rng.Start, err = strconv.ParseUint(start, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		if stop != "" {
+			if rng.Stop, err = strconv.ParseUint(stop, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		ranges[i] = rng
+	}
+	return ranges, nil
+}
+
+// formatRanges formats ranges in the canonical form accepted by
+// parseSlice.
+func formatRanges(ranges []safetensors.Range) string {
+	parts := make([]string, len(ranges))
+	for i, rng := range ranges {
+		parts[i] = fmt.Sprintf("%d:%d", rng.Start, rng.Stop)
+	}
+	return strings.Join(parts, ",")
+}
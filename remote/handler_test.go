@@ -0,0 +1,162 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHandlerServer(t *testing.T, serialized []byte, identity string) *httptest.Server {
+	t.Helper()
+	st, err := safetensors.Deserialize(serialized)
+	require.NoError(t, err)
+	h, err := NewHandler(st, identity)
+	require.NoError(t, err)
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res, body
+}
+
+func TestHandler(t *testing.T) {
+	serialized, tensors := testFile(t)
+	ts := newHandlerServer(t, serialized, "v1")
+
+	t.Run("header", func(t *testing.T) {
+		res, body := get(t, ts.URL+"/header", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"__metadata__": {"foo": "bar"},
+			"a": {"dtype": "U8", "shape": [4, 4], "data_offsets": [0, 16]},
+			"b": {"dtype": "U8", "shape": [2], "data_offsets": [16, 18]},
+			"c": {"dtype": "U8", "shape": [3], "data_offsets": [18, 21]}
+		}`, string(body))
+
+		res, _ = get(t, ts.URL+"/header", http.Header{"If-None-Match": {res.Header.Get("ETag")}})
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("tensor", func(t *testing.T) {
+		res, body := get(t, ts.URL+"/tensors/a", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, tensors["a"].Data(), body)
+		assert.Equal(t, "16", res.Header.Get("Content-Length"))
+		assert.Equal(t, "U8", res.Header.Get(DTypeHeader))
+		assert.Equal(t, "[4,4]", res.Header.Get(ShapeHeader))
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+
+		other, _ := get(t, ts.URL+"/tensors/b", nil)
+		assert.NotEqual(t, res.Header.Get("ETag"), other.Header.Get("ETag"))
+
+		res, _ = get(t, ts.URL+"/tensors/a", http.Header{"If-None-Match": {res.Header.Get("ETag")}})
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		res, body := get(t, ts.URL+"/tensors/a", http.Header{"Range": {"bytes=4-7"}})
+		require.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, []byte{4, 5, 6, 7}, body)
+		assert.Equal(t, "bytes 4-7/16", res.Header.Get("Content-Range"))
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		res, body := get(t, ts.URL+"/tensors/a?slice=1:3,:2", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []byte{4, 5, 8, 9}, body)
+		assert.Equal(t, "[2,2]", res.Header.Get(ShapeHeader))
+
+		full, _ := get(t, ts.URL+"/tensors/a", nil)
+		assert.Equal(t, full.Header.Get("ETag")[:33]+`:1:3,0:2"`, res.Header.Get("ETag"))
+	})
+
+	t.Run("reader at", func(t *testing.T) {
+		ra, err := NewReaderAt(context.Background(), ts.URL+"/tensors/a?slice=2:4")
+		require.NoError(t, err)
+		assert.Equal(t, int64(8), ra.Size())
+		p := make([]byte, 3)
+		_, err = ra.ReadAt(p, 5)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{13, 14, 15}, p)
+	})
+
+	errorCases := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"unknown path", "/foo", http.StatusNotFound},
+		{"unknown tensor", "/tensors/x", http.StatusNotFound},
+		{"too many ranges", "/tensors/b?slice=0:1,0:1", http.StatusBadRequest},
+		{"invalid range", "/tensors/b?slice=1", http.StatusBadRequest},
+		{"invalid bound", "/tensors/b?slice=a:", http.StatusBadRequest},
+		{"out of bounds", "/tensors/b?slice=0:3", http.StatusBadRequest},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, _ := get(t, ts.URL+tc.path, nil)
+			assert.Equal(t, tc.status, res.StatusCode)
+		})
+	}
+
+	t.Run("method not allowed", func(t *testing.T) {
+		res, err := http.Post(ts.URL+"/header", "text/plain", nil)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+		assert.Equal(t, "GET, HEAD", res.Header.Get("Allow"))
+	})
+}
+
+func TestHandlerETags(t *testing.T) {
+	tensors := map[string]safetensors.TensorView{"a": newArange(t, safetensors.U8, 2)}
+
+	t.Run("checksums", func(t *testing.T) {
+		serialized, err := safetensors.Serialize(tensors, nil, safetensors.WithChecksums())
+		require.NoError(t, err)
+		ts := newHandlerServer(t, serialized, "")
+		res, _ := get(t, ts.URL+"/tensors/a", nil)
+		assert.Equal(t, `"sha256-b413f47d13ee2fe6c845b2ee141af81de858df4ec549a58b7970bb96645bc8d2"`, res.Header.Get("ETag"))
+	})
+
+	t.Run("no identity", func(t *testing.T) {
+		serialized, err := safetensors.Serialize(tensors, nil)
+		require.NoError(t, err)
+		ts := newHandlerServer(t, serialized, "")
+		res, _ := get(t, ts.URL+"/tensors/a", nil)
+		assert.Empty(t, res.Header.Get("ETag"))
+	})
+
+	t.Run("invalid checksums", func(t *testing.T) {
+		serialized, err := safetensors.Serialize(tensors, map[string]string{safetensors.ChecksumsMetadataKey: "["})
+		require.NoError(t, err)
+		st, err := safetensors.Deserialize(serialized)
+		require.NoError(t, err)
+		_, err = NewHandler(st, "")
+		assert.EqualError(t, err, `invalid "checksums.sha256" metadata: unexpected end of JSON input`)
+	})
+}
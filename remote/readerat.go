@@ -0,0 +1,238 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrModified is returned when the remote file changes while being read,
+// as detected by its ETag or size.
+var ErrModified = errors.New("remote file was modified")
+
+// ErrRangeNotSupported is returned when the server does not support
+// range requests.
+var ErrRangeNotSupported = errors.New("server does not support range requests")
+
+// retryableStatus are the HTTP status codes of transient errors.
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// ReaderAt is an io.ReaderAt over a file served over HTTP, reading it
+// with range requests.
+//
+// Each call to ReadAt performs a request, except for reads of the first
+// bytes of the file, which are fetched when the ReaderAt is created.
+// Transient errors are retried according to the Options.
+type ReaderAt struct {
+	ctx  context.Context
+	url  string
+	o    Options
+	size int64
+	// etag is the strong ETag of the file, if any, which subsequent
+	// requests must match.
+	etag string
+	// head are the first bytes of the file.
+	head []byte
+}
+
+// response is the result of a range request.
+type response struct {
+	data []byte
+	// size is the size of the whole file.
+	size int64
+	etag string
+}
+
+// NewReaderAt returns a ReaderAt reading the file at the given URL with
+// the default Options.
+func NewReaderAt(ctx context.Context, url string) (*ReaderAt, error) {
+	return Options{}.NewReaderAt(ctx, url)
+}
+
+// NewReaderAt returns a ReaderAt reading the file at the given URL.
+//
+// The first 8 bytes of the file are requested immediately, to learn its
+// size and check that the server supports range requests. The context is
+// used for all the requests of the ReaderAt.
+func (o Options) NewReaderAt(ctx context.Context, url string) (*ReaderAt, error) {
+	ra := &ReaderAt{ctx: ctx, url: url, o: o}
+	resp, err := ra.fetch(0, headSize)
+	if err != nil {
+		return nil, err
+	}
+	ra.size = resp.size
+	ra.head = resp.data
+	if !strings.HasPrefix(resp.etag, "W/") {
+		ra.etag = resp.etag
+	}
+	return ra, nil
+}
+
+// Size returns the size of the remote file in bytes.
+func (ra *ReaderAt) Size() int64 {
+	return ra.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= ra.size {
+		return 0, io.EOF
+	}
+	n := int64(len(p))
+	if n > ra.size-off {
+		n = ra.size - off
+	}
+
+	if off+n <= int64(len(ra.head)) {
+		copy(p, ra.head[off:off+n])
+	} else if n > 0 {
+		resp, err := ra.fetch(off, n)
+		if err != nil {
+			return 0, err
+		}
+		if err = ra.check(resp, n); err != nil {
+			return 0, err
+		}
+		copy(p, resp.data)
+	}
+
+	if n < int64(len(p)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+// check verifies that resp, the response to a request of n bytes, comes
+// from the file read by NewReaderAt: servers which ignore If-Match are
+// still expected to report the ETag and size of the file they serve.
+func (ra *ReaderAt) check(resp response, n int64) error {
+	if resp.size != ra.size || (ra.etag != "" && resp.etag != ra.etag) {
+		return ErrModified
+	}
+	if int64(len(resp.data)) != n {
+		return fmt.Errorf("expected %d bytes, received %d", n, len(resp.data))
+	}
+	return nil
+}
+
+// fetch requests n bytes at the given offset, retrying on transient
+// errors. Fewer bytes are returned if the file is shorter.
+func (ra *ReaderAt) fetch(off, n int64) (response, error) {
+	delay := ra.o.retryDelay()
+	for attempt := 0; ; attempt++ {
+		resp, retry, err := ra.request(off, n)
+		if err == nil || !retry || attempt >= ra.o.maxRetries() {
+			return resp, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ra.ctx.Done():
+			return response{}, ra.ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// request performs a single range request, reporting whether a failure
+// is transient.
+func (ra *ReaderAt) request(off, n int64) (_ response, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ra.ctx, http.MethodGet, ra.url, nil)
+	if err != nil {
+		return response{}, false, err
+	}
+	for key, values := range ra.o.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	if ra.etag != "" {
+		req.Header.Set("If-Match", ra.etag)
+	}
+
+	res, err := ra.o.client().Do(req)
+	if err != nil {
+		return response{}, ra.ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		return readPartialContent(res, off, n)
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && off == 0:
+		// The file is empty, or shorter than the requested range.
+		size, err := parseUnsatisfiedRange(res.Header.Get("Content-Range"))
+		return response{size: size, etag: res.Header.Get("ETag")}, false, err
+	case res.StatusCode == http.StatusOK:
+		return response{}, false, ErrRangeNotSupported
+	case res.StatusCode == http.StatusPreconditionFailed:
+		return response{}, false, ErrModified
+	default:
+		return response{}, retryableStatus[res.StatusCode], fmt.Errorf("unexpected status %q", res.Status)
+	}
+}
+
+// readPartialContent reads the body of a 206 response to a request of n
+// bytes at the given offset.
+func readPartialContent(res *http.Response, off, n int64) (response, bool, error) {
+	start, end, size, err := parseContentRange(res.Header.Get("Content-Range"))
+	if err != nil {
+		return response{}, false, err
+	}
+	if start != off || end < start || end-start+1 > n {
+		return response{}, false, fmt.Errorf("unexpected range %d-%d, requested %d-%d", start, end, off, off+n-1)
+	}
+	data := make([]byte, end-start+1)
+	if _, err = io.ReadFull(res.Body, data); err != nil {
+		// Interrupted transfers are transient errors.
+		return response{}, true, fmt.Errorf("failed to read response: %w", err)
+	}
+	return response{data: data, size: size, etag: res.Header.Get("ETag")}, false, nil
+}
+
+// parseContentRange parses a Content-Range header such as
+// "bytes 0-7/1024".
+func parseContentRange(s string) (start, end, size int64, err error) {
+	rng, total, ok := strings.Cut(strings.TrimPrefix(s, "bytes "), "/")
+	first, last, ok2 := strings.Cut(rng, "-")
+	if !strings.HasPrefix(s, "bytes ") || !ok || !ok2 {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	values := make([]int64, 3)
+	for i, v := range []string{first, last, total} {
+		if values[i], err = strconv.ParseInt(v, 10, 64); err != nil || values[i] < 0 {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+		}
+	}
+	if values[1] >= values[2] {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// parseUnsatisfiedRange parses the Content-Range header of a 416
+// response, such as "bytes */0".
+func parseUnsatisfiedRange(s string) (int64, error) {
+	size, err := strconv.ParseInt(strings.TrimPrefix(s, "bytes */"), 10, 64)
+	if !strings.HasPrefix(s, "bytes */") || err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	return size, nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlpodyssey/safetensors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// server serves a file, recording the Range header of each request.
+type server struct {
+	*httptest.Server
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	ranges  []string
+	// ignoreIfMatch makes the server serve the file regardless of the
+	// If-Match header.
+	ignoreIfMatch bool
+	// failures are the status codes of the responses to the next
+	// requests, before the file is served.
+	failures []int
+}
+
+func newServer(t *testing.T, content []byte) *server {
+	t.Helper()
+	s := &server{content: content, etag: `"v1"`}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		var status int
+		if len(s.failures) > 0 {
+			status, s.failures = s.failures[0], s.failures[1:]
+		}
+		content, etag := s.content, s.etag
+		if s.ignoreIfMatch {
+			r.Header.Del("If-Match")
+		}
+		s.mu.Unlock()
+
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// fail sets the status codes of the responses to the next requests.
+func (s *server) fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = statuses
+}
+
+func (s *server) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranges := s.ranges
+	s.ranges = nil
+	return ranges
+}
+
+func newArange(t *testing.T, dType safetensors.DType, shape ...uint64) safetensors.TensorView {
+	t.Helper()
+	n := dType.Size()
+	for _, dim := range shape {
+		n *= dim
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	tv, err := safetensors.NewTensorView(dType, shape, data)
+	require.NoError(t, err)
+	return tv
+}
+
+func testFile(t *testing.T) ([]byte, map[string]safetensors.TensorView) {
+	t.Helper()
+	tensors := map[string]safetensors.TensorView{
+		"a": newArange(t, safetensors.U8, 4, 4),
+		"b": newArange(t, safetensors.U8, 2),
+		"c": newArange(t, safetensors.U8, 3),
+	}
+	serialized, err := safetensors.Serialize(tensors, map[string]string{"foo": "bar"})
+	require.NoError(t, err)
+	return serialized, tensors
+}
+
+func TestOpen(t *testing.T) {
+	serialized, tensors := testFile(t)
+	s := newServer(t, serialized)
+	headerEnd := len(serialized) - 21
+
+	r, err := Open(context.Background(), s.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bytes=0-7", "bytes=8-" + strconv.Itoa(headerEnd-1)}, s.requests())
+	assert.Equal(t, []string{"a", "b", "c"}, r.Names())
+	assert.Equal(t, map[string]string{"foo": "bar"}, r.Metadata().Metadata())
+
+	t.Run("tensor", func(t *testing.T) {
+		tv, err := r.Tensor("b")
+		require.NoError(t, err)
+		assert.Equal(t, tensors["b"], tv)
+		assert.Equal(t, []string{rangeHeader(headerEnd+16, 2)}, s.requests())
+	})
+
+	t.Run("coalesced tensors", func(t *testing.T) {
+		views, err := r.ReadTensors("c", "b")
+		require.NoError(t, err)
+		assert.Equal(t, tensors["c"], views[0].TensorView)
+		assert.Equal(t, tensors["b"], views[1].TensorView)
+		assert.Equal(t, []string{rangeHeader(headerEnd+16, 5)}, s.requests())
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		tv, err := r.Slice("a", safetensors.Range{Start: 1, Stop: 3}, safetensors.Range{Start: 1, Stop: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 1}, tv.Shape())
+		assert.Equal(t, []byte{5, 9}, tv.Data())
+		assert.Equal(t, []string{rangeHeader(headerEnd+4, 8)}, s.requests())
+	})
+
+	t.Run("invalid slice", func(t *testing.T) {
+		_, err := r.Slice("a", safetensors.Range{Start: 0, Stop: 5})
+		assert.ErrorIs(t, err, safetensors.ErrInvalidSlice)
+		_, err = r.Slice("x", safetensors.Range{Start: 0, Stop: 1})
+		assert.ErrorIs(t, err, safetensors.ErrTensorNotFound)
+		assert.Empty(t, s.requests())
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		s.mu.Lock()
+		s.etag = `"v2"`
+		s.mu.Unlock()
+		_, err := r.Tensor("a")
+		assert.ErrorIs(t, err, ErrModified)
+	})
+}
+
+func TestModifiedIgnoringIfMatch(t *testing.T) {
+	serialized, tensors := testFile(t)
+
+	t.Run("etag", func(t *testing.T) {
+		s := newServer(t, serialized)
+		s.ignoreIfMatch = true
+		r, err := Open(context.Background(), s.URL)
+		require.NoError(t, err)
+
+		s.mu.Lock()
+		s.etag = `"v2"`
+		s.mu.Unlock()
+		_, err = r.Tensor("a")
+		assert.ErrorIs(t, err, ErrModified)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		s := newServer(t, serialized)
+		s.ignoreIfMatch = true
+		r, err := Open(context.Background(), s.URL)
+		require.NoError(t, err)
+
+		tv, err := r.Tensor("b")
+		require.NoError(t, err)
+		assert.Equal(t, tensors["b"], tv)
+
+		s.mu.Lock()
+		s.content = append(serialized[:len(serialized):len(serialized)], 0)
+		s.mu.Unlock()
+		_, err = r.Tensor("b")
+		assert.ErrorIs(t, err, ErrModified)
+	})
+}
+
+func TestRetries(t *testing.T) {
+	serialized, tensors := testFile(t)
+	s := newServer(t, serialized)
+	o := Options{RetryDelay: time.Millisecond}
+
+	t.Run("transient errors", func(t *testing.T) {
+		s.fail(http.StatusServiceUnavailable, http.StatusBadGateway)
+		r, err := o.Open(context.Background(), s.URL)
+		require.NoError(t, err)
+		tv, err := r.Tensor("c")
+		require.NoError(t, err)
+		assert.Equal(t, tensors["c"], tv)
+		assert.Len(t, s.requests(), 5)
+	})
+
+	t.Run("too many errors", func(t *testing.T) {
+		s.fail(503, 503, 503, 503)
+		_, err := o.Open(context.Background(), s.URL)
+		assert.EqualError(t, err, `unexpected status "503 Service Unavailable"`)
+		assert.Len(t, s.requests(), 4)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s.fail(503)
+		_, err := Options{MaxRetries: -1}.Open(context.Background(), s.URL)
+		assert.Error(t, err)
+		assert.Len(t, s.requests(), 1)
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		s.fail(http.StatusNotFound)
+		_, err := o.Open(context.Background(), s.URL)
+		assert.EqualError(t, err, `unexpected status "404 Not Found"`)
+		assert.Len(t, s.requests(), 1)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		s.fail(503, 503)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Options{RetryDelay: time.Hour}.Open(ctx, s.URL)
+		assert.ErrorIs(t, err, context.Canceled)
+		s.fail()
+	})
+}
+
+func TestOpenErrors(t *testing.T) {
+	t.Run("range not supported", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("foo"))
+		}))
+		defer ts.Close()
+		_, err := Open(context.Background(), ts.URL)
+		assert.ErrorIs(t, err, ErrRangeNotSupported)
+	})
+
+	t.Run("file too small", func(t *testing.T) {
+		s := newServer(t, []byte("foo"))
+		_, err := Open(context.Background(), s.URL)
+		assert.ErrorIs(t, err, safetensors.ErrHeaderTooSmall)
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		s := newServer(t, []byte("\x02\x00\x00\x00\x00\x00\x00\x00[]"))
+		_, err := Open(context.Background(), s.URL)
+		assert.ErrorIs(t, err, safetensors.ErrInvalidHeader)
+	})
+
+	t.Run("header and authorization", func(t *testing.T) {
+		serialized, _ := testFile(t)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(serialized))
+		}))
+		defer ts.Close()
+
+		_, err := Open(context.Background(), ts.URL)
+		assert.EqualError(t, err, `unexpected status "401 Unauthorized"`)
+
+		o := Options{Client: ts.Client(), Header: http.Header{"Authorization": {"Bearer token"}}}
+		r, err := o.Open(context.Background(), ts.URL)
+		require.NoError(t, err)
+		assert.Equal(t, 3, r.Len())
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, end, size, err := parseContentRange("bytes 8-15/100")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{8, 15, 100}, []int64{start, end, size})
+
+	for _, s := range []string{"", "bytes 8-15", "bytes */100", "items 0-1/2", "bytes 0-10/10", "bytes -1-2/3"} {
+		_, _, _, err := parseContentRange(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func rangeHeader(off, n int) string {
+	return "bytes=" + strconv.Itoa(off) + "-" + strconv.Itoa(off+n-1)
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// RenameRule is a rule of a Renamer, replacing the names matching a
+// regular expression.
+type RenameRule struct {
+	// Pattern is a regular expression, with the syntax of the regexp
+	// package, which must match the whole name.
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// Replacement is the new name, where $1 or ${name} are replaced with
+	// the text of the corresponding capture group, as in
+	// regexp.Regexp.Expand.
+	Replacement string `json:"replacement" yaml:"replacement"`
+}
+
+// UnmatchedPolicy determines how a Renamer handles the names which are not
+// matched by any rule.
+type UnmatchedPolicy int
+
+const (
+	// UnmatchedError reports unmatched names as an error.
+	UnmatchedError UnmatchedPolicy = iota
+	// UnmatchedKeep keeps unmatched names unchanged.
+	UnmatchedKeep
+	// UnmatchedDrop leaves out the tensors with unmatched names.
+	UnmatchedDrop
+)
+
+var unmatchedPolicies = map[UnmatchedPolicy]string{
+	UnmatchedError: "error",
+	UnmatchedKeep:  "keep",
+	UnmatchedDrop:  "drop",
+}
+
+// String returns the name of the policy.
+func (p UnmatchedPolicy) String() string {
+	if s, ok := unmatchedPolicies[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("UnmatchedPolicy(%d)", int(p))
+}
+
+// Renamer renames tensors according to an ordered list of rules: each
+// name is renamed by the first rule matching it.
+type Renamer struct {
+	rules []renameRule
+	// Unmatched is the policy for names not matched by any rule.
+	Unmatched UnmatchedPolicy
+	// Checksums, if true, makes Apply add the checksums of the renamed
+	// tensors to the metadata, as with WithChecksums. Otherwise, the
+	// checksums of the input, which are indexed by the old names, are
+	// dropped.
+	Checksums bool
+}
+
+type renameRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// NewRenamer returns a Renamer applying the given rules, which reports
+// unmatched names as an error.
+func NewRenamer(rules []RenameRule) (*Renamer, error) {
+	rn := &Renamer{rules: make([]renameRule, len(rules))}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("invalid rename rule %d: empty pattern", i)
+		}
+		re, err := regexp.Compile(`^(?:` + rule.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename rule %d: %w", i, err)
+		}
+		rn.rules[i] = renameRule{re: re, replacement: rule.Replacement}
+	}
+	return rn, nil
+}
+
+// Rename returns the new name given by the first rule matching the name,
+// reporting whether any rule matches.
+func (rn *Renamer) Rename(name string) (string, bool) {
+	for _, rule := range rn.rules {
+		if m := rule.re.FindStringSubmatchIndex(name); m != nil {
+			return string(rule.re.ExpandString(nil, rule.replacement, name, m)), true
+		}
+	}
+	return name, false
+}
+
+// Names maps each of the names to the new one. Unmatched names are handled
+// according to the policy: if dropped, they are missing from the result.
+//
+// If any name is unmatched, with UnmatchedError, or if any new name is
+// not unique, a *RenameError is returned.
+func (rn *Renamer) Names(names []string) (map[string]string, error) {
+	if _, ok := unmatchedPolicies[rn.Unmatched]; !ok {
+		return nil, fmt.Errorf("invalid unmatched policy %d", int(rn.Unmatched))
+	}
+	renamed := make(map[string]string, len(names))
+	sources := make(map[string][]string, len(names))
+	var e RenameError
+	for _, name := range names {
+		newName, ok := rn.Rename(name)
+		if !ok && rn.Unmatched == UnmatchedDrop {
+			continue
+		}
+		if !ok && rn.Unmatched == UnmatchedError {
+			e.Unmatched = append(e.Unmatched, name)
+			continue
+		}
+		renamed[name] = newName
+		sources[newName] = append(sources[newName], name)
+	}
+	e.Collisions = collisions(sources)
+	if len(e.Unmatched) > 0 || len(e.Collisions) > 0 {
+		sort.Strings(e.Unmatched)
+		return nil, &e
+	}
+	return renamed, nil
+}
+
+// collisions returns the new names with more than one source, sorted.
+func collisions(sources map[string][]string) []RenameCollision {
+	var result []RenameCollision
+	for name, names := range sources {
+		if len(names) > 1 {
+			sort.Strings(names)
+			result = append(result, RenameCollision{Name: name, Sources: names})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Apply writes to w a safetensors file with the tensors of st, under the
+// new names, and its __metadata__. See Names for the handling of unmatched
+// names and collisions.
+//
+// The checksums of st, if any, are dropped or recomputed, according to
+// rn.Checksums. The data of the tensors is written directly from st, as
+// with SerializeToWriter.
+func (rn *Renamer) Apply(w io.Writer, st SafeTensors) error {
+	renamed, err := rn.Names(st.Names())
+	if err != nil {
+		return err
+	}
+	tensors := make(map[string]TensorView, len(renamed))
+	for name, newName := range renamed {
+		tensors[newName], _ = st.Tensor(name)
+	}
+
+	metadata := make(map[string]string, len(st.metadata.metadata))
+	for key, value := range st.metadata.metadata {
+		if key != ChecksumsMetadataKey {
+			metadata[key] = value
+		}
+	}
+	var opts []SerializeOption
+	if rn.Checksums {
+		opts = append(opts, WithChecksums())
+	}
+	return SerializeToWriter(tensors, metadata, w, opts...)
+}
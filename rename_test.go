@@ -0,0 +1,120 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenamer(t *testing.T) {
+	rn, err := NewRenamer([]RenameRule{
+		{Pattern: `model\.layers\.(\d+)\.self_attn\.(?P<proj>[qkv])_proj\.weight`, Replacement: "blocks.$1.attn.w${proj}"},
+		{Pattern: `model\.layers\.(\d+)\..*`, Replacement: "blocks.$1.other"},
+		{Pattern: `lm_head\.weight`, Replacement: "output"},
+	})
+	require.NoError(t, err)
+
+	t.Run("rename", func(t *testing.T) {
+		name, ok := rn.Rename("model.layers.12.self_attn.k_proj.weight")
+		assert.True(t, ok)
+		assert.Equal(t, "blocks.12.attn.wk", name)
+
+		name, ok = rn.Rename("model.layers.3.mlp.weight")
+		assert.True(t, ok)
+		assert.Equal(t, "blocks.3.other", name)
+
+		// Patterns must match the whole name.
+		name, ok = rn.Rename("lm_head.weight.scale")
+		assert.False(t, ok)
+		assert.Equal(t, "lm_head.weight.scale", name)
+	})
+
+	t.Run("names", func(t *testing.T) {
+		renamed, err := rn.Names([]string{"model.layers.0.self_attn.q_proj.weight", "lm_head.weight"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"model.layers.0.self_attn.q_proj.weight": "blocks.0.attn.wq",
+			"lm_head.weight":                         "output",
+		}, renamed)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := rn.Names([]string{"b", "model.layers.0.mlp.up", "a", "model.layers.0.mlp.down", "blocks.0.other"})
+		assert.ErrorIs(t, err, ErrInvalidRename)
+		assert.Equal(t, &RenameError{
+			Unmatched: []string{"a", "b", "blocks.0.other"},
+			Collisions: []RenameCollision{
+				{Name: "blocks.0.other", Sources: []string{"model.layers.0.mlp.down", "model.layers.0.mlp.up"}},
+			},
+		}, err)
+		assert.EqualError(t, err, `invalid rename: unmatched tensors ["a" "b" "blocks.0.other"], `+
+			`tensors ["model.layers.0.mlp.down" "model.layers.0.mlp.up"] renamed to "blocks.0.other"`)
+	})
+
+	t.Run("keep unmatched", func(t *testing.T) {
+		rn := *rn
+		rn.Unmatched = UnmatchedKeep
+		renamed, err := rn.Names([]string{"a", "lm_head.weight"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "a", "lm_head.weight": "output"}, renamed)
+
+		_, err = rn.Names([]string{"model.layers.0.mlp.up", "blocks.0.other"})
+		assert.EqualError(t, err, `invalid rename: tensors ["blocks.0.other" "model.layers.0.mlp.up"] renamed to "blocks.0.other"`)
+	})
+
+	t.Run("drop unmatched", func(t *testing.T) {
+		rn := *rn
+		rn.Unmatched = UnmatchedDrop
+		renamed, err := rn.Names([]string{"a", "lm_head.weight"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"lm_head.weight": "output"}, renamed)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		tensors := map[string]TensorView{
+			"model.layers.0.self_attn.v_proj.weight": newArangeU8(t, 2, 2),
+			"lm_head.weight":                         newArangeU8(t, 3),
+		}
+		serialized, err := Serialize(tensors, map[string]string{"format": "pt"}, WithChecksums())
+		require.NoError(t, err)
+		st, err := Deserialize(serialized)
+		require.NoError(t, err)
+
+		apply := func(t *testing.T, checksums bool) SafeTensors {
+			t.Helper()
+			rn := *rn
+			rn.Checksums = checksums
+			var buf bytes.Buffer
+			require.NoError(t, rn.Apply(&buf, st))
+			renamed, err := Deserialize(buf.Bytes())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"blocks.0.attn.wv", "output"}, renamed.Names())
+			tv, _ := renamed.Tensor("blocks.0.attn.wv")
+			assert.Equal(t, tensors["model.layers.0.self_attn.v_proj.weight"], tv)
+			tv, _ = renamed.Tensor("output")
+			assert.Equal(t, tensors["lm_head.weight"], tv)
+			return renamed
+		}
+
+		renamed := apply(t, false)
+		assert.Equal(t, map[string]string{"format": "pt"}, renamed.Metadata().Metadata())
+
+		renamed = apply(t, true)
+		assert.Equal(t, "pt", renamed.Metadata().Metadata()["format"])
+		assert.NoError(t, Verify(renamed))
+		assert.Contains(t, st.Metadata().Metadata(), ChecksumsMetadataKey, "the input must not be modified")
+	})
+}
+
+func TestNewRenamerErrors(t *testing.T) {
+	_, err := NewRenamer([]RenameRule{{Pattern: "a"}, {Pattern: ""}})
+	assert.EqualError(t, err, "invalid rename rule 1: empty pattern")
+	_, err = NewRenamer([]RenameRule{{Pattern: "("}})
+	assert.EqualError(t, err, "invalid rename rule 0: error parsing regexp: missing closing ): `^(?:()$`")
+}
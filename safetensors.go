@@ -12,8 +12,6 @@ import (
 	"sort"
 )
 
-const maxHeaderSize = 100_000_000
-
 // SafeTensors is a structure owning some metadata to lookup tensors
 // on a shared `data` byte-buffer.
 type SafeTensors struct {
@@ -24,50 +22,94 @@ type SafeTensors struct {
 // Deserialize parses a byte-buffer representing the whole
 // safetensor file and returns the deserialized form (no tensor allocation).
 func Deserialize(buffer []byte) (SafeTensors, error) {
-	n, metadata, err := ReadMetadata(buffer)
+	return ParseOptions{}.Deserialize(buffer)
+}
+
+// Deserialize is like the package-level Deserialize function, but it
+// parses the header according to the options.
+func (o ParseOptions) Deserialize(buffer []byte) (SafeTensors, error) {
+	n, metadata, err := o.ReadMetadata(buffer)
 	if err != nil {
 		return SafeTensors{}, err
 	}
-	return SafeTensors{
+	st := SafeTensors{
 		metadata: metadata,
 		data:     buffer[n+8:],
-	}, nil
+	}
+	if o.VerifyChecksums {
+		if err = Verify(st); err != nil {
+			return SafeTensors{}, err
+		}
+	}
+	return st, nil
 }
 
 // ReadMetadata parses the header and returns the size of the header + parsed
 // data, given a byte-buffer representing the whole safetensor file.
 func ReadMetadata(buffer []byte) (uint64, Metadata, error) {
+	return ParseOptions{}.ReadMetadata(buffer)
+}
+
+// ReadMetadata is like the package-level ReadMetadata function, but it
+// parses the header according to the options.
+func (o ParseOptions) ReadMetadata(buffer []byte) (uint64, Metadata, error) {
 	bufferLen := uint64(len(buffer))
 	if bufferLen < 8 {
-		return 0, Metadata{}, fmt.Errorf("header too small")
+		return 0, Metadata{}, ErrHeaderTooSmall
 	}
 
-	arr := buffer[:8]
-	n := binary.LittleEndian.Uint64(arr)
-	if n > maxHeaderSize {
-		return 0, Metadata{}, fmt.Errorf("header too large: max %d, actual %d", maxHeaderSize, n)
+	n, err := o.readHeaderSize(buffer[:8])
+	if err != nil {
+		return 0, Metadata{}, err
 	}
 
 	stop := n + 8
 	if stop > bufferLen {
-		return 0, Metadata{}, fmt.Errorf("invalid header length")
+		return 0, Metadata{}, ErrInvalidHeaderLength
 	}
 
-	var metadata Metadata
-	err := json.Unmarshal(buffer[8:stop], &metadata)
-	if err != nil {
-		return 0, Metadata{}, fmt.Errorf("invalid header deserialization: %w", err)
-	}
-	bufferEnd, err := metadata.validate()
+	metadata, bufferEnd, err := o.parseHeader(buffer[8:stop])
 	if err != nil {
 		return 0, Metadata{}, err
 	}
 	if bufferEnd+8+n != bufferLen {
-		return 0, Metadata{}, fmt.Errorf("metadata incomplete buffer")
+		return 0, Metadata{}, ErrIncompleteBuffer
 	}
 	return n, metadata, nil
 }
 
+// readHeaderSize decodes the 8-byte little-endian size prefix of the header.
+func (o ParseOptions) readHeaderSize(arr []byte) (uint64, error) {
+	n := binary.LittleEndian.Uint64(arr)
+	if maxSize := o.maxHeaderSize(); n > maxSize {
+		return 0, &LimitError{Err: ErrHeaderTooLarge, Max: maxSize, Actual: n}
+	}
+	return n, nil
+}
+
+// parseHeader decodes and validates the JSON header.
+// In case of success, it also returns the expected size of the data buffer.
+func (o ParseOptions) parseHeader(header []byte) (Metadata, uint64, error) {
+	if o.Strict {
+		if err := checkStrictHeader(header); err != nil {
+			return Metadata{}, 0, err
+		}
+	}
+	if err := o.checkLimits(header); err != nil {
+		return Metadata{}, 0, err
+	}
+	var metadata Metadata
+	err := json.Unmarshal(header, &metadata)
+	if err != nil {
+		return Metadata{}, 0, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	bufferEnd, err := metadata.validate()
+	if err != nil {
+		return Metadata{}, 0, err
+	}
+	return metadata, bufferEnd, nil
+}
+
 // Tensors returns a list of named views of all tensors.
 func (st SafeTensors) Tensors() []NamedTensorView {
 	tensors := make([]NamedTensorView, len(st.metadata.indexMap))
@@ -102,11 +144,12 @@ func (st SafeTensors) Tensor(name string) (TensorView, bool) {
 
 // The Names of all tensors.
 func (st SafeTensors) Names() []string {
-	names := make([]string, len(st.metadata.indexMap))
-	for name, index := range st.metadata.indexMap {
-		names[index] = name
-	}
-	return names
+	return st.metadata.names()
+}
+
+// Metadata returns the parsed header.
+func (st SafeTensors) Metadata() Metadata {
+	return st.metadata
 }
 
 // Len returns how many tensors are currently stored within the SafeTensors.
@@ -120,7 +163,11 @@ func (st SafeTensors) IsEmpty() bool {
 }
 
 // Serialize the dictionary of tensors to a byte buffer.
-func Serialize[V View](data map[string]V, dataInfo map[string]string) ([]byte, error) {
+func Serialize[V View](data map[string]V, dataInfo map[string]string, opts ...SerializeOption) ([]byte, error) {
+	dataInfo, err := applySerializeOptions(data, dataInfo, opts)
+	if err != nil {
+		return nil, err
+	}
 	pd, tensors, err := prepare(data, dataInfo)
 	if err != nil {
 		return nil, err
@@ -130,7 +177,7 @@ func Serialize[V View](data map[string]V, dataInfo map[string]string) ([]byte, e
 	buffer = binary.LittleEndian.AppendUint64(buffer, pd.n)
 	buffer = append(buffer, pd.headerBytes...)
 	for _, tensor := range tensors {
-		buffer = append(buffer, tensor.Data()...)
+		buffer = append(buffer, tensor.View.Data()...)
 	}
 	return buffer, nil
 }
@@ -139,28 +186,23 @@ func Serialize[V View](data map[string]V, dataInfo map[string]string) ([]byte, e
 //
 // Compared to Serialize, this procedure reduces the need to allocate the
 // whole amount of memory.
-func SerializeToWriter[V View](data map[string]V, dataInfo map[string]string, w io.Writer) error {
-	pd, tensors, err := prepare(data, dataInfo)
+func SerializeToWriter[V View](data map[string]V, dataInfo map[string]string, w io.Writer, opts ...SerializeOption) error {
+	dataInfo, err := applySerializeOptions(data, dataInfo, opts)
 	if err != nil {
 		return err
 	}
-
-	var nbArr [8]byte
-	nb := nbArr[:]
-	binary.LittleEndian.PutUint64(nb, pd.n)
-
-	_, err = w.Write(nb)
+	pd, tensors, err := prepare(data, dataInfo)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.Write(pd.headerBytes)
+	err = writeHeader(w, pd)
 	if err != nil {
 		return err
 	}
 
 	for _, tensor := range tensors {
-		_, err = w.Write(tensor.Data())
+		_, err = w.Write(tensor.View.Data())
 		if err != nil {
 			return err
 		}
@@ -175,7 +217,9 @@ type preparedData struct {
 	offset      uint64
 }
 
-func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (preparedData, []V, error) {
+// prepare builds the header for the given tensors, and returns the tensors
+// sorted in the same order in which their data must be written.
+func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (preparedData, []NamedView[V], error) {
 	// Make sure we're sorting by descending dtype alignment,
 	// then by name.
 	data := make([]NamedView[V], 0, len(dataMap))
@@ -188,16 +232,23 @@ func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (prepared
 		return ldt > rdt || (ldt == rdt && l.Name < r.Name)
 	})
 
-	tensors := make([]V, len(data))
 	hMetadata := make([]NamedTensorInfo, len(data))
 	offset := uint64(0)
 
 	for i, namedView := range data {
 		name, tensor := namedView.Name, namedView.View
-		n := tensor.DataLen()
+		n, err := checkView(tensor)
+		if err != nil {
+			return preparedData{}, nil, fmt.Errorf("invalid tensor %q: %w", name, err)
+		}
+		shape := tensor.Shape()
+		if shape == nil {
+			// A scalar must be written as "shape":[], not null.
+			shape = []uint64{}
+		}
 		tensorInfo := TensorInfo{
 			DType:       tensor.DType(),
-			Shape:       tensor.Shape(),
+			Shape:       shape,
 			DataOffsets: [2]uint64{offset, offset + n},
 		}
 		offset += n
@@ -205,7 +256,6 @@ func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (prepared
 			Name:       name,
 			TensorInfo: tensorInfo,
 		}
-		tensors[i] = tensor
 	}
 
 	metadata := newMetadata(dataInfo, hMetadata)
@@ -215,14 +265,7 @@ func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (prepared
 	}
 
 	// Force alignment to 8 bytes.
-	extra := (8 - len(metadataBuf)%8) % 8
-	if extra > 0 {
-		spaces := make([]byte, extra)
-		for i := range spaces {
-			spaces[i] = ' '
-		}
-		metadataBuf = append(metadataBuf, spaces...)
-	}
+	metadataBuf = appendSpaces(metadataBuf, (8-len(metadataBuf)%8)%8)
 
 	pd := preparedData{
 		n:           uint64(len(metadataBuf)),
@@ -230,5 +273,37 @@ func prepare[V View](dataMap map[string]V, dataInfo map[string]string) (prepared
 		offset:      offset,
 	}
 
-	return pd, tensors, nil
+	return pd, data, nil
+}
+
+// checkView verifies that the data size of the view is consistent with
+// its DType and shape, and returns it.
+func checkView(v View) (uint64, error) {
+	dType := v.DType()
+	if !dType.isValid() {
+		return 0, fmt.Errorf("%w %d", ErrInvalidDType, dType)
+	}
+	expected, err := dataLen(dType, v.Shape())
+	if err != nil {
+		return 0, err
+	}
+	if n := v.DataLen(); n != expected {
+		return 0, fmt.Errorf("expected %d bytes of data, actual %d", expected, n)
+	}
+	return expected, nil
+}
+
+// writeHeader writes the header size followed by the header itself.
+func writeHeader(w io.Writer, pd preparedData) error {
+	var nbArr [8]byte
+	nb := nbArr[:]
+	binary.LittleEndian.PutUint64(nb, pd.n)
+
+	_, err := w.Write(nb)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(pd.headerBytes)
+	return err
 }
@@ -113,6 +113,24 @@ func TestSerialize(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, expected, buf.Bytes())
 	})
+
+	t.Run("html escaping", func(t *testing.T) {
+		tv, err := NewTensorView(U8, []uint64{1}, []byte{42})
+		require.NoError(t, err)
+
+		out, err := Serialize(map[string]TensorView{"<a&b>": tv}, map[string]string{"k": "<v>"})
+		require.NoError(t, err)
+
+		expected := "\x70\x00\x00\x00\x00\x00\x00\x00" +
+			`{"\u003ca\u0026b\u003e":{"dtype":"U8","shape":[1],"data_offsets":[0,1]},"__metadata__":{"k":"\u003cv\u003e"}}` +
+			"   \x2a"
+		assert.Equal(t, expected, string(out))
+
+		st, err := Deserialize(out)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"<a&b>"}, st.Names())
+		assert.Equal(t, map[string]string{"k": "<v>"}, st.Metadata().Metadata())
+	})
 }
 
 func TestGPT2Like(t *testing.T) {
@@ -220,6 +238,22 @@ func TestEmptyShapesAllowed(t *testing.T) {
 	assert.Equal(t, []byte{0, 0, 0, 0}, tensor.Data())
 }
 
+func TestScalarNilShape(t *testing.T) {
+	tv, err := NewTensorView(F32, nil, []byte{0, 0, 0x80, 0x3f})
+	require.NoError(t, err)
+
+	out, err := Serialize(map[string]TensorView{"s": tv}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"shape":[]`)
+
+	loaded, err := Deserialize(out)
+	require.NoError(t, err)
+	tensor, ok := loaded.Tensor("s")
+	require.True(t, ok)
+	assert.Equal(t, []uint64{}, tensor.Shape())
+	assert.Equal(t, tv.Data(), tensor.Data())
+}
+
 func TestJSONAttack(t *testing.T) {
 	tensors := make(map[string]TensorInfo, 10)
 	dType := F32
@@ -331,7 +365,7 @@ func TestInvalidInfo(t *testing.T) {
 		`{"test":{"dtype":"I32","shape":[2,2],"data_offsets":[0, 4]}}`)
 
 	_, err := Deserialize(serialized)
-	assert.EqualError(t, err, "metadata validation error: info data offsets mismatch")
+	assert.EqualError(t, err, `metadata validation error: info data offsets mismatch: tensor "test" has 4 bytes of data, expected 16`)
 }
 
 func TestValidationOverflow(t *testing.T) {
@@ -0,0 +1,255 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ShardIndexFileName is the conventional name of the index file of a
+// sharded checkpoint.
+const ShardIndexFileName = "model.safetensors.index.json"
+
+// ShardIndex is the content of the index file of a sharded checkpoint,
+// conventionally named "model.safetensors.index.json".
+type ShardIndex struct {
+	// Metadata is an arbitrary set of information about the checkpoint.
+	Metadata map[string]any `json:"metadata"`
+	// WeightMap maps each tensor name to the name of the shard file
+	// containing it, relative to the index file.
+	WeightMap map[string]string `json:"weight_map"`
+}
+
+// ReadShardIndex decodes a ShardIndex from its JSON representation.
+func ReadShardIndex(r io.Reader) (ShardIndex, error) {
+	var index ShardIndex
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return ShardIndex{}, fmt.Errorf("invalid shard index: %w", err)
+	}
+	return index, nil
+}
+
+// ShardedFile provides a unified view over all the shards of a sharded
+// checkpoint, each one being memory-mapped as with Open.
+type ShardedFile struct {
+	index  ShardIndex
+	shards map[string]*File
+	names  []string
+}
+
+// OpenSharded opens the index file of a sharded checkpoint at the given
+// path, and all the shard files it refers to.
+//
+// An error is returned if the index refers to a tensor which is missing
+// from its shard, or if a shard contains a tensor not mapped to it by
+// the index.
+func OpenSharded(indexPath string) (*ShardedFile, error) {
+	return ParseOptions{}.OpenSharded(indexPath)
+}
+
+// OpenSharded is like the package-level OpenSharded function, but it
+// parses the header of each shard according to the options.
+func (o ParseOptions) OpenSharded(indexPath string) (*ShardedFile, error) {
+	index, err := readShardIndexFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &ShardedFile{
+		index:  index,
+		shards: make(map[string]*File),
+		names:  make([]string, 0, len(index.WeightMap)),
+	}
+	for name := range index.WeightMap {
+		sf.names = append(sf.names, name)
+	}
+	sort.Strings(sf.names)
+
+	dir := filepath.Dir(indexPath)
+	for _, shardName := range index.shardNames() {
+		if err = sf.openShard(o, dir, shardName); err != nil {
+			_ = sf.Close()
+			return nil, err
+		}
+	}
+	return sf, nil
+}
+
+func readShardIndexFile(name string) (ShardIndex, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return ShardIndex{}, err
+	}
+	defer f.Close()
+	return ReadShardIndex(f)
+}
+
+// shardNames returns the sorted names of all shards referred by the index.
+func (index ShardIndex) shardNames() []string {
+	set := make(map[string]struct{})
+	for _, shardName := range index.WeightMap {
+		set[shardName] = struct{}{}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sf *ShardedFile) openShard(o ParseOptions, dir, shardName string) error {
+	if !filepath.IsLocal(shardName) {
+		return fmt.Errorf("invalid shard file name %q", shardName)
+	}
+	f, err := o.Open(filepath.Join(dir, shardName))
+	if err != nil {
+		return fmt.Errorf("failed to open shard %q: %w", shardName, err)
+	}
+	sf.shards[shardName] = f
+
+	for _, name := range f.Names() {
+		if sf.index.WeightMap[name] != shardName {
+			return fmt.Errorf("shard %q contains tensor %q which is not mapped to it by the index", shardName, name)
+		}
+	}
+	for name, sn := range sf.index.WeightMap {
+		if _, ok := f.Tensor(name); sn == shardName && !ok {
+			return fmt.Errorf("tensor %q of the index is missing from shard %q", name, shardName)
+		}
+	}
+	return nil
+}
+
+// Index returns the content of the index file.
+func (sf *ShardedFile) Index() ShardIndex {
+	return sf.index
+}
+
+// Tensor allows the user to get the view of a specific tensor by name,
+// from whichever shard contains it.
+// The returned boolean flag reports whether the tensor was found.
+func (sf *ShardedFile) Tensor(name string) (TensorView, bool) {
+	shardName, ok := sf.index.WeightMap[name]
+	if !ok {
+		return TensorView{}, false
+	}
+	f, ok := sf.shards[shardName]
+	if !ok {
+		return TensorView{}, false
+	}
+	return f.Tensor(name)
+}
+
+// The Names of all tensors, across all shards, sorted alphabetically.
+func (sf *ShardedFile) Names() []string {
+	return sf.names
+}
+
+// Len returns how many tensors are stored within all shards.
+func (sf *ShardedFile) Len() int {
+	return len(sf.names)
+}
+
+// Close closes all shards.
+//
+// After Close, any TensorView previously obtained from the ShardedFile
+// must no longer be used.
+func (sf *ShardedFile) Close() error {
+	var firstErr error
+	for shardName, f := range sf.shards {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(sf.shards, shardName)
+	}
+	sf.names = nil
+	return firstErr
+}
+
+// SplitShards splits the tensors in groups, so that the total data size of
+// each group does not exceed maxShardSize.
+//
+// Tensors are assigned to shards in alphabetical order. A tensor whose
+// size alone exceeds maxShardSize is placed in a shard on its own.
+func SplitShards[V View](data map[string]V, maxShardSize uint64) []map[string]V {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var shards []map[string]V
+	var shard map[string]V
+	shardSize := uint64(0)
+
+	for _, name := range names {
+		tensor := data[name]
+		n := tensor.DataLen()
+		if shard == nil || (len(shard) > 0 && shardSize+n > maxShardSize) {
+			shard = make(map[string]V)
+			shards = append(shards, shard)
+			shardSize = 0
+		}
+		shard[name] = tensor
+		shardSize += n
+	}
+	return shards
+}
+
+// SerializeSharded splits the tensors in shards, as with SplitShards, and
+// writes them to the directory dir, together with the index file.
+//
+// Shard files are named "model-XXXXX-of-YYYYY.safetensors", and the index
+// file is named after ShardIndexFileName. The optional dataInfo metadata
+// is written to each shard. The returned ShardIndex reports the total size
+// of the tensors' data as "total_size" metadata.
+func SerializeSharded[V View](dir string, data map[string]V, dataInfo map[string]string, maxShardSize uint64, opts ...SerializeOption) (ShardIndex, error) {
+	shards := SplitShards(data, maxShardSize)
+
+	index := ShardIndex{
+		Metadata:  make(map[string]any, 1),
+		WeightMap: make(map[string]string, len(data)),
+	}
+	totalSize := uint64(0)
+
+	for i, shard := range shards {
+		shardName := fmt.Sprintf("model-%05d-of-%05d.safetensors", i+1, len(shards))
+		if err := serializeToFile(filepath.Join(dir, shardName), shard, dataInfo, opts); err != nil {
+			return ShardIndex{}, fmt.Errorf("failed to write shard %q: %w", shardName, err)
+		}
+		for name, tensor := range shard {
+			index.WeightMap[name] = shardName
+			totalSize += tensor.DataLen()
+		}
+	}
+	index.Metadata["total_size"] = totalSize
+
+	out, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return ShardIndex{}, fmt.Errorf("failed to JSON-marshal shard index: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, ShardIndexFileName), out, 0o666); err != nil {
+		return ShardIndex{}, err
+	}
+	return index, nil
+}
+
+func serializeToFile[V View](name string, data map[string]V, dataInfo map[string]string, opts []SerializeOption) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = SerializeToWriter(data, dataInfo, f, opts...)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSharded(t *testing.T) {
+	newShard := func(t *testing.T, dir, shardName string, names ...string) {
+		t.Helper()
+		tensors := make(map[string]TensorView, len(names))
+		for i, name := range names {
+			tv, err := NewTensorView(U8, []uint64{1}, []byte{byte(i)})
+			require.NoError(t, err)
+			tensors[name] = tv
+		}
+		out, err := Serialize(tensors, nil)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, shardName), out, 0o600))
+	}
+	newIndex := func(t *testing.T, dir string, weightMap map[string]string) string {
+		t.Helper()
+		out, err := json.Marshal(ShardIndex{
+			Metadata:  map[string]any{"total_size": 3},
+			WeightMap: weightMap,
+		})
+		require.NoError(t, err)
+		name := filepath.Join(dir, "model.safetensors.index.json")
+		require.NoError(t, os.WriteFile(name, out, 0o600))
+		return name
+	}
+
+	t.Run("valid checkpoint", func(t *testing.T) {
+		dir := t.TempDir()
+		newShard(t, dir, "model-00001-of-00002.safetensors", "a", "b")
+		newShard(t, dir, "model-00002-of-00002.safetensors", "c")
+		indexPath := newIndex(t, dir, map[string]string{
+			"a": "model-00001-of-00002.safetensors",
+			"b": "model-00001-of-00002.safetensors",
+			"c": "model-00002-of-00002.safetensors",
+		})
+
+		sf, err := OpenSharded(indexPath)
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, sf.Len())
+		assert.Equal(t, []string{"a", "b", "c"}, sf.Names())
+		assert.Equal(t, float64(3), sf.Index().Metadata["total_size"])
+
+		for i, name := range []string{"a", "b", "c"} {
+			tv, ok := sf.Tensor(name)
+			require.Truef(t, ok, "tensor %q", name)
+			assert.Equal(t, []byte{byte(i % 2)}, tv.Data(), "tensor %q", name)
+		}
+		_, ok := sf.Tensor("d")
+		assert.False(t, ok)
+
+		require.NoError(t, sf.Close())
+		_, ok = sf.Tensor("a")
+		assert.False(t, ok)
+		assert.Zero(t, sf.Len())
+	})
+
+	t.Run("tensor missing from shard", func(t *testing.T) {
+		dir := t.TempDir()
+		newShard(t, dir, "model-00001-of-00001.safetensors", "a")
+		indexPath := newIndex(t, dir, map[string]string{
+			"a": "model-00001-of-00001.safetensors",
+			"b": "model-00001-of-00001.safetensors",
+		})
+		_, err := OpenSharded(indexPath)
+		assert.EqualError(t, err, `tensor "b" of the index is missing from shard "model-00001-of-00001.safetensors"`)
+	})
+
+	t.Run("tensor not in index", func(t *testing.T) {
+		dir := t.TempDir()
+		newShard(t, dir, "model-00001-of-00001.safetensors", "a", "b")
+		indexPath := newIndex(t, dir, map[string]string{
+			"a": "model-00001-of-00001.safetensors",
+		})
+		_, err := OpenSharded(indexPath)
+		assert.EqualError(t, err, `shard "model-00001-of-00001.safetensors" contains tensor "b" which is not mapped to it by the index`)
+	})
+
+	t.Run("missing shard", func(t *testing.T) {
+		dir := t.TempDir()
+		indexPath := newIndex(t, dir, map[string]string{
+			"a": "model-00001-of-00001.safetensors",
+		})
+		_, err := OpenSharded(indexPath)
+		assert.ErrorContains(t, err, `failed to open shard "model-00001-of-00001.safetensors"`)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("non-local shard", func(t *testing.T) {
+		dir := t.TempDir()
+		indexPath := newIndex(t, dir, map[string]string{
+			"a": "../model.safetensors",
+		})
+		_, err := OpenSharded(indexPath)
+		assert.EqualError(t, err, `invalid shard file name "../model.safetensors"`)
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		indexPath := filepath.Join(t.TempDir(), "model.safetensors.index.json")
+		require.NoError(t, os.WriteFile(indexPath, []byte("{"), 0o600))
+		_, err := OpenSharded(indexPath)
+		assert.ErrorContains(t, err, "invalid shard index")
+	})
+}
+
+func TestSplitShards(t *testing.T) {
+	data := map[string]TensorView{
+		"a": newZeroU8Tensor(t, 4),
+		"b": newZeroU8Tensor(t, 4),
+		"c": newZeroU8Tensor(t, 3),
+		"d": newZeroU8Tensor(t, 20),
+		"e": newZeroU8Tensor(t, 1),
+	}
+
+	shards := SplitShards(data, 10)
+	assert.Equal(t, []map[string]TensorView{
+		{"a": data["a"], "b": data["b"]},
+		{"c": data["c"]},
+		{"d": data["d"]},
+		{"e": data["e"]},
+	}, shards)
+
+	assert.Empty(t, SplitShards(map[string]TensorView{}, 10))
+}
+
+func TestSerializeSharded(t *testing.T) {
+	dir := t.TempDir()
+	data := map[string]TensorView{
+		"a": newZeroU8Tensor(t, 6),
+		"b": newZeroU8Tensor(t, 6),
+		"c": newZeroU8Tensor(t, 2),
+	}
+	dataInfo := map[string]string{"format": "pt"}
+
+	index, err := SerializeSharded(dir, data, dataInfo, 10)
+	require.NoError(t, err)
+
+	expectedIndex := ShardIndex{
+		Metadata: map[string]any{"total_size": uint64(14)},
+		WeightMap: map[string]string{
+			"a": "model-00001-of-00002.safetensors",
+			"b": "model-00002-of-00002.safetensors",
+			"c": "model-00002-of-00002.safetensors",
+		},
+	}
+	assert.Equal(t, expectedIndex, index)
+
+	sf, err := OpenSharded(filepath.Join(dir, ShardIndexFileName))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	assert.Equal(t, []string{"a", "b", "c"}, sf.Names())
+	assert.Equal(t, expectedIndex.WeightMap, sf.Index().WeightMap)
+	assert.Equal(t, float64(14), sf.Index().Metadata["total_size"])
+	for name, expected := range data {
+		tv, ok := sf.Tensor(name)
+		require.Truef(t, ok, "tensor %q", name)
+		assert.Equal(t, expected, tv, "tensor %q", name)
+	}
+
+	shard, err := Open(filepath.Join(dir, "model-00001-of-00002.safetensors"))
+	require.NoError(t, err)
+	defer shard.Close()
+	assert.Equal(t, []string{"a"}, shard.Names())
+}
+
+func newZeroU8Tensor(t *testing.T, size int) TensorView {
+	t.Helper()
+	tv, err := NewTensorView(U8, []uint64{uint64(size)}, make([]byte, size))
+	require.NoError(t, err)
+	return tv
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import "fmt"
+
+// Range selects the indices in [Start, Stop) along one dimension of
+// a tensor.
+type Range struct {
+	Start uint64
+	Stop  uint64
+}
+
+// Len returns the number of indices selected by the range.
+func (r Range) Len() uint64 { return r.Stop - r.Start }
+
+// Slice returns a view of a sub-region of the tensor, similarly to
+// Python's get_slice.
+//
+// Each range selects the indices of the corresponding dimension, starting
+// from the outermost one. Dimensions without a range are taken in full.
+// The returned view has the same number of dimensions as the tensor.
+//
+// If the selected region is contiguous in C order, the returned view shares
+// the data of the tensor; otherwise the needed bytes are copied into a
+// new buffer.
+func (tv TensorView) Slice(ranges ...Range) (TensorView, error) {
+	plan, err := planSlice(tv.dType, tv.shape, ranges)
+	if err != nil {
+		return TensorView{}, err
+	}
+	var data []byte
+	if len(plan.runs) == 1 {
+		run := plan.runs[0]
+		data = tv.data[run.begin:run.end:run.end]
+	} else {
+		data = make([]byte, 0, plan.size)
+		for _, run := range plan.runs {
+			data = append(data, tv.data[run.begin:run.end]...)
+		}
+	}
+	return TensorView{
+		dType: tv.dType,
+		shape: plan.shape,
+		data:  data,
+	}, nil
+}
+
+// byteRun is a contiguous region of the data of a tensor, as
+// [begin, end) byte offsets.
+type byteRun struct {
+	begin uint64
+	end   uint64
+}
+
+// slicePlan describes how to extract a slice from the data of a tensor.
+type slicePlan struct {
+	// shape of the slice.
+	shape []uint64
+	// runs of bytes to be concatenated, in order.
+	runs []byteRun
+	// size is the total size of the runs.
+	size uint64
+}
+
+// planSlice validates the ranges against the shape, and computes the
+// shape of the slice and the minimal set of byte runs that compose it.
+func planSlice(dType DType, shape []uint64, ranges []Range) (slicePlan, error) {
+	full, err := normalizeRanges(shape, ranges)
+	if err != nil {
+		return slicePlan{}, err
+	}
+
+	// strides[i] is the size in bytes of one step along dimension i.
+	strides := make([]uint64, len(shape))
+	stride := dType.Size()
+	for i := len(shape) - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride *= shape[i]
+	}
+
+	plan := slicePlan{shape: make([]uint64, len(full))}
+	for i, r := range full {
+		plan.shape[i] = r.Len()
+	}
+	size, err := dataLen(dType, plan.shape)
+	if err != nil || size == 0 {
+		return plan, err
+	}
+
+	// The innermost dimensions which are taken in full are merged with the
+	// last partially selected one, so that each run is as long as possible.
+	k := len(full) - 1
+	for k >= 0 && full[k].Len() == shape[k] {
+		k--
+	}
+	if k < 0 {
+		plan.runs = []byteRun{{begin: 0, end: size}}
+	} else {
+		plan.runs = collectRuns(full[:k], strides[:k], full[k].Start*strides[k], full[k].Len()*strides[k])
+	}
+	plan.size = size
+	return plan, nil
+}
+
+// normalizeRanges validates the ranges, and returns one range for each
+// dimension of the shape.
+func normalizeRanges(shape []uint64, ranges []Range) ([]Range, error) {
+	if len(ranges) > len(shape) {
+		return nil, fmt.Errorf("%w: %d ranges for %d dimensions", ErrInvalidSlice, len(ranges), len(shape))
+	}
+	full := make([]Range, len(shape))
+	for i, size := range shape {
+		if i >= len(ranges) {
+			full[i] = Range{Start: 0, Stop: size}
+			continue
+		}
+		r := ranges[i]
+		if r.Start > r.Stop || r.Stop > size {
+			return nil, fmt.Errorf("%w: range [%d:%d] out of bounds for dimension %d of size %d",
+				ErrInvalidSlice, r.Start, r.Stop, i, size)
+		}
+		full[i] = r
+	}
+	return full, nil
+}
+
+// collectRuns enumerates, in C order, all the index combinations of the
+// outer ranges, returning a run of runLen bytes for each of them.
+// Adjacent runs are coalesced.
+func collectRuns(outer []Range, strides []uint64, innerOffset, runLen uint64) []byteRun {
+	idx := make([]uint64, len(outer))
+	for i, r := range outer {
+		idx[i] = r.Start
+	}
+	var runs []byteRun
+	for {
+		begin := innerOffset
+		for i, v := range idx {
+			begin += v * strides[i]
+		}
+		if n := len(runs); n > 0 && runs[n-1].end == begin {
+			runs[n-1].end += runLen
+		} else {
+			runs = append(runs, byteRun{begin: begin, end: begin + runLen})
+		}
+		if !nextIndex(idx, outer) {
+			return runs
+		}
+	}
+}
+
+// nextIndex advances idx to the next index combination within the ranges,
+// in C order. It reports false when all combinations have been visited.
+func nextIndex(idx []uint64, ranges []Range) bool {
+	for i := len(idx) - 1; i >= 0; i-- {
+		idx[i]++
+		if idx[i] < ranges[i].Stop {
+			return true
+		}
+		idx[i] = ranges[i].Start
+	}
+	return false
+}
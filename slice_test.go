@@ -0,0 +1,136 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newArangeU8 creates a U8 tensor whose elements are 0, 1, 2, ...
+func newArangeU8(t *testing.T, shape ...uint64) TensorView {
+	t.Helper()
+	n := uint64(1)
+	for _, v := range shape {
+		n *= v
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	tv, err := NewTensorView(U8, shape, data)
+	require.NoError(t, err)
+	return tv
+}
+
+func TestTensorViewSlice(t *testing.T) {
+	tv := newArangeU8(t, 3, 4)
+
+	testCases := []struct {
+		name   string
+		ranges []Range
+		shape  []uint64
+		data   []byte
+		shared bool
+	}{
+		{"no ranges", nil, []uint64{3, 4}, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, true},
+		{"rows", []Range{{1, 3}}, []uint64{2, 4}, []byte{4, 5, 6, 7, 8, 9, 10, 11}, true},
+		{"single row part", []Range{{1, 2}, {1, 3}}, []uint64{1, 2}, []byte{5, 6}, true},
+		{"columns", []Range{{0, 3}, {1, 3}}, []uint64{3, 2}, []byte{1, 2, 5, 6, 9, 10}, false},
+		{"block", []Range{{1, 3}, {2, 4}}, []uint64{2, 2}, []byte{6, 7, 10, 11}, false},
+		{"empty", []Range{{1, 1}}, []uint64{0, 4}, []byte{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := tv.Slice(tc.ranges...)
+			require.NoError(t, err)
+			assert.Equal(t, U8, s.DType())
+			assert.Equal(t, tc.shape, s.Shape())
+			assert.Equal(t, tc.data, s.Data())
+			if tc.shared {
+				assert.Same(t, &tv.Data()[tc.data[0]], &s.Data()[0])
+			}
+		})
+	}
+
+	t.Run("multi-byte elements in 3 dimensions", func(t *testing.T) {
+		data := make([]byte, 2*3*2*2)
+		for i := 0; i < len(data); i += 2 {
+			data[i] = byte(i / 2)
+		}
+		tv, err := NewTensorView(I16, []uint64{2, 3, 2}, data)
+		require.NoError(t, err)
+
+		s, err := tv.Slice(Range{0, 2}, Range{1, 2})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 1, 2}, s.Shape())
+		assert.Equal(t, []byte{2, 0, 3, 0, 8, 0, 9, 0}, s.Data())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := tv.Slice(Range{0, 1}, Range{0, 1}, Range{0, 1})
+		assert.ErrorIs(t, err, ErrInvalidSlice)
+		assert.EqualError(t, err, "invalid slice: 3 ranges for 2 dimensions")
+
+		_, err = tv.Slice(Range{0, 4})
+		assert.EqualError(t, err, "invalid slice: range [0:4] out of bounds for dimension 0 of size 3")
+
+		_, err = tv.Slice(Range{2, 1})
+		assert.ErrorIs(t, err, ErrInvalidSlice)
+	})
+}
+
+// recordingReaderAt records the regions read from an io.ReaderAt.
+type recordingReaderAt struct {
+	r     io.ReaderAt
+	reads [][2]int64
+}
+
+func (ra *recordingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	ra.reads = append(ra.reads, [2]int64{off, int64(len(p))})
+	return ra.r.ReadAt(p, off)
+}
+
+func TestReaderSlice(t *testing.T) {
+	tv := newArangeU8(t, 4, 4)
+	serialized, err := Serialize(map[string]TensorView{"a": tv}, nil)
+	require.NoError(t, err)
+
+	ra := &recordingReaderAt{r: bytes.NewReader(serialized)}
+	r, err := NewReader(ra, int64(len(serialized)))
+	require.NoError(t, err)
+	dataOffset := r.dataOffset
+
+	t.Run("contiguous", func(t *testing.T) {
+		ra.reads = nil
+		s, err := r.Slice("a", Range{1, 3})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 4}, s.Shape())
+		assert.Equal(t, []byte{4, 5, 6, 7, 8, 9, 10, 11}, s.Data())
+		assert.Equal(t, [][2]int64{{dataOffset + 4, 8}}, ra.reads)
+	})
+
+	t.Run("strided", func(t *testing.T) {
+		ra.reads = nil
+		s, err := r.Slice("a", Range{1, 3}, Range{2, 3})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 1}, s.Shape())
+		assert.Equal(t, []byte{6, 10}, s.Data())
+		assert.Equal(t, [][2]int64{{dataOffset + 6, 1}, {dataOffset + 10, 1}}, ra.reads)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := r.Slice("b")
+		assert.ErrorIs(t, err, ErrTensorNotFound)
+
+		_, err = r.Slice("a", Range{0, 5})
+		assert.ErrorIs(t, err, ErrInvalidSlice)
+		assert.ErrorContains(t, err, `failed to slice tensor "a"`)
+	})
+}
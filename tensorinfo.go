@@ -4,6 +4,8 @@
 
 package safetensors
 
+import "fmt"
+
 // TensorInfo provides information of a single tensor.
 // Endianness is assumed to be little-endian. Ordering is assumed to be 'C'.
 type TensorInfo struct {
@@ -21,3 +23,24 @@ type NamedTensorInfo struct {
 	Name       string
 	TensorInfo TensorInfo
 }
+
+// dataLen returns the expected size in bytes of the data of a tensor
+// with the given DType and shape.
+//
+// An empty shape denotes a scalar, which has one element.
+func dataLen(dType DType, shape []uint64) (uint64, error) {
+	numElements := uint64(1)
+	for _, v := range shape {
+		var err error
+		numElements, err = checkedMul(numElements, v)
+		if err != nil {
+			return 0, fmt.Errorf("failed to compute num elements from shape: %w", err)
+		}
+	}
+
+	numBytes, err := checkedMul(numElements, dType.Size())
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute num bytes from num elements: %w", err)
+	}
+	return numBytes, nil
+}
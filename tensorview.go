@@ -28,11 +28,19 @@ func (tv TensorView) Data() []byte    { return tv.data }
 func (tv TensorView) DataLen() uint64 { return uint64(len(tv.data)) }
 
 // NewTensorView creates a new TensorView.
+//
+// A tensor with an empty shape is a scalar, having exactly one element.
 func NewTensorView(dType DType, shape []uint64, data []byte) (TensorView, error) {
-	n := uint64(len(data))
-	numElements := numElementsFromShape(shape)
+	if !dType.isValid() {
+		return TensorView{}, fmt.Errorf("invalid tensor view: %w %d", ErrInvalidDType, dType)
+	}
+	expected, err := dataLen(dType, shape)
+	if err != nil {
+		return TensorView{}, fmt.Errorf("invalid tensor view: %w", err)
+	}
 
-	if n != numElements*dType.Size() {
+	n := uint64(len(data))
+	if n != expected {
 		return TensorView{}, fmt.Errorf("invalid tensor view: dtype=%d shape=%+v len(data)=%d", dType, shape, n)
 	}
 
@@ -42,14 +50,3 @@ func NewTensorView(dType DType, shape []uint64, data []byte) (TensorView, error)
 		data:  data,
 	}, nil
 }
-
-func numElementsFromShape(shape []uint64) uint64 {
-	if len(shape) == 0 {
-		return 0
-	}
-	n := shape[0]
-	for _, v := range shape[1:] {
-		n *= v
-	}
-	return n
-}
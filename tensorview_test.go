@@ -4,4 +4,86 @@
 
 package safetensors
 
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
 var _ View = TensorView{}
+
+func TestNewTensorView(t *testing.T) {
+	t.Run("scalar", func(t *testing.T) {
+		tv, err := NewTensorView(F32, []uint64{}, make([]byte, 4))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{}, tv.Shape())
+		assert.Equal(t, uint64(4), tv.DataLen())
+
+		_, err = NewTensorView(F32, nil, nil)
+		assert.EqualError(t, err, fmt.Sprintf("invalid tensor view: dtype=%d shape=[] len(data)=0", F32))
+	})
+
+	t.Run("zero-sized dimension", func(t *testing.T) {
+		tv, err := NewTensorView(F32, []uint64{3, 0, 2}, []byte{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), tv.DataLen())
+
+		_, err = NewTensorView(F32, []uint64{3, 0, 2}, make([]byte, 4))
+		assert.Error(t, err)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := NewTensorView(U8, []uint64{1 << 32, 1 << 32}, nil)
+		assert.ErrorIs(t, err, ErrOverflow)
+	})
+
+	t.Run("invalid DType", func(t *testing.T) {
+		_, err := NewTensorView(DType(200), []uint64{1}, []byte{0})
+		assert.ErrorIs(t, err, ErrInvalidDType)
+	})
+}
+
+// invalidView is a View whose data size is not consistent with its shape.
+type invalidView struct{ TensorView }
+
+func (v invalidView) DataLen() uint64 { return v.TensorView.DataLen() + 1 }
+
+func TestScalarRoundTrip(t *testing.T) {
+	scalar, err := NewTensorView(I64, []uint64{}, []byte{42, 0, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	empty, err := NewTensorView(F32, []uint64{2, 0}, []byte{})
+	require.NoError(t, err)
+
+	serialized, err := Serialize(map[string]TensorView{"step": scalar, "empty": empty}, nil)
+	require.NoError(t, err)
+
+	st, err := Deserialize(serialized)
+	require.NoError(t, err)
+
+	for name, expected := range map[string]TensorView{"step": scalar, "empty": empty} {
+		actual, ok := st.Tensor(name)
+		require.True(t, ok, name)
+		assert.Equal(t, expected.DType(), actual.DType(), name)
+		assert.Equal(t, expected.Shape(), actual.Shape(), name)
+		assert.Equal(t, expected.Data(), actual.Data(), name)
+
+		rebuilt, err := NewTensorView(actual.DType(), actual.Shape(), actual.Data())
+		require.NoError(t, err, name)
+		assert.Equal(t, actual, rebuilt, name)
+	}
+
+	views := make(map[string]TensorView)
+	for _, nv := range st.Tensors() {
+		views[nv.Name] = nv.TensorView
+	}
+	reserialized, err := Serialize(views, nil)
+	require.NoError(t, err)
+	assert.Equal(t, serialized, reserialized)
+
+	t.Run("inconsistent view", func(t *testing.T) {
+		_, err := Serialize(map[string]invalidView{"a": {scalar}}, nil)
+		assert.EqualError(t, err, `invalid tensor "a": expected 8 bytes of data, actual 9`)
+	})
+}
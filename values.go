@@ -0,0 +1,160 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/nlpodyssey/safetensors/internal/tensorio"
+)
+
+// Element is the set of Go types which directly correspond to a DType.
+type Element interface {
+	bool | uint8 | int8 | int16 | uint16 | int32 | uint32 | float32 | float64 | int64 | uint64
+}
+
+// hostLittleEndian reports whether the native byte order is little-endian.
+var hostLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// elementDTypes maps each Element type to its DType.
+var elementDTypes = map[reflect.Type]DType{
+	reflect.TypeOf(false):      BOOL,
+	reflect.TypeOf(uint8(0)):   U8,
+	reflect.TypeOf(int8(0)):    I8,
+	reflect.TypeOf(int16(0)):   I16,
+	reflect.TypeOf(uint16(0)):  U16,
+	reflect.TypeOf(int32(0)):   I32,
+	reflect.TypeOf(uint32(0)):  U32,
+	reflect.TypeOf(float32(0)): F32,
+	reflect.TypeOf(float64(0)): F64,
+	reflect.TypeOf(int64(0)):   I64,
+	reflect.TypeOf(uint64(0)):  U64,
+}
+
+// DTypeOf returns the DType corresponding to the Go type T.
+func DTypeOf[T Element]() DType {
+	return elementDTypes[reflect.TypeOf(*new(T))]
+}
+
+// Values returns the elements of the tensor as a slice of T, whose
+// corresponding DType must match the tensor's DType.
+//
+// Whenever possible, that is, when the host is little-endian and the data
+// is suitably aligned, the returned slice shares the memory of the tensor's
+// data, avoiding any copy: in this case, modifying the slice also modifies
+// the tensor, and the data of a memory-mapped File must not be modified at
+// all. Use CopyValues to always get an independent slice.
+func Values[T Element](tv TensorView) ([]T, error) {
+	if err := checkElementDType[T](tv); err != nil {
+		return nil, err
+	}
+	return bytesAs[T](tv.data), nil
+}
+
+// Float32Values returns the elements of a floating point tensor, of any
+// floating point DType, converted to float32.
+//
+// The returned slice never shares the memory of the tensor's data.
+func Float32Values(tv TensorView) ([]float32, error) {
+	values := make([]float32, len(tv.data)/int(tv.dType.Size()))
+	switch tv.dType {
+	case F8_E5M2:
+		for i, v := range tv.data {
+			values[i] = F8E5M2ToFloat32(v)
+		}
+	case F8_E4M3:
+		for i, v := range tv.data {
+			values[i] = F8E4M3ToFloat32(v)
+		}
+	case F16:
+		F16ToFloat32Slice(values, bytesAs[uint16](tv.data))
+	case BF16:
+		BF16ToFloat32Slice(values, bytesAs[uint16](tv.data))
+	case F32:
+		copy(values, bytesAs[float32](tv.data))
+	case F64:
+		for i, v := range bytesAs[float64](tv.data) {
+			values[i] = float32(v)
+		}
+	default:
+		return nil, fmt.Errorf("cannot get float32 values from tensor with DType %s", tv.dType)
+	}
+	return values, nil
+}
+
+// CopyValues returns a copy of the elements of the tensor as a slice of T,
+// whose corresponding DType must match the tensor's DType.
+func CopyValues[T Element](tv TensorView) ([]T, error) {
+	if err := checkElementDType[T](tv); err != nil {
+		return nil, err
+	}
+	return copyValues[T](tv.data, hostLittleEndian), nil
+}
+
+func checkElementDType[T Element](tv TensorView) error {
+	if dt := DTypeOf[T](); tv.dType != dt {
+		return fmt.Errorf("cannot get values of type %T from tensor with DType %s", *new(T), tv.dType)
+	}
+	return nil
+}
+
+// bytesAs reinterprets little-endian data as a slice of T, only copying
+// it when necessary.
+func bytesAs[T Element](data []byte) []T {
+	if !canCastBytes[T](data) {
+		return copyValues[T](data, hostLittleEndian)
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&data[0])), len(data)/int(unsafe.Sizeof(*new(T))))
+}
+
+// canCastBytes reports whether data can be reinterpreted in place as
+// a slice of T.
+func canCastBytes[T Element](data []byte) bool {
+	if !hostLittleEndian || len(data) == 0 {
+		return false
+	}
+	if uintptr(unsafe.Pointer(&data[0]))%unsafe.Alignof(*new(T)) != 0 {
+		return false
+	}
+	if _, ok := any(*new(T)).(bool); ok {
+		// Any byte other than 0 and 1 is not a valid Go bool.
+		for _, b := range data {
+			if b > 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// copyValues decodes little-endian data into a new slice of T, assuming
+// the given native byte order.
+func copyValues[T Element](data []byte, littleEndian bool) []T {
+	size := int(unsafe.Sizeof(*new(T)))
+	values := make([]T, len(data)/size)
+	if len(values) == 0 {
+		return values
+	}
+
+	if _, ok := any(values).([]bool); ok {
+		bools := any(values).([]bool)
+		for i, b := range data {
+			bools[i] = b != 0
+		}
+		return values
+	}
+
+	dst := unsafe.Slice((*byte)(unsafe.Pointer(&values[0])), len(data))
+	copy(dst, data)
+	if !littleEndian {
+		tensorio.SwapBytes(dst, size)
+	}
+	return values
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDTypeOf(t *testing.T) {
+	assert.Equal(t, BOOL, DTypeOf[bool]())
+	assert.Equal(t, U8, DTypeOf[uint8]())
+	assert.Equal(t, I8, DTypeOf[int8]())
+	assert.Equal(t, I16, DTypeOf[int16]())
+	assert.Equal(t, U16, DTypeOf[uint16]())
+	assert.Equal(t, I32, DTypeOf[int32]())
+	assert.Equal(t, U32, DTypeOf[uint32]())
+	assert.Equal(t, F32, DTypeOf[float32]())
+	assert.Equal(t, F64, DTypeOf[float64]())
+	assert.Equal(t, I64, DTypeOf[int64]())
+	assert.Equal(t, U64, DTypeOf[uint64]())
+}
+
+func TestValues(t *testing.T) {
+	t.Run("decoding", func(t *testing.T) {
+		assertValues(t, BOOL, []byte{0, 1, 1}, []bool{false, true, true})
+		assertValues(t, U8, []byte{0, 1, 255}, []uint8{0, 1, 255})
+		assertValues(t, I8, []byte{0, 1, 255}, []int8{0, 1, -1})
+		assertValues(t, I16, []byte{1, 0, 0xfe, 0xff}, []int16{1, -2})
+		assertValues(t, U16, []byte{1, 0, 0xfe, 0xff}, []uint16{1, 0xfffe})
+		assertValues(t, I32, []byte{1, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff}, []int32{1, -2})
+		assertValues(t, U32, []byte{1, 2, 3, 4}, []uint32{0x04030201})
+		assertValues(t, F32, []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0xc0}, []float32{1, -2})
+		assertValues(t, F64, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}, []float64{1})
+		assertValues(t, I64, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []int64{-2})
+		assertValues(t, U64, []byte{1, 2, 3, 4, 5, 6, 7, 8}, []uint64{0x0807060504030201})
+	})
+
+	t.Run("zero-copy", func(t *testing.T) {
+		if !hostLittleEndian {
+			t.Skip("zero-copy is only available on little-endian hosts")
+		}
+		tv, err := NewTensorView(F32, []uint64{2}, make([]byte, 8))
+		require.NoError(t, err)
+
+		values, err := Values[float32](tv)
+		require.NoError(t, err)
+		values[1] = 1
+		assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0x80, 0x3f}, tv.Data())
+	})
+
+	t.Run("misaligned data is copied", func(t *testing.T) {
+		buf := make([]byte, 9)
+		tv, err := NewTensorView(F32, []uint64{2}, buf[1:])
+		require.NoError(t, err)
+
+		values, err := Values[float32](tv)
+		require.NoError(t, err)
+		values[1] = 1
+		assert.Equal(t, make([]byte, 9), buf)
+	})
+
+	t.Run("invalid booleans are copied", func(t *testing.T) {
+		tv, err := NewTensorView(BOOL, []uint64{3}, []byte{0, 1, 2})
+		require.NoError(t, err)
+
+		values, err := Values[bool](tv)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true, true}, values)
+	})
+
+	t.Run("empty tensor", func(t *testing.T) {
+		tv, err := NewTensorView(F32, []uint64{0, 3}, []byte{})
+		require.NoError(t, err)
+
+		values, err := Values[float32](tv)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{}, values)
+	})
+
+	t.Run("DType mismatch", func(t *testing.T) {
+		tv, err := NewTensorView(F32, []uint64{1}, make([]byte, 4))
+		require.NoError(t, err)
+
+		_, err = Values[int32](tv)
+		assert.EqualError(t, err, "cannot get values of type int32 from tensor with DType F32")
+		_, err = CopyValues[uint32](tv)
+		assert.EqualError(t, err, "cannot get values of type uint32 from tensor with DType F32")
+	})
+}
+
+func TestCopyValues(t *testing.T) {
+	data := []byte{0, 0, 0x80, 0x3f}
+	tv, err := NewTensorView(F32, []uint64{1}, data)
+	require.NoError(t, err)
+
+	values, err := CopyValues[float32](tv)
+	require.NoError(t, err)
+	assert.Equal(t, []float32{1}, values)
+
+	values[0] = 2
+	assert.Equal(t, []byte{0, 0, 0x80, 0x3f}, data)
+}
+
+func assertValues[T Element](t *testing.T, dType DType, data []byte, expected []T) {
+	t.Helper()
+	tv, err := NewTensorView(dType, []uint64{uint64(len(expected))}, data)
+	require.NoError(t, err)
+
+	values, err := Values[T](tv)
+	require.NoError(t, err)
+	assert.Equal(t, expected, values)
+
+	values, err = CopyValues[T](tv)
+	require.NoError(t, err)
+	assert.Equal(t, expected, values)
+}
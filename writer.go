@@ -0,0 +1,149 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writer serializes tensors to an io.Writer one at a time.
+//
+// All tensors must be declared upfront, when creating the Writer, so that
+// the header can be written immediately. The data of each tensor is then
+// written in the order reported by Writer.Names.
+//
+// The output is identical to the one produced by Serialize and
+// SerializeToWriter, given the same tensors and metadata.
+type Writer struct {
+	w       io.Writer
+	tensors []NamedView[declaredView]
+	next    int
+	err     error
+}
+
+// declaredView is a View with no data, used to prepare the header of
+// tensors whose data is not available yet.
+type declaredView struct {
+	dType   DType
+	shape   []uint64
+	dataLen uint64
+}
+
+func (dv declaredView) DType() DType    { return dv.dType }
+func (dv declaredView) Shape() []uint64 { return dv.shape }
+func (dv declaredView) Data() []byte    { return nil }
+func (dv declaredView) DataLen() uint64 { return dv.dataLen }
+
+// NewWriter declares the given tensors and metadata, and writes the
+// resulting header to w.
+//
+// Only DType and Shape of each TensorInfo are considered, while
+// DataOffsets are ignored, being computed automatically.
+func NewWriter(w io.Writer, tensors map[string]TensorInfo, dataInfo map[string]string) (*Writer, error) {
+	views := make(map[string]declaredView, len(tensors))
+	for name, info := range tensors {
+		if !info.DType.isValid() {
+			return nil, fmt.Errorf("%w %d for tensor %q", ErrInvalidDType, info.DType, name)
+		}
+		n, err := dataLen(info.DType, info.Shape)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tensor %q: %w", name, err)
+		}
+		views[name] = declaredView{
+			dType:   info.DType,
+			shape:   info.Shape,
+			dataLen: n,
+		}
+	}
+
+	pd, sorted, err := prepare(views, dataInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeHeader(w, pd); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:       w,
+		tensors: sorted,
+	}, nil
+}
+
+// Names returns the names of all declared tensors, in the order in which
+// their data must be written.
+func (w *Writer) Names() []string {
+	names := make([]string, len(w.tensors))
+	for i, t := range w.tensors {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// WriteTensor writes the data of the named tensor, which must be the
+// next one expected, and whose size must match the declared one.
+func (w *Writer) WriteTensor(name string, data []byte) error {
+	tensor, err := w.nextTensor(name)
+	if err != nil {
+		return err
+	}
+	if n := uint64(len(data)); n != tensor.dataLen {
+		return fmt.Errorf("invalid data size for tensor %q: expected %d bytes, actual %d", name, tensor.dataLen, n)
+	}
+	if _, err = w.w.Write(data); err != nil {
+		w.err = err
+		return err
+	}
+	w.next++
+	return nil
+}
+
+// CopyTensor writes the data of the named tensor, which must be the next
+// one expected, reading it from r.
+//
+// Exactly the declared amount of bytes is read from r: an error is
+// returned if r provides less data than that.
+func (w *Writer) CopyTensor(name string, r io.Reader) error {
+	tensor, err := w.nextTensor(name)
+	if err != nil {
+		return err
+	}
+	n, err := io.CopyN(w.w, r, int64(tensor.dataLen))
+	if err == io.EOF {
+		err = fmt.Errorf("invalid data size for tensor %q: expected %d bytes, actual %d", name, tensor.dataLen, n)
+	}
+	if err != nil {
+		w.err = err
+		return err
+	}
+	w.next++
+	return nil
+}
+
+// Close reports an error if the data of some declared tensors was not
+// written. It does not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.next < len(w.tensors) {
+		return fmt.Errorf("missing data for %d tensors, starting from %q", len(w.tensors)-w.next, w.tensors[w.next].Name)
+	}
+	return nil
+}
+
+func (w *Writer) nextTensor(name string) (declaredView, error) {
+	if w.err != nil {
+		return declaredView{}, w.err
+	}
+	if w.next >= len(w.tensors) {
+		return declaredView{}, fmt.Errorf("cannot write tensor %q: all tensors have already been written", name)
+	}
+	if expected := w.tensors[w.next].Name; name != expected {
+		return declaredView{}, fmt.Errorf("cannot write tensor %q: expected tensor %q", name, expected)
+	}
+	return w.tensors[w.next].View, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 The NLP Odyssey Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package safetensors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	newTensors := func(t *testing.T) map[string]TensorView {
+		a, err := NewTensorView(U8, []uint64{3}, []byte{1, 2, 3})
+		require.NoError(t, err)
+		b, err := NewTensorView(F32, []uint64{1, 2}, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+		require.NoError(t, err)
+		c, err := NewTensorView(I16, []uint64{2}, []byte{9, 10, 11, 12})
+		require.NoError(t, err)
+		return map[string]TensorView{"a": a, "b": b, "c": c}
+	}
+	infosOf := func(tensors map[string]TensorView) map[string]TensorInfo {
+		infos := make(map[string]TensorInfo, len(tensors))
+		for name, tv := range tensors {
+			infos[name] = TensorInfo{DType: tv.DType(), Shape: tv.Shape()}
+		}
+		return infos
+	}
+	dataInfo := map[string]string{"foo": "bar"}
+
+	t.Run("same output as Serialize", func(t *testing.T) {
+		tensors := newTensors(t)
+		expected, err := Serialize(tensors, dataInfo)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, infosOf(tensors), dataInfo)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"b", "c", "a"}, w.Names())
+
+		require.NoError(t, w.WriteTensor("b", tensors["b"].Data()))
+		require.NoError(t, w.CopyTensor("c", bytes.NewReader(tensors["c"].Data())))
+		require.NoError(t, w.WriteTensor("a", tensors["a"].Data()))
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, expected, buf.Bytes())
+	})
+
+	t.Run("wrong order", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, infosOf(newTensors(t)), nil)
+		require.NoError(t, err)
+		err = w.WriteTensor("a", []byte{1, 2, 3})
+		assert.EqualError(t, err, `cannot write tensor "a": expected tensor "b"`)
+	})
+
+	t.Run("wrong data size", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, infosOf(newTensors(t)), nil)
+		require.NoError(t, err)
+
+		err = w.WriteTensor("b", []byte{1, 2, 3})
+		assert.EqualError(t, err, `invalid data size for tensor "b": expected 8 bytes, actual 3`)
+
+		err = w.CopyTensor("b", strings.NewReader("123"))
+		assert.EqualError(t, err, `invalid data size for tensor "b": expected 8 bytes, actual 3`)
+
+		// The error is sticky.
+		assert.Error(t, w.WriteTensor("b", make([]byte, 8)))
+		assert.Error(t, w.Close())
+	})
+
+	t.Run("too many tensors", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, map[string]TensorInfo{"a": {DType: U8, Shape: []uint64{1}}}, nil)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteTensor("a", []byte{1}))
+		err = w.WriteTensor("a", []byte{1})
+		assert.EqualError(t, err, `cannot write tensor "a": all tensors have already been written`)
+	})
+
+	t.Run("missing tensors", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, infosOf(newTensors(t)), nil)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteTensor("b", make([]byte, 8)))
+		assert.EqualError(t, w.Close(), `missing data for 2 tensors, starting from "c"`)
+	})
+
+	t.Run("invalid declarations", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := NewWriter(&buf, map[string]TensorInfo{"a": {DType: DType(200)}}, nil)
+		assert.EqualError(t, err, `invalid DType 200 for tensor "a"`)
+
+		_, err = NewWriter(&buf, map[string]TensorInfo{"a": {DType: U8, Shape: []uint64{2, 18446744073709551614}}}, nil)
+		assert.ErrorContains(t, err, `invalid tensor "a": failed to compute num elements from shape: multiplication overflow`)
+
+		assert.Zero(t, buf.Len())
+	})
+}